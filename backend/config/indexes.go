@@ -46,6 +46,9 @@ func CreateIndexes() error {
 		{
 			Keys: bson.D{{Key: "isActive", Value: 1}},
 		},
+		{
+			Keys: bson.D{{Key: "multisig.publicKeys", Value: 1}},
+		},
 	}
 	if _, err := walletsCollection.Indexes().CreateMany(ctx, walletsIndexes); err != nil {
 		log.Printf("Warning: Failed to create wallets indexes: %v", err)
@@ -81,6 +84,9 @@ func CreateIndexes() error {
 		{
 			Keys: bson.D{{Key: "receiverWalletId", Value: 1}},
 		},
+		{
+			Keys: bson.D{{Key: "outputUtxos.walletId", Value: 1}},
+		},
 		{
 			Keys: bson.D{{Key: "timestamp", Value: -1}},
 		},
@@ -170,6 +176,31 @@ func CreateIndexes() error {
 		log.Printf("Warning: Failed to create transaction logs indexes: %v", err)
 	}
 
+	// Multisig proposals collection indexes
+	proposalsCollection := GetCollection("multisigProposals")
+	proposalsIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "walletId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	}
+	if _, err := proposalsCollection.Indexes().CreateMany(ctx, proposalsIndexes); err != nil {
+		log.Printf("Warning: Failed to create multisig proposals indexes: %v", err)
+	}
+
+	// Scheduled transfers collection indexes
+	scheduledCollection := GetCollection("scheduledTransfers")
+	scheduledIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextRunAt", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	}
+	if _, err := scheduledCollection.Indexes().CreateMany(ctx, scheduledIndexes); err != nil {
+		log.Printf("Warning: Failed to create scheduled transfers indexes: %v", err)
+	}
+
 	log.Println("Database indexes created successfully")
 	return nil
 }
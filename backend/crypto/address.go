@@ -0,0 +1,280 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Wallet addresses are Bech32 (BIP-173) strings: a network prefix, the separator "1",
+// then a version byte naming the key algorithm followed by the SHA-256 of the PEM public
+// key, and a 6-character checksum that catches any typo of up to four characters.
+//
+// Wallets created before addresses keep their hex ID: the same hash in hex, prefixed
+// with "ed" or "k1" for non-RSA keys. Both forms carry the same hash, so each legacy ID
+// is an alias of exactly one address and either can be converted to the other offline.
+
+// Errors returned when decoding a wallet address
+var (
+	ErrAddressFormat   = errors.New("malformed wallet address")
+	ErrAddressChecksum = errors.New("wallet address checksum mismatch, check for typos")
+	ErrAddressNetwork  = errors.New("wallet address is for a different network")
+)
+
+// addressVersions is the payload version byte for each key algorithm
+var addressVersions = map[KeyAlgorithm]byte{
+	AlgorithmRSA:       0,
+	AlgorithmEd25519:   1,
+	AlgorithmSecp256k1: 2,
+	AlgorithmMultisig:  3,
+}
+
+// legacyWalletIDPrefixes tag hex wallet IDs with their key algorithm. RSA IDs predate
+// tagging and are a bare 64-character hash.
+var legacyWalletIDPrefixes = map[KeyAlgorithm]string{
+	AlgorithmRSA:       "",
+	AlgorithmEd25519:   "ed",
+	AlgorithmSecp256k1: "k1",
+	AlgorithmMultisig:  "ms",
+}
+
+var hexHashRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// GetAddressPrefix returns the network prefix of wallet addresses (ADDRESS_PREFIX)
+func GetAddressPrefix() string {
+	if prefix := os.Getenv("ADDRESS_PREFIX"); prefix != "" {
+		return strings.ToLower(prefix)
+	}
+	return "bcw"
+}
+
+// WalletIDFromPublicKey derives the address of a PEM public key. New wallets use the
+// address as their wallet ID.
+func WalletIDFromPublicKey(publicKeyPEM string) (string, error) {
+	algorithm, err := DetectKeyAlgorithm(publicKeyPEM)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(publicKeyPEM))
+	return EncodeAddress(algorithm, hash[:])
+}
+
+// LegacyWalletIDFromPublicKey derives the hex wallet ID used before addresses
+func LegacyWalletIDFromPublicKey(publicKeyPEM string) (string, error) {
+	algorithm, err := DetectKeyAlgorithm(publicKeyPEM)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(publicKeyPEM))
+	return legacyWalletIDPrefixes[algorithm] + hex.EncodeToString(hash[:]), nil
+}
+
+// EncodeAddress encodes a key algorithm and 32-byte public key hash as an address
+func EncodeAddress(algorithm KeyAlgorithm, hash []byte) (string, error) {
+	version, ok := addressVersions[algorithm]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedKey, algorithm)
+	}
+	if len(hash) != sha256.Size {
+		return "", fmt.Errorf("%w: hash must be %d bytes", ErrAddressFormat, sha256.Size)
+	}
+
+	data := convertBits(append([]byte{version}, hash...), 8, 5, true)
+	prefix := GetAddressPrefix()
+	combined := append(data, bech32Checksum(prefix, data)...)
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	sb.WriteByte('1')
+	for _, v := range combined {
+		sb.WriteByte(bech32Charset[v])
+	}
+	return sb.String(), nil
+}
+
+// DecodeAddress checks an address's network prefix and checksum and returns its key
+// algorithm and public key hash
+func DecodeAddress(address string) (KeyAlgorithm, []byte, error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return "", nil, fmt.Errorf("%w: mixed case", ErrAddressFormat)
+	}
+	address = strings.ToLower(address)
+
+	separator := strings.LastIndexByte(address, '1')
+	if separator < 1 || separator+7 > len(address) || len(address) > 90 {
+		return "", nil, ErrAddressFormat
+	}
+
+	prefix, encoded := address[:separator], address[separator+1:]
+	data := make([]byte, len(encoded))
+	for i := range encoded {
+		index := strings.IndexByte(bech32Charset, encoded[i])
+		if index < 0 {
+			return "", nil, fmt.Errorf("%w: invalid character %q", ErrAddressFormat, encoded[i])
+		}
+		data[i] = byte(index)
+	}
+
+	if bech32Polymod(append(bech32HRPExpand(prefix), data...)) != 1 {
+		return "", nil, ErrAddressChecksum
+	}
+	if prefix != GetAddressPrefix() {
+		return "", nil, fmt.Errorf("%w: prefix %q, expected %q", ErrAddressNetwork, prefix, GetAddressPrefix())
+	}
+
+	payload := convertBits(data[:len(data)-6], 5, 8, false)
+	if payload == nil || len(payload) != 1+sha256.Size {
+		return "", nil, fmt.Errorf("%w: wrong payload length", ErrAddressFormat)
+	}
+
+	for algorithm, version := range addressVersions {
+		if version == payload[0] {
+			return algorithm, payload[1:], nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: unknown version %d", ErrAddressFormat, payload[0])
+}
+
+// IsLegacyWalletID reports whether id has the hex wallet ID format used before addresses
+func IsLegacyWalletID(id string) bool {
+	_, _, ok := parseLegacyWalletID(id)
+	return ok
+}
+
+// parseLegacyWalletID splits a hex wallet ID into its algorithm and hash
+func parseLegacyWalletID(id string) (KeyAlgorithm, []byte, bool) {
+	for algorithm, prefix := range legacyWalletIDPrefixes {
+		if len(id) == len(prefix)+64 && strings.HasPrefix(id, prefix) && hexHashRegex.MatchString(id[len(prefix):]) {
+			hash, _ := hex.DecodeString(id[len(prefix):])
+			return algorithm, hash, true
+		}
+	}
+	return "", nil, false
+}
+
+// ValidateWalletIDFormat checks a wallet ID offline. Addresses must have the right
+// network prefix and checksum; legacy hex IDs only have their shape checked.
+func ValidateWalletIDFormat(id string) error {
+	if IsLegacyWalletID(id) {
+		return nil
+	}
+	_, _, err := DecodeAddress(id)
+	return err
+}
+
+// WalletIDAlias returns the other form of a wallet ID: the address of a legacy hex ID,
+// or the legacy hex ID of an address. It returns "" for anything else.
+func WalletIDAlias(id string) string {
+	if algorithm, hash, ok := parseLegacyWalletID(id); ok {
+		address, err := EncodeAddress(algorithm, hash)
+		if err != nil {
+			return ""
+		}
+		return address
+	}
+
+	algorithm, hash, err := DecodeAddress(id)
+	if err != nil {
+		return ""
+	}
+	return legacyWalletIDPrefixes[algorithm] + hex.EncodeToString(hash)
+}
+
+// WalletIDAlgorithm returns the key algorithm encoded in an address or legacy wallet ID
+func WalletIDAlgorithm(walletID string) (KeyAlgorithm, error) {
+	if algorithm, _, ok := parseLegacyWalletID(walletID); ok {
+		return algorithm, nil
+	}
+	algorithm, _, err := DecodeAddress(walletID)
+	if err != nil {
+		return "", err
+	}
+	return algorithm, nil
+}
+
+// WalletIDKeyHash returns the SHA-256 of the public key an address or legacy wallet ID
+// encodes
+func WalletIDKeyHash(walletID string) ([]byte, error) {
+	if _, hash, ok := parseLegacyWalletID(walletID); ok {
+		return hash, nil
+	}
+	_, hash, err := DecodeAddress(walletID)
+	if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// bech32Polymod computes the BCH checksum over 5-bit values
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HRPExpand expands the prefix for checksum computation
+func bech32HRPExpand(prefix string) []byte {
+	expanded := make([]byte, 0, len(prefix)*2+1)
+	for i := 0; i < len(prefix); i++ {
+		expanded = append(expanded, prefix[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(prefix); i++ {
+		expanded = append(expanded, prefix[i]&31)
+	}
+	return expanded
+}
+
+// bech32Checksum returns the six 5-bit checksum values for prefix and data
+func bech32Checksum(prefix string, data []byte) []byte {
+	values := append(bech32HRPExpand(prefix), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ 1
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte((polymod >> uint(5*(5-i))) & 31)
+	}
+	return checksum
+}
+
+// convertBits regroups data from fromBits-wide to toBits-wide values. Without padding,
+// leftover bits must be zero; nil is returned if they are not.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) []byte {
+	var acc, bits uint
+	maxValue := uint(1)<<toBits - 1
+	var out []byte
+
+	for _, value := range data {
+		acc = acc<<fromBits | uint(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte((acc>>bits)&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			out = append(out, byte((acc<<(toBits-bits))&maxValue))
+		}
+	} else if bits >= fromBits || (acc<<(toBits-bits))&maxValue != 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testHash returns a fixed 32-byte public key hash
+func testHash(seed string) []byte {
+	hash := sha256.Sum256([]byte(seed))
+	return hash[:]
+}
+
+func TestAddressRoundTripsEveryAlgorithm(t *testing.T) {
+	hash := testHash("public key")
+	seen := make(map[string]KeyAlgorithm)
+
+	for algorithm, version := range addressVersions {
+		t.Run(string(algorithm), func(t *testing.T) {
+			address, err := EncodeAddress(algorithm, hash)
+			if err != nil {
+				t.Fatalf("EncodeAddress: %v", err)
+			}
+			if !strings.HasPrefix(address, "bcw1") {
+				t.Errorf("address %s does not start with bcw1", address)
+			}
+			if other, ok := seen[address]; ok {
+				t.Fatalf("%s and %s share address %s", algorithm, other, address)
+			}
+			seen[address] = algorithm
+
+			for _, form := range []string{address, strings.ToUpper(address)} {
+				decoded, decodedHash, err := DecodeAddress(form)
+				if err != nil {
+					t.Fatalf("DecodeAddress(%s): %v", form, err)
+				}
+				if decoded != algorithm || addressVersions[decoded] != version {
+					t.Errorf("DecodeAddress(%s) algorithm = %s, want %s (version %d)", form, decoded, algorithm, version)
+				}
+				if !bytes.Equal(decodedHash, hash) {
+					t.Errorf("DecodeAddress(%s) hash = %x, want %x", form, decodedHash, hash)
+				}
+			}
+
+			if got, err := WalletIDAlgorithm(address); err != nil || got != algorithm {
+				t.Errorf("WalletIDAlgorithm = %s, %v; want %s", got, err, algorithm)
+			}
+			if got, err := WalletIDKeyHash(address); err != nil || !bytes.Equal(got, hash) {
+				t.Errorf("WalletIDKeyHash = %x, %v; want %x", got, err, hash)
+			}
+		})
+	}
+}
+
+func TestEncodeAddressRejectsBadInput(t *testing.T) {
+	if _, err := EncodeAddress("dsa", testHash("key")); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("unknown algorithm err = %v, want ErrUnsupportedKey", err)
+	}
+	if _, err := EncodeAddress(AlgorithmEd25519, []byte{1, 2, 3}); !errors.Is(err, ErrAddressFormat) {
+		t.Errorf("short hash err = %v, want ErrAddressFormat", err)
+	}
+}
+
+func TestDecodeAddressRejectsTypos(t *testing.T) {
+	address, err := EncodeAddress(AlgorithmEd25519, testHash("typo"))
+	if err != nil {
+		t.Fatalf("EncodeAddress: %v", err)
+	}
+	data := strings.LastIndexByte(address, '1') + 1
+
+	t.Run("changed character", func(t *testing.T) {
+		for i := data; i < len(address); i++ {
+			for _, c := range bech32Charset {
+				if byte(c) == address[i] {
+					continue
+				}
+				typo := address[:i] + string(c) + address[i+1:]
+				if _, _, err := DecodeAddress(typo); !errors.Is(err, ErrAddressChecksum) {
+					t.Fatalf("DecodeAddress(%s) err = %v, want ErrAddressChecksum", typo, err)
+				}
+			}
+		}
+	})
+
+	t.Run("swapped pair", func(t *testing.T) {
+		for i := data; i < len(address)-1; i++ {
+			if address[i] == address[i+1] {
+				continue
+			}
+			swapped := address[:i] + string(address[i+1]) + string(address[i]) + address[i+2:]
+			if _, _, err := DecodeAddress(swapped); !errors.Is(err, ErrAddressChecksum) {
+				t.Fatalf("DecodeAddress(%s) err = %v, want ErrAddressChecksum", swapped, err)
+			}
+		}
+	})
+
+	tests := []struct {
+		name    string
+		address string
+		wantErr error
+	}{
+		{"mixed case", address[:data] + strings.ToUpper(address[data:]), ErrAddressFormat},
+		{"invalid character", address[:len(address)-1] + "b", ErrAddressFormat},
+		{"no separator", strings.Replace(address, "1", "", 1), ErrAddressFormat},
+		{"too short", address[:data+5], ErrAddressFormat},
+		{"changed prefix", "bcx" + address[3:], ErrAddressChecksum},
+		{"dropped character", address[:data] + address[data+1:], ErrAddressChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := DecodeAddress(tt.address); !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecodeAddress(%s) err = %v, want %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeAddressChecksNetwork(t *testing.T) {
+	t.Setenv("ADDRESS_PREFIX", "tbcw")
+	testnet, err := EncodeAddress(AlgorithmEd25519, testHash("network"))
+	if err != nil {
+		t.Fatalf("EncodeAddress: %v", err)
+	}
+	if !strings.HasPrefix(testnet, "tbcw1") {
+		t.Fatalf("address %s does not use ADDRESS_PREFIX", testnet)
+	}
+
+	t.Setenv("ADDRESS_PREFIX", "")
+	if _, _, err := DecodeAddress(testnet); !errors.Is(err, ErrAddressNetwork) {
+		t.Errorf("DecodeAddress(%s) err = %v, want ErrAddressNetwork", testnet, err)
+	}
+	if err := ValidateWalletIDFormat(testnet); !errors.Is(err, ErrAddressNetwork) {
+		t.Errorf("ValidateWalletIDFormat(%s) err = %v, want ErrAddressNetwork", testnet, err)
+	}
+}
+
+func TestWalletIDAliasResolvesLegacyIDs(t *testing.T) {
+	hash := testHash("legacy")
+
+	for algorithm, prefix := range legacyWalletIDPrefixes {
+		t.Run(string(algorithm), func(t *testing.T) {
+			legacy := prefix + hex.EncodeToString(hash)
+			address, err := EncodeAddress(algorithm, hash)
+			if err != nil {
+				t.Fatalf("EncodeAddress: %v", err)
+			}
+
+			if !IsLegacyWalletID(legacy) || IsLegacyWalletID(address) {
+				t.Errorf("IsLegacyWalletID(%s, %s) = %v, %v; want true, false", legacy, address, IsLegacyWalletID(legacy), IsLegacyWalletID(address))
+			}
+			if got := WalletIDAlias(legacy); got != address {
+				t.Errorf("WalletIDAlias(%s) = %s, want %s", legacy, got, address)
+			}
+			if got := WalletIDAlias(address); got != legacy {
+				t.Errorf("WalletIDAlias(%s) = %s, want %s", address, got, legacy)
+			}
+			if got, err := WalletIDAlgorithm(legacy); err != nil || got != algorithm {
+				t.Errorf("WalletIDAlgorithm(%s) = %s, %v; want %s", legacy, got, err, algorithm)
+			}
+			if err := ValidateWalletIDFormat(legacy); err != nil {
+				t.Errorf("ValidateWalletIDFormat(%s): %v", legacy, err)
+			}
+		})
+	}
+
+	for _, id := range []string{"", "not-a-wallet", "ed" + strings.Repeat("g", 64), strings.ToUpper(hex.EncodeToString(hash))} {
+		if got := WalletIDAlias(id); got != "" {
+			t.Errorf("WalletIDAlias(%q) = %s, want none", id, got)
+		}
+	}
+}
+
+func TestWalletIDsFromPublicKeyAreAliases(t *testing.T) {
+	for _, algorithm := range []KeyAlgorithm{AlgorithmRSA, AlgorithmEd25519, AlgorithmSecp256k1} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			_, publicKey, err := GenerateKeys(algorithm)
+			if err != nil {
+				t.Fatalf("GenerateKeys: %v", err)
+			}
+			address, err := WalletIDFromPublicKey(publicKey)
+			if err != nil {
+				t.Fatalf("WalletIDFromPublicKey: %v", err)
+			}
+			legacy, err := LegacyWalletIDFromPublicKey(publicKey)
+			if err != nil {
+				t.Fatalf("LegacyWalletIDFromPublicKey: %v", err)
+			}
+
+			if WalletIDAlias(legacy) != address || WalletIDAlias(address) != legacy {
+				t.Errorf("%s and %s are not aliases of each other", legacy, address)
+			}
+			if got, _ := WalletIDAlgorithm(address); got != algorithm {
+				t.Errorf("WalletIDAlgorithm = %s, want %s", got, algorithm)
+			}
+		})
+	}
+}
@@ -2,8 +2,6 @@ package crypto
 
 import (
 	"crypto"
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -13,8 +11,6 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
-	"os"
 )
 
 // GenerateKeyPair generates RSA public/private key pair
@@ -76,7 +72,8 @@ func StringToPublicKey(publicKeyStr string) (*rsa.PublicKey, error) {
 	return publicKey, nil
 }
 
-// GenerateWalletID generates a unique wallet ID from public key
+// GenerateWalletID generates the legacy hex wallet ID of an RSA public key. It matches
+// LegacyWalletIDFromPublicKey for RSA keys.
 func GenerateWalletID(publicKey *rsa.PublicKey) string {
 	publicKeyStr := PublicKeyToString(publicKey)
 	hash := sha256.Sum256([]byte(publicKeyStr))
@@ -103,76 +100,23 @@ func VerifySignature(data string, signature string, publicKey *rsa.PublicKey) er
 	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signatureBytes)
 }
 
-// EncryptPrivateKey encrypts private key using AES
+// EncryptPrivateKey encrypts private key using AES with the active keyring key
 func EncryptPrivateKey(privateKeyStr string) (string, error) {
-	aesKeyStr := os.Getenv("AES_ENCRYPTION_KEY")
-	aesKey, err := base64.StdEncoding.DecodeString(aesKeyStr)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode AES key: %v", err)
-	}
-
-	if len(aesKey) != 32 {
-		return "", fmt.Errorf("AES key must be 32 bytes, got %d bytes", len(aesKey))
-	}
-
-	block, err := aes.NewCipher(aesKey)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
+	keyring, err := LoadKeyring()
 	if err != nil {
 		return "", err
 	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-
-	ciphertext := gcm.Seal(nonce, nonce, []byte(privateKeyStr), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return keyring.Encrypt(privateKeyStr)
 }
 
-// DecryptPrivateKey decrypts private key using AES
+// DecryptPrivateKey decrypts private key using AES with the key version it was
+// encrypted under
 func DecryptPrivateKey(encryptedKey string) (string, error) {
-	aesKeyStr := os.Getenv("AES_ENCRYPTION_KEY")
-	aesKey, err := base64.StdEncoding.DecodeString(aesKeyStr)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode AES key: %v", err)
-	}
-
-	if len(aesKey) != 32 {
-		return "", fmt.Errorf("AES key must be 32 bytes, got %d bytes", len(aesKey))
-	}
-
-	ciphertext, err := base64.StdEncoding.DecodeString(encryptedKey)
-	if err != nil {
-		return "", err
-	}
-
-	block, err := aes.NewCipher(aesKey)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", errors.New("ciphertext too short")
-	}
-
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	keyring, err := LoadKeyring()
 	if err != nil {
 		return "", err
 	}
-
-	return string(plaintext), nil
+	return keyring.Decrypt(encryptedKey)
 }
 
 // HashSHA256 computes SHA-256 hash of data
@@ -181,7 +125,8 @@ func HashSHA256(data string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// CreateTransactionPayload creates the payload to be signed for a transaction
-func CreateTransactionPayload(senderID, receiverID string, amount float64, timestamp, note string) string {
-	return fmt.Sprintf("%s%s%.8f%s%s", senderID, receiverID, amount, timestamp, note)
+// CreateTransactionPayload creates the payload signed by legacy transactions.
+// amount is the BC value formatted with 8 decimal places.
+func CreateTransactionPayload(senderID, receiverID, amount, timestamp, note string) string {
+	return fmt.Sprintf("%s%s%s%s%s", senderID, receiverID, amount, timestamp, note)
 }
@@ -0,0 +1,244 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Hierarchical deterministic (HD) wallets derive secp256k1 keys from one seed as in
+// BIP-32, and the seed is backed up as a BIP-39 mnemonic. Addresses follow the BIP-44
+// layout m/44'/coin'/account'/0/index: accounts are hardened so the server can hold an
+// account's extended public key and derive receiving addresses without any private key.
+
+// HDPurpose and HDCoinType are the first two levels of every derivation path. The coin
+// type is not registered in SLIP-44; it only keeps these keys apart from other coins'.
+const (
+	HDPurpose  uint32 = 44
+	HDCoinType uint32 = 7777
+)
+
+// HardenedKeyStart is the first hardened child index
+const HardenedKeyStart uint32 = 0x80000000
+
+// mnemonicEntropyBits gives 24-word mnemonics
+const mnemonicEntropyBits = 256
+
+// Errors returned by HD key derivation
+var (
+	ErrInvalidMnemonic    = errors.New("invalid mnemonic")
+	ErrInvalidExtendedKey = errors.New("invalid extended key")
+	ErrHardenedFromPublic = errors.New("cannot derive a hardened child from a public key")
+	ErrInvalidChild       = errors.New("derived key is invalid, use the next index")
+)
+
+// ExtendedKey is a BIP-32 key with its chain code. Private keys hold the 32-byte scalar
+// and public keys the 33-byte compressed point.
+type ExtendedKey struct {
+	key       []byte
+	chainCode []byte
+	private   bool
+}
+
+// NewMnemonic generates a 24-word BIP-39 mnemonic
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// MnemonicToSeed checks a mnemonic's words and checksum and returns its BIP-39 seed
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	return bip39.NewSeed(mnemonic, passphrase), nil
+}
+
+// NewMasterKey derives the master private key of a seed
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("%w: seed must be 16 to 64 bytes", ErrInvalidExtendedKey)
+	}
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	var scalar secp256k1.ModNScalar
+	if overflow := scalar.SetByteSlice(sum[:32]); overflow || scalar.IsZero() {
+		return nil, ErrInvalidChild
+	}
+	return &ExtendedKey{key: sum[:32], chainCode: sum[32:], private: true}, nil
+}
+
+// ParseExtendedPublicKey decodes a key written by ExtendedKey.String
+func ParseExtendedPublicKey(encoded string) (*ExtendedKey, error) {
+	data, err := hex.DecodeString(encoded)
+	if err != nil || len(data) != 33+32 {
+		return nil, ErrInvalidExtendedKey
+	}
+	if _, err := secp256k1.ParsePubKey(data[:33]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExtendedKey, err)
+	}
+	return &ExtendedKey{key: data[:33], chainCode: data[33:]}, nil
+}
+
+// IsPrivate reports whether the key can derive hardened children and sign
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.private
+}
+
+// String encodes a public extended key as hex of the compressed point followed by the
+// chain code. Private keys are never encoded.
+func (k *ExtendedKey) String() string {
+	public := k.Public()
+	return hex.EncodeToString(append(append([]byte{}, public.key...), public.chainCode...))
+}
+
+// Public returns the public extended key, which derives the same non-hardened
+// public children as k
+func (k *ExtendedKey) Public() *ExtendedKey {
+	if !k.private {
+		return k
+	}
+	return &ExtendedKey{key: k.publicKeyBytes(), chainCode: k.chainCode}
+}
+
+// Child derives the child key at index. Indexes from HardenedKeyStart up are hardened
+// and need a private parent.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	hardened := index >= HardenedKeyStart
+	if hardened && !k.private {
+		return nil, ErrHardenedFromPublic
+	}
+
+	data := make([]byte, 0, 37)
+	if hardened {
+		data = append(data, 0)
+		data = append(data, k.key...)
+	} else {
+		data = append(data, k.publicKeyBytes()...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	var tweak secp256k1.ModNScalar
+	if overflow := tweak.SetByteSlice(sum[:32]); overflow {
+		return nil, ErrInvalidChild
+	}
+
+	if k.private {
+		var parent secp256k1.ModNScalar
+		parent.SetByteSlice(k.key)
+		tweak.Add(&parent)
+		if tweak.IsZero() {
+			return nil, ErrInvalidChild
+		}
+		childKey := tweak.Bytes()
+		return &ExtendedKey{key: childKey[:], chainCode: sum[32:], private: true}, nil
+	}
+
+	parentKey, err := secp256k1.ParsePubKey(k.key)
+	if err != nil {
+		return nil, err
+	}
+	var tweakPoint, parentPoint, childPoint secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&tweak, &tweakPoint)
+	parentKey.AsJacobian(&parentPoint)
+	secp256k1.AddNonConst(&tweakPoint, &parentPoint, &childPoint)
+	if (childPoint.X.IsZero() && childPoint.Y.IsZero()) || childPoint.Z.IsZero() {
+		return nil, ErrInvalidChild
+	}
+	childPoint.ToAffine()
+	childKey := secp256k1.NewPublicKey(&childPoint.X, &childPoint.Y)
+	return &ExtendedKey{key: childKey.SerializeCompressed(), chainCode: sum[32:]}, nil
+}
+
+// DerivePath derives a descendant from a path such as "m/44'/7777'/0'/0/1". Path
+// components ending in ' or h are hardened; a leading "m" is optional.
+func (k *ExtendedKey) DerivePath(path string) (*ExtendedKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, index := range indexes {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// PublicKeyPEM returns the key's public key in the PEM form wallets store
+func (k *ExtendedKey) PublicKeyPEM() string {
+	return encodePEM(secp256k1PublicKeyType, k.publicKeyBytes())
+}
+
+// PrivateKeyPEM returns the key's private key in the PEM form SignHash accepts
+func (k *ExtendedKey) PrivateKeyPEM() (string, error) {
+	if !k.private {
+		return "", errors.New("extended key has no private key")
+	}
+	return encodePEM(secp256k1PrivateKeyType, k.key), nil
+}
+
+// publicKeyBytes returns the compressed public key
+func (k *ExtendedKey) publicKeyBytes() []byte {
+	if !k.private {
+		return k.key
+	}
+	return secp256k1.PrivKeyFromBytes(k.key).PubKey().SerializeCompressed()
+}
+
+// ParseDerivationPath converts a path such as "m/44'/7777'/0'" into child indexes
+func ParseDerivationPath(path string) ([]uint32, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "m")
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil, nil
+	}
+
+	var indexes []uint32
+	for _, component := range strings.Split(path, "/") {
+		hardened := strings.HasSuffix(component, "'") || strings.HasSuffix(component, "h")
+		if hardened {
+			component = component[:len(component)-1]
+		}
+		index, err := strconv.ParseUint(component, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path component %q", component)
+		}
+		if hardened {
+			index += uint64(HardenedKeyStart)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// HDAccountPath returns the path of an account's extended key, m/44'/coin'/account'
+func HDAccountPath(account uint32) string {
+	return fmt.Sprintf("m/%d'/%d'/%d'", HDPurpose, HDCoinType, account)
+}
+
+// HDAddressPath returns the path of an account's receiving key at index
+func HDAddressPath(account, index uint32) string {
+	return fmt.Sprintf("%s/0/%d", HDAccountPath(account), index)
+}
@@ -0,0 +1,220 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Private keys are encrypted with AES-256-GCM under a versioned master key. The
+// ciphertext is an envelope "enc:v<version>:<base64 nonce+ciphertext>" that names the key
+// it was sealed with, and the envelope header is authenticated as GCM additional data so
+// the version cannot be swapped. Ciphertexts from before envelopes are bare base64 and
+// were sealed with AES_ENCRYPTION_KEY.
+//
+// The keyring is read from the environment:
+//
+//	AES_ENCRYPTION_KEY          the original key, version 1
+//	AES_ENCRYPTION_KEYS         further keys as "version:base64key" pairs, comma separated
+//	AES_ENCRYPTION_KEY_VERSION  the version new ciphertexts use; defaults to the highest
+//
+// Rotating the key means adding a new version, making it active, re-encrypting stored
+// keys (the reencrypt-keys command) and only then removing the old version.
+
+const envelopePrefix = "enc:v"
+
+// legacyKeyVersion is the version AES_ENCRYPTION_KEY is loaded as
+const legacyKeyVersion uint32 = 1
+
+// Errors returned by the keyring
+var (
+	ErrNoEncryptionKey   = errors.New("no AES encryption key configured")
+	ErrUnknownKeyVersion = errors.New("ciphertext uses a key version that is not in the keyring")
+)
+
+// Keyring holds the AES master keys by version
+type Keyring struct {
+	keys   map[uint32][]byte
+	active uint32
+}
+
+// LoadKeyring reads the keyring from the environment
+func LoadKeyring() (*Keyring, error) {
+	keyring := &Keyring{keys: make(map[uint32][]byte)}
+
+	if encoded := os.Getenv("AES_ENCRYPTION_KEY"); encoded != "" {
+		if err := keyring.add(legacyKeyVersion, encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	if entries := os.Getenv("AES_ENCRYPTION_KEYS"); entries != "" {
+		for _, entry := range strings.Split(entries, ",") {
+			versionStr, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok {
+				return nil, fmt.Errorf("AES_ENCRYPTION_KEYS entry must be version:key")
+			}
+			version, err := strconv.ParseUint(versionStr, 10, 32)
+			if err != nil || version == 0 {
+				return nil, fmt.Errorf("invalid AES key version %q", versionStr)
+			}
+			if _, exists := keyring.keys[uint32(version)]; exists {
+				return nil, fmt.Errorf("AES key version %d is configured twice", version)
+			}
+			if err := keyring.add(uint32(version), encoded); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(keyring.keys) == 0 {
+		return nil, ErrNoEncryptionKey
+	}
+
+	if activeStr := os.Getenv("AES_ENCRYPTION_KEY_VERSION"); activeStr != "" {
+		active, err := strconv.ParseUint(activeStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AES_ENCRYPTION_KEY_VERSION %q", activeStr)
+		}
+		if _, ok := keyring.keys[uint32(active)]; !ok {
+			return nil, fmt.Errorf("AES_ENCRYPTION_KEY_VERSION %d is not in the keyring", active)
+		}
+		keyring.active = uint32(active)
+	} else {
+		for _, version := range keyring.Versions() {
+			keyring.active = version
+		}
+	}
+
+	return keyring, nil
+}
+
+// add decodes a base64 key and stores it under version
+func (k *Keyring) add(version uint32, encoded string) error {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("failed to decode AES key version %d: %v", version, err)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("AES key version %d must be 32 bytes, got %d bytes", version, len(key))
+	}
+	k.keys[version] = key
+	return nil
+}
+
+// ActiveVersion returns the key version new ciphertexts are sealed with
+func (k *Keyring) ActiveVersion() uint32 {
+	return k.active
+}
+
+// Versions lists the configured key versions in ascending order
+func (k *Keyring) Versions() []uint32 {
+	versions := make([]uint32, 0, len(k.keys))
+	for version := range k.keys {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// Encrypt seals plaintext with the active key and returns the envelope
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	header := envelopeHeader(k.active)
+	gcm, err := newGCM(k.keys[k.active])
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(header))
+	return header + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt opens an envelope with the key it names. Legacy ciphertexts are opened with
+// the original key.
+func (k *Keyring) Decrypt(encrypted string) (string, error) {
+	version, isEnvelope := EnvelopeKeyVersion(encrypted)
+	header := ""
+	encoded := encrypted
+	if isEnvelope {
+		header = envelopeHeader(version)
+		encoded = strings.TrimPrefix(encrypted, header)
+	} else {
+		version = legacyKeyVersion
+	}
+
+	key, ok := k.keys[version]
+	if !ok {
+		return "", fmt.Errorf("%w: version %d", ErrUnknownKeyVersion, version)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return "", errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	var additionalData []byte
+	if isEnvelope {
+		additionalData = []byte(header)
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// EnvelopeKeyVersion returns the key version named by an envelope. It reports false for
+// legacy ciphertexts, which carry no version.
+func EnvelopeKeyVersion(encrypted string) (uint32, bool) {
+	if !strings.HasPrefix(encrypted, envelopePrefix) {
+		return 0, false
+	}
+	versionStr, _, ok := strings.Cut(strings.TrimPrefix(encrypted, envelopePrefix), ":")
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(versionStr, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(version), true
+}
+
+// envelopeHeader returns the prefix of an envelope sealed with version
+func envelopeHeader(version uint32) string {
+	return envelopePrefix + strconv.FormatUint(uint64(version), 10) + ":"
+}
+
+// newGCM returns AES-GCM for a 32-byte key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
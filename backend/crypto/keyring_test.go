@@ -0,0 +1,191 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testKey returns a base64 AES-256 key filled with b
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// setKeyring configures the keyring environment for a test
+func setKeyring(t *testing.T, legacyKey, keys, active string) *Keyring {
+	t.Helper()
+
+	t.Setenv("AES_ENCRYPTION_KEY", legacyKey)
+	t.Setenv("AES_ENCRYPTION_KEYS", keys)
+	t.Setenv("AES_ENCRYPTION_KEY_VERSION", active)
+	keyring, err := LoadKeyring()
+	if err != nil {
+		t.Fatalf("LoadKeyring: %v", err)
+	}
+	return keyring
+}
+
+func TestKeyringEnvelopeFormat(t *testing.T) {
+	keyring := setKeyring(t, testKey(1), "2:"+testKey(2), "")
+
+	if keyring.ActiveVersion() != 2 {
+		t.Fatalf("active version = %d, want the highest, 2", keyring.ActiveVersion())
+	}
+
+	encrypted, err := keyring.Encrypt("private key")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, "enc:v2:") {
+		t.Fatalf("envelope %q does not start with enc:v2:", encrypted)
+	}
+	if _, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, "enc:v2:")); err != nil {
+		t.Errorf("envelope body is not base64: %v", err)
+	}
+	if version, ok := EnvelopeKeyVersion(encrypted); !ok || version != 2 {
+		t.Errorf("EnvelopeKeyVersion = %d, %v; want 2, true", version, ok)
+	}
+
+	again, err := keyring.Encrypt("private key")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if again == encrypted {
+		t.Error("two encryptions of the same key are identical; nonce not random")
+	}
+
+	plaintext, err := keyring.Decrypt(encrypted)
+	if err != nil || plaintext != "private key" {
+		t.Errorf("Decrypt = %q, %v; want private key", plaintext, err)
+	}
+
+	for _, legacy := range []string{"", "c29tZSBiYXNlNjQ=", "enc:vx:abc", "enc:v2"} {
+		if _, ok := EnvelopeKeyVersion(legacy); ok {
+			t.Errorf("EnvelopeKeyVersion(%q) reported an envelope", legacy)
+		}
+	}
+}
+
+func TestKeyringDecryptsWithNamedVersion(t *testing.T) {
+	keys := "2:" + testKey(2) + ", 3:" + testKey(3)
+
+	// Seal one key under each version, as successive rotations would have
+	sealed := make(map[uint32]string)
+	for _, active := range []string{"1", "2", "3"} {
+		keyring := setKeyring(t, testKey(1), keys, active)
+		encrypted, err := keyring.Encrypt(fmt.Sprintf("key sealed under v%d", keyring.ActiveVersion()))
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		sealed[keyring.ActiveVersion()] = encrypted
+	}
+
+	keyring := setKeyring(t, testKey(1), keys, "")
+	for version, encrypted := range sealed {
+		plaintext, err := keyring.Decrypt(encrypted)
+		if err != nil {
+			t.Errorf("Decrypt(v%d): %v", version, err)
+			continue
+		}
+		if want := fmt.Sprintf("key sealed under v%d", version); plaintext != want {
+			t.Errorf("Decrypt(v%d) = %q, want %q", version, plaintext, want)
+		}
+	}
+
+	// Ciphertexts from before envelopes are bare base64 sealed with AES_ENCRYPTION_KEY
+	gcm, err := newGCM(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("newGCM: %v", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	legacy := base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte("legacy key"), nil))
+	if plaintext, err := keyring.Decrypt(legacy); err != nil || plaintext != "legacy key" {
+		t.Errorf("Decrypt(legacy) = %q, %v; want legacy key", plaintext, err)
+	}
+
+	// A keyring that dropped version 2 cannot open what it sealed
+	withoutV2 := setKeyring(t, testKey(1), "3:"+testKey(3), "")
+	if _, err := withoutV2.Decrypt(sealed[2]); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Decrypt(v2) without version 2 err = %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestKeyringRejectsTamperedHeader(t *testing.T) {
+	// Versions 2 and 3 share a key, so only the authenticated header tells them apart
+	keyring := setKeyring(t, testKey(2), "2:"+testKey(2)+",3:"+testKey(2), "2")
+
+	encrypted, err := keyring.Encrypt("private key")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	body := strings.TrimPrefix(encrypted, "enc:v2:")
+
+	tests := []struct {
+		name      string
+		encrypted string
+	}{
+		{"relabelled version", "enc:v3:" + body},
+		{"stripped header", body},
+		{"flipped ciphertext byte", "enc:v2:" + flipLastByte(t, body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if plaintext, err := keyring.Decrypt(tt.encrypted); err == nil {
+				t.Errorf("Decrypt(%s) = %q, want an authentication error", tt.name, plaintext)
+			}
+		})
+	}
+}
+
+func TestLoadKeyringRejectsBadConfiguration(t *testing.T) {
+	tests := []struct {
+		name      string
+		legacyKey string
+		keys      string
+		active    string
+		wantErr   error
+	}{
+		{"no keys", "", "", "", ErrNoEncryptionKey},
+		{"short key", base64.StdEncoding.EncodeToString([]byte("short")), "", "", nil},
+		{"entry without version", "", testKey(2), "", nil},
+		{"version zero", "", "0:" + testKey(2), "", nil},
+		{"version configured twice", testKey(1), "1:" + testKey(2), "", nil},
+		{"active version missing", testKey(1), "2:" + testKey(2), "3", nil},
+		{"active version not a number", testKey(1), "", "latest", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AES_ENCRYPTION_KEY", tt.legacyKey)
+			t.Setenv("AES_ENCRYPTION_KEYS", tt.keys)
+			t.Setenv("AES_ENCRYPTION_KEY_VERSION", tt.active)
+
+			_, err := LoadKeyring()
+			if err == nil {
+				t.Fatal("LoadKeyring succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// flipLastByte changes the last byte of a base64 ciphertext
+func flipLastByte(t *testing.T, encoded string) string {
+	t.Helper()
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	data[len(data)-1] ^= 1
+	return base64.StdEncoding.EncodeToString(data)
+}
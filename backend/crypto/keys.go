@@ -0,0 +1,296 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// KeyAlgorithm names a signature scheme a wallet key can use
+type KeyAlgorithm string
+
+// Supported key algorithms
+const (
+	AlgorithmRSA       KeyAlgorithm = "rsa"
+	AlgorithmEd25519   KeyAlgorithm = "ed25519"
+	AlgorithmSecp256k1 KeyAlgorithm = "secp256k1"
+)
+
+// PEM block types for secp256k1 keys, which x509 does not support. The private key is
+// the raw 32-byte scalar and the public key is the 33-byte compressed point.
+const (
+	secp256k1PrivateKeyType = "SECP256K1 PRIVATE KEY"
+	secp256k1PublicKeyType  = "SECP256K1 PUBLIC KEY"
+)
+
+// ErrUnsupportedKey is returned for keys of an unknown type or algorithm
+var ErrUnsupportedKey = errors.New("unsupported key algorithm")
+
+// KeyScheme generates keys and signs SHA-256 digests for one algorithm. Keys are
+// exchanged as PEM strings so they can be stored and sent like the original RSA keys.
+type KeyScheme interface {
+	Algorithm() KeyAlgorithm
+	GenerateKey() (privateKeyPEM, publicKeyPEM string, err error)
+	Sign(digest []byte, privateKeyPEM string) ([]byte, error)
+	Verify(digest, signature []byte, publicKeyPEM string) error
+}
+
+var keySchemes = map[KeyAlgorithm]KeyScheme{
+	AlgorithmRSA:       rsaScheme{},
+	AlgorithmEd25519:   ed25519Scheme{},
+	AlgorithmSecp256k1: secp256k1Scheme{},
+}
+
+// GetKeyScheme returns the scheme for an algorithm
+func GetKeyScheme(algorithm KeyAlgorithm) (KeyScheme, error) {
+	scheme, ok := keySchemes[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKey, algorithm)
+	}
+	return scheme, nil
+}
+
+// SupportedKeyAlgorithms lists the algorithms wallets can be created with
+func SupportedKeyAlgorithms() []KeyAlgorithm {
+	return []KeyAlgorithm{AlgorithmRSA, AlgorithmEd25519, AlgorithmSecp256k1}
+}
+
+// GenerateKeys creates a PEM key pair for algorithm
+func GenerateKeys(algorithm KeyAlgorithm) (privateKeyPEM, publicKeyPEM string, err error) {
+	scheme, err := GetKeyScheme(algorithm)
+	if err != nil {
+		return "", "", err
+	}
+	return scheme.GenerateKey()
+}
+
+// DetectKeyAlgorithm returns the algorithm of a PEM public or private key
+func DetectKeyAlgorithm(keyPEM string) (KeyAlgorithm, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return "", errors.New("failed to decode PEM block")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return AlgorithmRSA, nil
+	case secp256k1PrivateKeyType, secp256k1PublicKeyType:
+		return AlgorithmSecp256k1, nil
+	case multisigPolicyType:
+		return AlgorithmMultisig, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return "", err
+		}
+		return algorithmOf(key)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return "", err
+		}
+		return algorithmOf(key)
+	}
+	return "", fmt.Errorf("%w: PEM type %q", ErrUnsupportedKey, block.Type)
+}
+
+// algorithmOf maps a parsed x509 key to its algorithm
+func algorithmOf(key interface{}) (KeyAlgorithm, error) {
+	switch key.(type) {
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return AlgorithmRSA, nil
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return AlgorithmEd25519, nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+}
+
+// SignHash signs a hex SHA-256 digest with a PEM private key of any supported
+// algorithm and returns the base64 signature. For RSA this is the same signature
+// SignData produces over the data that was hashed.
+func SignHash(hash string, privateKeyPEM string) (string, error) {
+	digest, err := hex.DecodeString(hash)
+	if err != nil || len(digest) != sha256.Size {
+		return "", errors.New("hash must be a hex SHA-256 digest")
+	}
+
+	algorithm, err := DetectKeyAlgorithm(privateKeyPEM)
+	if err != nil {
+		return "", err
+	}
+	scheme, err := GetKeyScheme(algorithm)
+	if err != nil {
+		return "", err
+	}
+	signature, err := scheme.Sign(digest, privateKeyPEM)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyHashSignature verifies a base64 signature over a hex SHA-256 digest with a PEM
+// public key of any supported algorithm
+func VerifyHashSignature(hash string, signature string, publicKeyPEM string) error {
+	digest, err := hex.DecodeString(hash)
+	if err != nil || len(digest) != sha256.Size {
+		return errors.New("hash must be a hex SHA-256 digest")
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+
+	algorithm, err := DetectKeyAlgorithm(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+	scheme, err := GetKeyScheme(algorithm)
+	if err != nil {
+		return err
+	}
+	return scheme.Verify(digest, signatureBytes, publicKeyPEM)
+}
+
+// rsaScheme is 2048-bit RSA with PKCS#1 v1.5 signatures
+type rsaScheme struct{}
+
+func (rsaScheme) Algorithm() KeyAlgorithm { return AlgorithmRSA }
+
+func (rsaScheme) GenerateKey() (string, string, error) {
+	privateKey, publicKey, err := GenerateKeyPair()
+	if err != nil {
+		return "", "", err
+	}
+	return PrivateKeyToString(privateKey), PublicKeyToString(publicKey), nil
+}
+
+func (rsaScheme) Sign(digest []byte, privateKeyPEM string) ([]byte, error) {
+	privateKey, err := StringToPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest)
+}
+
+func (rsaScheme) Verify(digest, signature []byte, publicKeyPEM string) error {
+	publicKey, err := StringToPublicKey(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature)
+}
+
+// ed25519Scheme signs the digest as an Ed25519 message. Keys use PKCS#8 and PKIX PEM.
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) Algorithm() KeyAlgorithm { return AlgorithmEd25519 }
+
+func (ed25519Scheme) GenerateKey() (string, string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+	publicBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", "", err
+	}
+	return encodePEM("PRIVATE KEY", privateBytes), encodePEM("PUBLIC KEY", publicBytes), nil
+}
+
+func (ed25519Scheme) Sign(digest []byte, privateKeyPEM string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 private key")
+	}
+	return ed25519.Sign(privateKey, digest), nil
+}
+
+func (ed25519Scheme) Verify(digest, signature []byte, publicKeyPEM string) error {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return errors.New("failed to decode PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return errors.New("not an Ed25519 public key")
+	}
+	if !ed25519.Verify(publicKey, digest, signature) {
+		return errors.New("ed25519: verification error")
+	}
+	return nil
+}
+
+// secp256k1Scheme is ECDSA over secp256k1 with DER-encoded, low-S signatures
+type secp256k1Scheme struct{}
+
+func (secp256k1Scheme) Algorithm() KeyAlgorithm { return AlgorithmSecp256k1 }
+
+func (secp256k1Scheme) GenerateKey() (string, string, error) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return "", "", err
+	}
+	return encodePEM(secp256k1PrivateKeyType, privateKey.Serialize()),
+		encodePEM(secp256k1PublicKeyType, privateKey.PubKey().SerializeCompressed()), nil
+}
+
+func (secp256k1Scheme) Sign(digest []byte, privateKeyPEM string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil || block.Type != secp256k1PrivateKeyType || len(block.Bytes) != 32 {
+		return nil, errors.New("not a secp256k1 private key")
+	}
+	privateKey := secp256k1.PrivKeyFromBytes(block.Bytes)
+	return ecdsa.Sign(privateKey, digest).Serialize(), nil
+}
+
+func (secp256k1Scheme) Verify(digest, signature []byte, publicKeyPEM string) error {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil || block.Type != secp256k1PublicKeyType {
+		return errors.New("not a secp256k1 public key")
+	}
+	publicKey, err := secp256k1.ParsePubKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	parsed, err := ecdsa.ParseDERSignature(signature)
+	if err != nil {
+		return err
+	}
+	if !parsed.Verify(digest, publicKey) {
+		return errors.New("secp256k1: verification error")
+	}
+	return nil
+}
+
+// encodePEM wraps DER or raw key bytes in a PEM block
+func encodePEM(blockType string, data []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}))
+}
@@ -0,0 +1,156 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A multisig wallet is controlled by N public keys of which any M must sign. Its policy
+// (the threshold and the sorted keys) is encoded as a PEM block and used as the wallet's
+// public key, so the wallet ID is derived from the policy like any other address and
+// a transaction's SenderPublicKey commits to the policy that authorises it.
+
+// AlgorithmMultisig marks a multisig policy in place of a single public key
+const AlgorithmMultisig KeyAlgorithm = "multisig"
+
+// multisigPolicyType is the PEM block type of an encoded multisig policy
+const multisigPolicyType = "MULTISIG POLICY"
+
+// MaxMultisigKeys limits the number of co-signers in a policy
+const MaxMultisigKeys = 15
+
+// ErrThresholdNotMet is returned when fewer valid signatures than the threshold are given
+var ErrThresholdNotMet = errors.New("not enough valid signatures to meet the multisig threshold")
+
+// MultisigPolicy is an M-of-N signing policy. PublicKeys are PEM strings in sorted order.
+type MultisigPolicy struct {
+	Threshold  int
+	PublicKeys []string
+}
+
+// NewMultisigPolicy validates the keys and threshold of a policy and puts the keys in
+// canonical order, so the same set of keys always gives the same wallet ID
+func NewMultisigPolicy(threshold int, publicKeys []string) (*MultisigPolicy, error) {
+	if len(publicKeys) < 2 || len(publicKeys) > MaxMultisigKeys {
+		return nil, fmt.Errorf("a multisig policy needs 2 to %d public keys", MaxMultisigKeys)
+	}
+	if threshold < 1 || threshold > len(publicKeys) {
+		return nil, fmt.Errorf("threshold must be between 1 and %d", len(publicKeys))
+	}
+
+	keys := make([]string, 0, len(publicKeys))
+	seen := make(map[string]bool, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		canonical, err := CanonicalPublicKeyPEM(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		if seen[canonical] {
+			return nil, errors.New("multisig public keys must be distinct")
+		}
+		seen[canonical] = true
+		keys = append(keys, canonical)
+	}
+	sort.Strings(keys)
+
+	return &MultisigPolicy{Threshold: threshold, PublicKeys: keys}, nil
+}
+
+// ParseMultisigPolicy decodes a policy written by MultisigPolicy.PEM
+func ParseMultisigPolicy(policyPEM string) (*MultisigPolicy, error) {
+	block, _ := pem.Decode([]byte(policyPEM))
+	if block == nil || block.Type != multisigPolicyType || len(block.Bytes) < 2 {
+		return nil, errors.New("not a multisig policy")
+	}
+
+	data := block.Bytes
+	threshold, count := int(data[0]), int(data[1])
+	data = data[2:]
+
+	keys := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < 2 {
+			return nil, errors.New("truncated multisig policy")
+		}
+		length := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+length {
+			return nil, errors.New("truncated multisig policy")
+		}
+		keys = append(keys, string(data[2:2+length]))
+		data = data[2+length:]
+	}
+	if len(data) != 0 {
+		return nil, errors.New("trailing data in multisig policy")
+	}
+
+	policy, err := NewMultisigPolicy(threshold, keys)
+	if err != nil {
+		return nil, err
+	}
+	if policy.PEM() != policyPEM {
+		return nil, errors.New("multisig policy is not in canonical form")
+	}
+	return policy, nil
+}
+
+// PEM encodes the policy: threshold, key count, then each key prefixed with its length
+func (p *MultisigPolicy) PEM() string {
+	data := []byte{byte(p.Threshold), byte(len(p.PublicKeys))}
+	for _, publicKey := range p.PublicKeys {
+		data = binary.BigEndian.AppendUint16(data, uint16(len(publicKey)))
+		data = append(data, publicKey...)
+	}
+	return encodePEM(multisigPolicyType, data)
+}
+
+// KeyIndex returns the position of a public key in the policy, or -1
+func (p *MultisigPolicy) KeyIndex(publicKey string) int {
+	canonical, err := CanonicalPublicKeyPEM(publicKey)
+	if err != nil {
+		return -1
+	}
+	for i, key := range p.PublicKeys {
+		if key == canonical {
+			return i
+		}
+	}
+	return -1
+}
+
+// VerifyThreshold checks base64 signatures over a hex SHA-256 digest, keyed by the
+// index of the signing key in the policy. Each key counts once, and every signature
+// given must be valid.
+func (p *MultisigPolicy) VerifyThreshold(hash string, signatures map[int]string) error {
+	for index, signature := range signatures {
+		if index < 0 || index >= len(p.PublicKeys) {
+			return fmt.Errorf("signature for unknown key index %d", index)
+		}
+		if err := VerifyHashSignature(hash, signature, p.PublicKeys[index]); err != nil {
+			return fmt.Errorf("invalid signature from key %d: %v", index, err)
+		}
+	}
+	if len(signatures) < p.Threshold {
+		return fmt.Errorf("%w: have %d of %d", ErrThresholdNotMet, len(signatures), p.Threshold)
+	}
+	return nil
+}
+
+// CanonicalPublicKeyPEM re-encodes a single-key public key PEM so formatting differences
+// do not change a policy
+func CanonicalPublicKeyPEM(publicKey string) (string, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return "", errors.New("failed to decode PEM block")
+	}
+	algorithm, err := DetectKeyAlgorithm(publicKey)
+	if err != nil {
+		return "", err
+	}
+	if algorithm == AlgorithmMultisig || (block.Type != "PUBLIC KEY" && block.Type != secp256k1PublicKeyType) {
+		return "", fmt.Errorf("%w: multisig keys must be single public keys", ErrUnsupportedKey)
+	}
+	return encodePEM(block.Type, block.Bytes), nil
+}
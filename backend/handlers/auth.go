@@ -19,6 +19,8 @@ type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	CNIC     string `json:"cnic" binding:"required,len=15"`
 	Password string `json:"password" binding:"required,min=6,max=100"`
+	// KeyAlgorithm is "rsa", "ed25519" or "secp256k1"; defaults to KEY_ALGORITHM
+	KeyAlgorithm string `json:"keyAlgorithm"`
 }
 
 // RegisterResponse represents registration response
@@ -43,6 +45,16 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// Validate key algorithm
+	keyAlgorithm := services.GetDefaultKeyAlgorithm()
+	if req.KeyAlgorithm != "" {
+		keyAlgorithm = crypto.KeyAlgorithm(req.KeyAlgorithm)
+	}
+	if _, err := crypto.GetKeyScheme(keyAlgorithm); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Validate password strength
 	if err := services.ValidatePassword(req.Password); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -72,7 +84,7 @@ func Register(c *gin.Context) {
 	}
 
 	// Create user and wallet in our system
-	user, privateKey, err := services.RegisterUser(req.FullName, req.Email, req.CNIC, string(hashedPassword))
+	user, privateKey, err := services.RegisterUserWithKeyAlgorithm(req.FullName, req.Email, req.CNIC, string(hashedPassword), keyAlgorithm)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -109,11 +121,12 @@ type LoginRequest struct {
 }
 
 // LoginResponse represents login response
+// The private key is never returned; clients keep the key shown at registration and
+// sign transactions locally.
 type LoginResponse struct {
-	User       interface{} `json:"user"`
-	Token      string      `json:"token"`
-	PrivateKey string      `json:"privateKey"`
-	Message    string      `json:"message"`
+	User    interface{} `json:"user"`
+	Token   string      `json:"token"`
+	Message string      `json:"message"`
 }
 
 // Login handles user login
@@ -150,22 +163,13 @@ func Login(c *gin.Context) {
 
 	services.LogSystemEvent("login_success", "User logged in", user.ID, c.ClientIP())
 
-	// Decrypt private key to return to user
-	privateKey, err := crypto.DecryptPrivateKey(user.PrivateKey)
-	if err != nil {
-		services.LogSystemEvent("key_decryption_failure", "Failed to decrypt private key", user.ID, c.ClientIP())
-		// Don't fail login, just don't return the key
-		privateKey = ""
-	}
-
 	// Remove password from response
 	user.Password = ""
 
 	c.JSON(http.StatusOK, LoginResponse{
-		User:       user,
-		Token:      token,
-		PrivateKey: privateKey,
-		Message:    "Login successful",
+		User:    user,
+		Token:   token,
+		Message: "Login successful",
 	})
 }
 
@@ -263,12 +267,13 @@ func AddBeneficiary(c *gin.Context) {
 		return
 	}
 
-	// Validate wallet ID exists
-	_, err := services.GetWalletByID(req.BeneficiaryWalletID)
+	// Validate wallet ID exists and store it under its canonical ID
+	wallet, err := services.GetWalletByID(req.BeneficiaryWalletID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet ID"})
 		return
 	}
+	req.BeneficiaryWalletID = wallet.WalletID
 
 	user, err := services.GetUserByID(userID)
 	if err != nil {
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"backend/middleware"
+	"backend/models"
+	"backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchPaymentRequest is one receiver's line of a batch payment
+type BatchPaymentRequest struct {
+	WalletID string        `json:"walletId" binding:"required,min=10"`
+	Amount   models.Amount `json:"amount" binding:"required,gt=0"`
+	Note     string        `json:"note" binding:"max=500"` // Shown on the receiver's line, e.g. "March salary"
+}
+
+// BuildBatchRequest describes a payment to many receivers to be signed by the client
+type BuildBatchRequest struct {
+	Payments     []BatchPaymentRequest `json:"payments" binding:"required,min=1,max=250,dive"`
+	Fee          models.Amount         `json:"fee" binding:"gte=0"` // Defaults to the minimum fee
+	Note         string                `json:"note" binding:"max=500"`
+	FromWalletID string                `json:"fromWalletId"` // One of the user's addresses; defaults to the registration wallet
+}
+
+// BuildBatch selects inputs once and builds an unsigned transaction with an output for
+// every receiver. It is signed once and submitted like BuildTransaction.
+func BuildBatch(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req BuildBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	// The transfer limits apply to every line and to the batch as a whole
+	payments := make([]services.BatchPayment, len(req.Payments))
+	total := models.Amount(0)
+	for idx, payment := range req.Payments {
+		if !checkTransferAmount(c, userID, payment.Amount) {
+			return
+		}
+		payments[idx] = services.BatchPayment{WalletID: payment.WalletID, Amount: payment.Amount, Note: payment.Note}
+
+		var err error
+		if total, err = total.Add(payment.Amount); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum transaction amount is 1,000,000 BC"})
+			return
+		}
+	}
+	if !checkTransferAmount(c, userID, total) {
+		return
+	}
+
+	if req.Fee == 0 {
+		req.Fee = services.GetMinimumFee()
+	}
+
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	senderWalletID, senderPublicKey := user.WalletID, user.PublicKey
+	if req.FromWalletID != "" {
+		wallet, err := services.GetOwnedWallet(userID, req.FromWalletID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		senderWalletID, senderPublicKey = wallet.WalletID, wallet.PublicKey
+	}
+
+	tx, err := services.BuildBatchTransaction(senderWalletID, payments, req.Fee, req.Note, senderPublicKey)
+	if err != nil {
+		services.LogSystemEvent("transaction_failure", "Failed to build batch: "+err.Error(), userID, c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondUnsignedTransaction(c, tx)
+}
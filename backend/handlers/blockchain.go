@@ -71,8 +71,14 @@ func GetLatestBlock(c *gin.Context) {
 	})
 }
 
-// ValidateBlockchain validates the entire blockchain
+// ValidateBlockchain validates block links, hashes and proof-of-work. Deep validation
+// replays the whole chain and is only available to admins through ValidateBlockchainDeep.
 func ValidateBlockchain(c *gin.Context) {
+	if c.Query("deep") == "true" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Deep validation requires admin access: GET /api/admin/blockchain/validate"})
+		return
+	}
+
 	isValid := services.ValidateChain()
 
 	c.JSON(http.StatusOK, gin.H{
@@ -80,10 +86,49 @@ func ValidateBlockchain(c *gin.Context) {
 	})
 }
 
-// MineBlockManual manually triggers block mining
+// ValidateBlockchainDeep replays every transaction and returns a report of all violations
+func ValidateBlockchainDeep(c *gin.Context) {
+	c.JSON(http.StatusOK, services.ValidateChainDeep())
+}
+
+// ReindexUTXOs rebuilds the UTXO set and wallet balances from the blockchain.
+// With ?dryRun=true it only reports what would change.
+func ReindexUTXOs(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "true"
+
+	report, err := services.ReindexUTXOs(dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ReencryptPrivateKeys re-encrypts stored private keys under the active AES key version.
+// ?batchSize sets how many users are saved per batch and ?dryRun=true only reports counts.
+func ReencryptPrivateKeys(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "true"
+
+	batchSize, err := strconv.Atoi(c.DefaultQuery("batchSize", strconv.Itoa(services.DefaultReencryptBatchSize)))
+	if err != nil || batchSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch size"})
+		return
+	}
+
+	report, err := services.ReencryptPrivateKeys(batchSize, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// MineBlockManual mines the pending pool on demand. It is an admin route, so users cannot
+// mint rewards for themselves; the reward goes to ?walletId or else MINER_WALLET_ID.
 func MineBlockManual(c *gin.Context) {
-	// This would typically be automated, but provided for testing
-	walletID := c.Query("walletId")
+	walletID := c.DefaultQuery("walletId", services.GetMinerWallet())
 	if walletID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Wallet ID required"})
 		return
@@ -118,6 +163,9 @@ func GetBlockchainStats(c *gin.Context) {
 		"totalTransactions":   totalTransactions,
 		"pendingTransactions": len(pendingTxs),
 		"totalSupply":         totalSupply,
+		"difficulty":          services.GetCurrentDifficulty(),
+		"minimumFee":          services.GetMinimumFee(),
+		"blockReward":         services.GetBlockSubsidy(services.GetLatestBlock().Index + 1),
 		"latestBlock":         services.GetLatestBlock(),
 	}
 
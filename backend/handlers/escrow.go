@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"backend/middleware"
+	"backend/models"
+	"backend/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildEscrowRequest describes a hash-time-locked payment to be signed by the client
+type BuildEscrowRequest struct {
+	ReceiverWalletID string        `json:"receiverWalletId" binding:"required,min=10"`
+	Amount           models.Amount `json:"amount" binding:"required,gt=0"`
+	Fee              models.Amount `json:"fee" binding:"gte=0"` // Defaults to the minimum fee
+	Note             string        `json:"note" binding:"max=500"`
+	FromWalletID     string        `json:"fromWalletId"`                       // One of the user's addresses; defaults to the registration wallet
+	HashLock         string        `json:"hashLock" binding:"required,len=64"` // Hex SHA-256 of the secret the receiver reveals to claim
+	Timeout          int64         `json:"timeout" binding:"required,gt=0"`    // Block height, or Unix time from 500000000, from which the sender can reclaim
+}
+
+// ClaimEscrowRequest reveals the secret of an escrow to claim it
+type ClaimEscrowRequest struct {
+	Preimage string        `json:"preimage" binding:"required"` // Hex secret whose SHA-256 is the hash lock
+	Fee      models.Amount `json:"fee" binding:"gte=0"`         // Paid from the escrowed amount; defaults to the minimum fee
+}
+
+// RefundEscrowRequest reclaims an expired escrow
+type RefundEscrowRequest struct {
+	Fee models.Amount `json:"fee" binding:"gte=0"` // Paid from the escrowed amount; defaults to the minimum fee
+}
+
+// escrowErrorStatus maps escrow errors to HTTP status codes
+func escrowErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrEscrowNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrEscrowExpired):
+		return http.StatusGone
+	}
+	return transactionErrorStatus(err)
+}
+
+// BuildEscrow builds an unsigned payment held in escrow until the receiver reveals the
+// secret or the timeout passes. It is signed and submitted like BuildTransaction.
+func BuildEscrow(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req BuildEscrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if !checkTransferAmount(c, userID, req.Amount) {
+		return
+	}
+
+	if req.Fee == 0 {
+		req.Fee = services.GetMinimumFee()
+	}
+
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	senderWalletID, senderPublicKey := user.WalletID, user.PublicKey
+	if req.FromWalletID != "" {
+		wallet, err := services.GetOwnedWallet(userID, req.FromWalletID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		senderWalletID, senderPublicKey = wallet.WalletID, wallet.PublicKey
+	}
+
+	tx, err := services.BuildEscrowTransaction(senderWalletID, req.ReceiverWalletID, req.Amount, req.Fee, req.HashLock, req.Timeout, req.Note, senderPublicKey)
+	if err != nil {
+		services.LogSystemEvent("transaction_failure", "Failed to build escrow: "+err.Error(), userID, c.ClientIP())
+		c.JSON(escrowErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	respondUnsignedTransaction(c, tx)
+}
+
+// ClaimEscrow builds the unsigned transaction by which the receiver takes an escrow
+// payment by revealing its secret
+func ClaimEscrow(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ClaimEscrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if req.Fee == 0 {
+		req.Fee = services.GetMinimumFee()
+	}
+
+	wallet, err := services.GetEscrowPartyWallet(userID, c.Param("utxoId"), false)
+	if err != nil {
+		c.JSON(escrowErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := services.BuildEscrowClaim(wallet.WalletID, c.Param("utxoId"), req.Preimage, req.Fee, wallet.PublicKey)
+	if err != nil {
+		services.LogSystemEvent("transaction_failure", "Failed to build escrow claim: "+err.Error(), userID, c.ClientIP())
+		c.JSON(escrowErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	respondUnsignedTransaction(c, tx)
+}
+
+// RefundEscrow builds the unsigned transaction by which the sender takes back an escrow
+// payment once its timeout has passed
+func RefundEscrow(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req RefundEscrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if req.Fee == 0 {
+		req.Fee = services.GetMinimumFee()
+	}
+
+	wallet, err := services.GetEscrowPartyWallet(userID, c.Param("utxoId"), true)
+	if err != nil {
+		c.JSON(escrowErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := services.BuildEscrowRefund(wallet.WalletID, c.Param("utxoId"), req.Fee, wallet.PublicKey)
+	if err != nil {
+		services.LogSystemEvent("transaction_failure", "Failed to build escrow refund: "+err.Error(), userID, c.ClientIP())
+		c.JSON(escrowErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	respondUnsignedTransaction(c, tx)
+}
+
+// GetEscrows lists the unsettled escrows the user sent or can claim
+func GetEscrows(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	walletIDs, err := services.GetUserWalletIDs(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	escrows, err := services.GetWalletEscrows(walletIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"escrows": escrows})
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"backend/crypto"
+	"backend/middleware"
+	"backend/models"
+	"backend/services"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateMultisigRequest describes an M-of-N wallet. The caller's own public key must be
+// one of the keys.
+type CreateMultisigRequest struct {
+	Threshold  int      `json:"threshold" binding:"required,gt=0"`
+	PublicKeys []string `json:"publicKeys" binding:"required,min=2"`
+	Label      string   `json:"label" binding:"max=100"`
+}
+
+// ProposeMultisigRequest describes a spend from a multisig wallet
+type ProposeMultisigRequest struct {
+	ReceiverWalletID string        `json:"receiverWalletId" binding:"required,min=10"`
+	Amount           models.Amount `json:"amount" binding:"required,gt=0"`
+	Fee              models.Amount `json:"fee" binding:"gte=0"` // Defaults to the minimum fee
+	Note             string        `json:"note" binding:"max=500"`
+}
+
+// SignMultisigRequest carries one co-signer's signature over a proposal hash
+type SignMultisigRequest struct {
+	PublicKey string `json:"publicKey" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+// multisigErrorStatus maps multisig errors to HTTP status codes
+func multisigErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotCosigner), errors.Is(err, services.ErrSignerKeyNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrMultisigProposalGone):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrMultisigExists), errors.Is(err, services.ErrProposalNotOpen):
+		return http.StatusConflict
+	}
+	return transactionErrorStatus(err)
+}
+
+// CreateMultisigWallet registers an M-of-N wallet co-signed by the caller
+func CreateMultisigWallet(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateMultisigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	wallet, err := services.CreateMultisigWallet(userID, req.Threshold, req.PublicKeys, req.Label)
+	if err != nil {
+		c.JSON(multisigErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"wallet":  wallet,
+		"address": services.WalletAddress(wallet.WalletID),
+	})
+}
+
+// GetMultisigWallets lists the multisig wallets the caller co-signs with their balances
+func GetMultisigWallets(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	wallets, err := services.GetUserMultisigWallets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := make([]gin.H, 0, len(wallets))
+	for _, wallet := range wallets {
+		balance, err := services.CalculateBalance(wallet.WalletID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		result = append(result, gin.H{
+			"wallet":  wallet,
+			"address": services.WalletAddress(wallet.WalletID),
+			"balance": balance,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"wallets": result})
+}
+
+// GetMultisigWallet returns a co-signed wallet with its balance and spend proposals
+func GetMultisigWallet(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	wallet, _, err := services.GetCosignedWallet(userID, c.Param("walletId"))
+	if err != nil {
+		c.JSON(multisigErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	balance, err := services.CalculateBalance(wallet.WalletID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	proposals, err := services.GetWalletMultisigProposals(wallet.WalletID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallet":    wallet,
+		"address":   services.WalletAddress(wallet.WalletID),
+		"balance":   balance,
+		"proposals": proposals,
+	})
+}
+
+// ProposeMultisigSpend builds an unsigned spend from a co-signed wallet. Each co-signer
+// signs the returned hash with their own key, as for BuildTransaction, and posts the
+// signature to the proposal.
+func ProposeMultisigSpend(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ProposeMultisigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if !checkTransferAmount(c, userID, req.Amount) {
+		return
+	}
+
+	if req.Fee == 0 {
+		req.Fee = services.GetMinimumFee()
+	}
+
+	proposal, err := services.ProposeMultisigSpend(userID, c.Param("walletId"), req.ReceiverWalletID, req.Amount, req.Fee, req.Note)
+	if err != nil {
+		services.LogSystemEvent("transaction_failure", "Failed to propose multisig spend: "+err.Error(), userID, c.ClientIP())
+		c.JSON(multisigErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"proposal":            proposal,
+		"hash":                proposal.Transaction.Hash,
+		"signingPayload":      base64.StdEncoding.EncodeToString(services.SerializeTransaction(proposal.Transaction)),
+		"signatureAlgorithms": signatureAlgorithms,
+	})
+}
+
+// GetMultisigProposal returns a spend proposal of a co-signed wallet
+func GetMultisigProposal(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	proposal, err := services.GetCosignedProposal(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(multisigErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"proposal":       proposal,
+		"signingPayload": base64.StdEncoding.EncodeToString(services.SerializeTransaction(proposal.Transaction)),
+	})
+}
+
+// SignMultisigProposal adds the caller's signature to a proposal. The signature that meets
+// the threshold submits the transaction to the pending pool.
+func SignMultisigProposal(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req SignMultisigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if algorithm, err := crypto.DetectKeyAlgorithm(req.PublicKey); err != nil || algorithm == crypto.AlgorithmMultisig {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publicKey must be a single public key"})
+		return
+	}
+
+	proposal, err := services.SignMultisigProposal(userID, c.Param("id"), req.PublicKey, req.Signature)
+	if err != nil {
+		services.LogSystemEvent("transaction_failure", "Multisig signature rejected: "+err.Error(), userID, c.ClientIP())
+		response := gin.H{"error": err.Error()}
+		if proposal != nil {
+			response["proposal"] = proposal
+		}
+		c.JSON(multisigErrorStatus(err), response)
+		return
+	}
+
+	if proposal.Status == services.ProposalSubmitted {
+		services.LogSystemEvent("transaction_success", "Multisig transaction submitted to pending pool", userID, c.ClientIP())
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":  "Threshold met; transaction accepted and waiting to be mined",
+			"proposal": proposal,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Signature added",
+		"proposal": proposal,
+	})
+}
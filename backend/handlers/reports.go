@@ -130,36 +130,25 @@ func GetReports(c *gin.Context) {
 		return
 	}
 
-	// Get transactions
-	transactions, _ := services.GetTransactionsByWallet(user.WalletID)
-
-	// Calculate totals
-	totalSent := 0.0
-	totalReceived := 0.0
-
-	for _, tx := range transactions {
-		if tx.SenderWalletID == user.WalletID {
-			totalSent += tx.Amount
-		}
-		if tx.ReceiverWalletID == user.WalletID {
-			totalReceived += tx.Amount
-		}
+	// Totals cover every address the user owns, not just the registration wallet
+	report, err := services.GetUserReport(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Get zakat summary
 	zakatSummary, _ := services.GetZakatSummary(userID)
 
-	// Get current balance
-	balance, _ := services.CalculateBalance(user.WalletID)
-
-	report := map[string]interface{}{
-		"walletId":         user.WalletID,
-		"currentBalance":   balance,
-		"totalSent":        totalSent,
-		"totalReceived":    totalReceived,
-		"transactionCount": len(transactions),
+	c.JSON(http.StatusOK, gin.H{
+		"walletId":         report.WalletID,
+		"walletIds":        report.WalletIDs,
+		"currentBalance":   report.CurrentBalance,
+		"totalSent":        report.TotalSent,
+		"totalReceived":    report.TotalReceived,
+		"totalFees":        report.TotalFees,
+		"transactionCount": report.TransactionCount,
+		"paymentLines":     report.PaymentLines,
 		"zakatSummary":     zakatSummary,
-	}
-
-	c.JSON(http.StatusOK, report)
+	})
 }
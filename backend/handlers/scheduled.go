@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"backend/middleware"
+	"backend/models"
+	"backend/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleTransferRequest describes a future or recurring transfer from the registration wallet
+type ScheduleTransferRequest struct {
+	ReceiverWalletID string        `json:"receiverWalletId" binding:"required,min=10"`
+	Amount           models.Amount `json:"amount" binding:"required,gt=0"`
+	Fee              models.Amount `json:"fee" binding:"gte=0"` // 0 pays the minimum fee at each run
+	Note             string        `json:"note" binding:"max=500"`
+	Frequency        string        `json:"frequency" binding:"required,oneof=once daily weekly monthly"`
+	ServerSigning    bool          `json:"serverSigning"`   // Must be true: runs are signed with the stored key
+	StartAt          time.Time     `json:"startAt"`         // RFC 3339; defaults to now
+	EndAt            *time.Time    `json:"endAt,omitempty"` // Recurring transfers only
+}
+
+// CreateScheduledTransfer schedules a future or recurring transfer
+func CreateScheduledTransfer(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ScheduleTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if !checkTransferAmount(c, userID, req.Amount) {
+		return
+	}
+
+	transfer, err := services.CreateScheduledTransfer(userID, req.ReceiverWalletID, req.Amount, req.Fee, req.Note, req.Frequency, req.ServerSigning, req.StartAt, req.EndAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"scheduledTransfer": transfer})
+}
+
+// GetScheduledTransfers lists the user's scheduled transfers with their recent runs
+func GetScheduledTransfers(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	transfers, err := services.GetUserScheduledTransfers(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if transfers == nil {
+		transfers = []models.ScheduledTransfer{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scheduledTransfers": transfers})
+}
+
+// CancelScheduledTransfer stops a scheduled transfer
+func CancelScheduledTransfer(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	transfer, err := services.CancelScheduledTransfer(userID, c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrScheduledTransferNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrScheduledTransferInactive):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Scheduled transfer cancelled",
+		"scheduledTransfer": transfer,
+	})
+}
@@ -3,111 +3,229 @@ package handlers
 import (
 	"backend/crypto"
 	"backend/middleware"
+	"backend/models"
+	"backend/script"
 	"backend/services"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// CreateTransactionRequest represents transaction creation request
-type CreateTransactionRequest struct {
-	ReceiverWalletID string  `json:"receiverWalletId" binding:"required,min=10"`
-	Amount           float64 `json:"amount" binding:"required,gt=0"`
-	Note             string  `json:"note" binding:"max=500"`
-	PrivateKey       string  `json:"privateKey" binding:"required,min=100"`
+// CreateTransaction answers the retired endpoint that took the sender's private key in
+// the request body. Clients build with BuildTransaction, sign locally and submit with
+// SubmitTransaction, so the private key never reaches the server.
+func CreateTransaction(c *gin.Context) {
+	c.JSON(http.StatusGone, gin.H{
+		"error": "This endpoint no longer accepts private keys. Build with POST /api/transaction/build, sign the signing payload locally and submit with POST /api/transaction/submit",
+	})
 }
 
-// CreateTransaction creates a new transaction
-func CreateTransaction(c *gin.Context) {
+// BuildTransactionRequest describes a transfer to be signed by the client
+type BuildTransactionRequest struct {
+	ReceiverWalletID string        `json:"receiverWalletId" binding:"required,min=10"`
+	Amount           models.Amount `json:"amount" binding:"required,gt=0"`
+	Fee              models.Amount `json:"fee" binding:"gte=0"` // Defaults to the minimum fee
+	Note             string        `json:"note" binding:"max=500"`
+	FromWalletID     string        `json:"fromWalletId"`             // One of the user's addresses; defaults to the registration wallet
+	LockTime         int64         `json:"lockTime" binding:"gte=0"` // Block height, or Unix time from 500000000, before which it cannot be mined
+	LockingScript    string        `json:"lockingScript"`            // Script text locking the payment, e.g. "OP_DUP OP_SHA256 0x... OP_EQUALVERIFY OP_CHECKSIG"
+	Inputs           []string      `json:"inputs"`                   // UTXO IDs to spend, such as script-locked ones; selected automatically if empty
+}
+
+// SubmitTransactionRequest carries a transaction from BuildTransaction and the client's signature
+type SubmitTransactionRequest struct {
+	Transaction models.Transaction `json:"transaction"`
+	Signature   string             `json:"signature" binding:"required"`
+	PublicKey   string             `json:"publicKey" binding:"required"`
+	// Script text unlocking each input, in input order; "" unlocks with the signature
+	UnlockingScripts []string `json:"unlockingScripts"`
+}
+
+// signatureAlgorithms describes what a client signs for each key algorithm
+var signatureAlgorithms = map[crypto.KeyAlgorithm]string{
+	crypto.AlgorithmRSA:       "RSASSA-PKCS1-v1_5/SHA-256 over signingPayload",
+	crypto.AlgorithmEd25519:   "Ed25519 over the hash bytes",
+	crypto.AlgorithmSecp256k1: "ECDSA secp256k1 (DER, low-S) over the hash bytes",
+}
+
+// BuildTransaction selects inputs and returns an unsigned transaction with the canonical
+// payload the client must sign. For RSA, signing the payload with RSASSA-PKCS1-v1_5 and
+// SHA-256 is equivalent to signing the returned hash; other keys sign the hash bytes.
+func BuildTransaction(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	var req CreateTransactionRequest
+	var req BuildTransactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
 		return
 	}
 
-	// Validate minimum transaction amount (0.01)
-	if req.Amount < 0.01 {
-		services.LogSystemEvent("transaction_failure", "Amount too small", userID, c.ClientIP())
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimum transaction amount is 0.01 BC"})
+	if !checkTransferAmount(c, userID, req.Amount) {
 		return
 	}
 
-	// Validate maximum transaction amount (1000000)
-	if req.Amount > 1000000 {
-		services.LogSystemEvent("transaction_failure", "Amount too large", userID, c.ClientIP())
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum transaction amount is 1,000,000 BC"})
-		return
+	if req.Fee == 0 {
+		req.Fee = services.GetMinimumFee()
 	}
 
-	// Get user
 	user, err := services.GetUserByID(userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Decrypt and validate private key
-	decryptedKey, err := crypto.DecryptPrivateKey(user.PrivateKey)
-	if err != nil || decryptedKey != req.PrivateKey {
-		// If encrypted key doesn't match, try using the provided key directly
-		if req.PrivateKey == "" {
-			services.LogSystemEvent("transaction_failure", "Invalid private key", userID, c.ClientIP())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid private key"})
+	senderWalletID, senderPublicKey := user.WalletID, user.PublicKey
+	if req.FromWalletID != "" {
+		wallet, err := services.GetOwnedWallet(userID, req.FromWalletID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		senderWalletID, senderPublicKey = wallet.WalletID, wallet.PublicKey
+	}
+
+	opts := services.TransferOptions{LockTime: req.LockTime, Inputs: req.Inputs}
+	if req.LockingScript != "" {
+		if opts.LockingScript, err = script.Assemble(req.LockingScript); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid locking script: " + err.Error()})
 			return
 		}
 	}
 
-	// Create transaction
-	tx, err := services.CreateTransaction(
-		user.WalletID,
-		req.ReceiverWalletID,
-		req.Amount,
-		req.Note,
-		user.PublicKey,
-		req.PrivateKey,
-	)
+	tx, err := services.BuildTransactionWithOptions(senderWalletID, req.ReceiverWalletID, req.Amount, req.Fee, req.Note, senderPublicKey, opts)
 	if err != nil {
-		services.LogSystemEvent("transaction_failure", "Failed to create transaction: "+err.Error(), userID, c.ClientIP())
+		services.LogSystemEvent("transaction_failure", "Failed to build transaction: "+err.Error(), userID, c.ClientIP())
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Process transaction (validate and add to pending pool)
-	if err := services.ProcessTransaction(*tx); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	respondUnsignedTransaction(c, tx)
+}
+
+// respondUnsignedTransaction writes a built transaction with the payload and algorithm
+// the holder of its sender key signs for /transaction/submit
+func respondUnsignedTransaction(c *gin.Context, tx *models.Transaction) {
+	keyAlgorithm, err := crypto.DetectKeyAlgorithm(tx.SenderPublicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction":        tx,
+		"hash":               tx.Hash,
+		"signingPayload":     base64.StdEncoding.EncodeToString(services.SerializeTransaction(*tx)),
+		"keyAlgorithm":       keyAlgorithm,
+		"signatureAlgorithm": signatureAlgorithms[keyAlgorithm],
+	})
+}
+
+// SubmitTransaction accepts a transaction signed by the client and queues it for mining.
+// The private key never reaches the server; the hash, signature, key and inputs are
+// all checked by ProcessTransaction.
+func SubmitTransaction(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	// Process UTXOs
-	if err := services.ProcessTransactionUTXOs(*tx); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process UTXOs"})
+	var req SubmitTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
 		return
 	}
 
-	// Mine the transaction into a block
-	block, err := services.MineBlock(user.WalletID)
-	if err != nil {
-		services.LogSystemEvent("mining_failure", "Failed to mine block: "+err.Error(), userID, c.ClientIP())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction created but mining failed"})
+	tx := req.Transaction
+	if _, err := services.GetOwnedWallet(userID, tx.SenderWalletID); err != nil {
+		services.LogSystemEvent("transaction_failure", "Submitted transaction for another wallet", userID, c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "Transaction does not spend from your wallet"})
+		return
+	}
+	switch tx.Type {
+	case "transfer", "batch", "escrow", "escrow_claim", "escrow_refund":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only transfers, batches and escrow transactions can be submitted"})
 		return
 	}
 
-	services.LogSystemEvent("transaction_success", "Transaction created and mined", userID, c.ClientIP())
+	if len(req.UnlockingScripts) > 0 {
+		tx.UnlockingScripts = make([]string, len(req.UnlockingScripts))
+		for idx, text := range req.UnlockingScripts {
+			unlocking, err := script.Assemble(text)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid unlocking script %d: %v", idx, err)})
+				return
+			}
+			tx.UnlockingScripts[idx] = hex.EncodeToString(unlocking)
+		}
+	}
+
+	tx.SenderPublicKey = req.PublicKey
+	tx.Signature = req.Signature
+	tx.Status = "pending"
+	tx.BlockHash = ""
+
+	if err := services.ProcessTransaction(tx); err != nil {
+		c.JSON(transactionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message":     "Transaction successful",
+	services.LogSystemEvent("transaction_success", "Signed transaction submitted to pending pool", userID, c.ClientIP())
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":     "Transaction accepted and waiting to be mined",
 		"transaction": tx,
-		"block":       block,
 	})
 }
 
-// GetTransactionHistory returns transaction history for user
+// checkTransferAmount rejects amounts outside the per-transaction limits, writing the
+// response and returning false if the request should stop
+func checkTransferAmount(c *gin.Context, userID string, amount models.Amount) bool {
+	// Validate minimum transaction amount (0.01)
+	if amount < models.BC/100 {
+		services.LogSystemEvent("transaction_failure", "Amount too small", userID, c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimum transaction amount is 0.01 BC"})
+		return false
+	}
+
+	// Validate maximum transaction amount (1000000)
+	if amount > services.MaxTransferAmount {
+		services.LogSystemEvent("transaction_failure", "Amount too large", userID, c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum transaction amount is 1,000,000 BC"})
+		return false
+	}
+
+	return true
+}
+
+// transactionErrorStatus maps a transaction rejection to an HTTP status: inputs the
+// sender does not own or cannot unlock are forbidden, inputs already spent or queued conflict, and
+// anything else is a bad request
+func transactionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrSenderKeyMismatch), errors.Is(err, services.ErrInputNotOwned), errors.Is(err, services.ErrScriptFailed):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrUTXOAlreadySpent), errors.Is(err, services.ErrInputAlreadyQueued):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrTransactionLocked):
+		return http.StatusTooEarly
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// GetTransactionHistory returns the transaction history of all of the user's wallets
 func GetTransactionHistory(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
@@ -121,14 +239,23 @@ func GetTransactionHistory(c *gin.Context) {
 		return
 	}
 
-	transactions, err := services.GetTransactionsByWallet(user.WalletID)
+	transactions, err := services.GetTransactionsByUser(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	walletIDs, err := services.GetUserWalletIDs(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Lines break batches into their receivers: all of them for batches the user sent,
+	// the user's own for batches paying them
 	c.JSON(http.StatusOK, gin.H{
 		"transactions": transactions,
+		"lines":        services.GetWalletPaymentLines(transactions, walletIDs),
 		"count":        len(transactions),
 	})
 }
@@ -161,3 +288,57 @@ func GetPendingTransactions(c *gin.Context) {
 		"count":        len(transactions),
 	})
 }
+
+// GetTransactionProof returns a merkle inclusion proof for a mined transaction
+func GetTransactionProof(c *gin.Context) {
+	hash := c.Param("hash")
+	if hash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction hash required"})
+		return
+	}
+
+	proof, err := services.GetTransactionProof(hash)
+	if err != nil {
+		if errors.Is(err, services.ErrTransactionNotMined) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transaction has not been mined yet"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"proof":    proof,
+		"verified": services.VerifyTransactionProof(*proof),
+	})
+}
+
+// WaitForTransaction waits until a transaction is mined or the timeout expires
+func WaitForTransaction(c *gin.Context) {
+	hash := c.Param("hash")
+	if hash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction hash required"})
+		return
+	}
+
+	// Timeout in seconds, capped to keep connections short-lived
+	timeout, err := strconv.Atoi(c.DefaultQuery("timeout", "30"))
+	if err != nil || timeout <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timeout"})
+		return
+	}
+	if timeout > 60 {
+		timeout = 60
+	}
+
+	tx, err := services.WaitForTransaction(hash, time.Duration(timeout)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction": tx,
+		"confirmed":   tx.Status == "confirmed",
+	})
+}
@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"backend/crypto"
 	"backend/middleware"
+	"backend/models"
 	"backend/services"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetWallet returns wallet information
+// GetWallet returns the registration wallet with the UTXOs and addresses of all the
+// user's wallets
 func GetWallet(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
@@ -22,20 +26,35 @@ func GetWallet(c *gin.Context) {
 		return
 	}
 
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
 	// Get UTXOs
-	utxos, err := services.GetUTXOsByWallet(wallet.WalletID)
+	utxos, err := services.GetUTXOsByUser(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get UTXOs"})
 		return
 	}
 
+	addresses, err := services.GetUserAddresses(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"wallet": wallet,
-		"utxos":  utxos,
+		"wallet":    wallet,
+		"address":   services.WalletAddress(wallet.WalletID),
+		"utxos":     utxos,
+		"addresses": addresses,
 	})
 }
 
-// GetBalance returns wallet balance
+// GetBalance returns the balance summed over all of the user's wallets, with a
+// breakdown per address
 func GetBalance(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
@@ -49,19 +68,30 @@ func GetBalance(c *gin.Context) {
 		return
 	}
 
-	balance, err := services.CalculateBalance(user.WalletID)
+	addresses, err := services.GetUserAddresses(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Mining rewards count towards the balance but only become spendable once mature
+	var balance, immature models.Amount
+	for _, address := range addresses {
+		balance += address.Balance
+		immature += address.ImmatureBalance
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"balance":  balance,
-		"walletId": user.WalletID,
+		"balance":         balance,
+		"immatureBalance": immature,
+		"walletId":        user.WalletID,
+		"address":         services.WalletAddress(user.WalletID),
+		"addresses":       addresses,
 	})
 }
 
-// ValidateWalletID validates if a wallet ID exists
+// ValidateWalletID validates a wallet ID's format offline, then checks that it exists.
+// Addresses with a bad checksum are reported as likely typos without a lookup.
 func ValidateWalletID(c *gin.Context) {
 	walletID := c.Param("walletId")
 	if walletID == "" {
@@ -69,6 +99,15 @@ func ValidateWalletID(c *gin.Context) {
 		return
 	}
 
+	if err := crypto.ValidateWalletIDFormat(walletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"valid":   false,
+			"typo":    errors.Is(err, crypto.ErrAddressChecksum),
+			"message": err.Error(),
+		})
+		return
+	}
+
 	wallet, err := services.GetWalletByID(walletID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -88,11 +127,12 @@ func ValidateWalletID(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"valid":       true,
 		"walletId":    wallet.WalletID,
+		"address":     services.WalletAddress(wallet.WalletID),
 		"displayName": displayName,
 	})
 }
 
-// GetWalletUTXOs returns all UTXOs for a wallet
+// GetWalletUTXOs returns the unspent UTXOs of all of the user's wallets
 func GetWalletUTXOs(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
@@ -106,7 +146,7 @@ func GetWalletUTXOs(c *gin.Context) {
 		return
 	}
 
-	utxos, err := services.GetUTXOsByWallet(user.WalletID)
+	utxos, err := services.GetUTXOsByUser(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -117,3 +157,107 @@ func GetWalletUTXOs(c *gin.Context) {
 		"count": len(utxos),
 	})
 }
+
+// CreateHDWalletRequest sets up an HD wallet from the extended public keys of its
+// accounts, derived from the mnemonic on the user's side (see the hd-account-keys command)
+type CreateHDWalletRequest struct {
+	AccountKeys []string `json:"accountKeys" binding:"required,min=1,max=10,dive,required"`
+}
+
+// CreateHDWallet creates the user's HD wallet and its first receiving address. Only
+// account public keys are accepted; the mnemonic never reaches the server.
+func CreateHDWallet(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateHDWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	wallet, err := services.CreateHDWallet(userID, req.AccountKeys)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrHDWalletExists) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "HD wallet created",
+		"wallet":  wallet,
+		"address": services.WalletAddress(wallet.WalletID),
+	})
+}
+
+// CreateAddressRequest asks for a new receiving address in an HD account
+type CreateAddressRequest struct {
+	Account uint32 `json:"account"`
+	Label   string `json:"label" binding:"max=100"`
+}
+
+// CreateAddress derives the next receiving address of one of the user's HD accounts
+func CreateAddress(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateAddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	wallet, err := services.NewHDAddress(userID, req.Account, req.Label)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrNoHDWallet):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrHDAccountNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"wallet":  wallet,
+		"address": services.WalletAddress(wallet.WalletID),
+	})
+}
+
+// GetAddresses lists the user's wallets and HD accounts with per-address balances
+func GetAddresses(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := services.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	addresses, err := services.GetUserAddresses(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"addresses": addresses,
+		"accounts":  user.HDAccounts,
+		"count":     len(addresses),
+	})
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"backend/config"
+	"backend/crypto"
 	"backend/middleware"
 	"backend/routes"
 	"backend/services"
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -20,23 +25,37 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	// Initialize MongoDB
-	if err := config.InitMongoDB(); err != nil {
-		log.Fatalf("Failed to initialize MongoDB: %v", err)
+	// Key derivation runs offline, without storage, so a mnemonic never reaches a server
+	if len(os.Args) > 1 && os.Args[1] == "derive-key" {
+		deriveKey(os.Args[2:])
+		return
 	}
-	defer config.DisconnectMongoDB()
-
-	// Create database indexes for optimal performance
-	if err := config.CreateIndexes(); err != nil {
-		log.Printf("Warning: Failed to create some indexes: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "hd-account-keys" {
+		hdAccountKeys(os.Args[2:])
+		return
 	}
 
-	// Initialize blockchain with genesis block
+	// Connect storage and load the blockchain
+	cleanup := initStorage()
+	defer cleanup()
+
 	services.InitBlockchain()
 
+	// Maintenance subcommands run once and exit
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// Start Zakat scheduler
 	go services.StartZakatScheduler()
 
+	// Start scheduler for future and recurring transfers
+	go services.StartTransferScheduler()
+
+	// Start background miner
+	go services.StartMiner()
+
 	// Setup Gin router
 	r := gin.Default()
 
@@ -71,3 +90,177 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// initStorage selects the storage backend (mongo by default, memory for local testing)
+// and returns a function that releases it
+func initStorage() func() {
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	if err := services.InitStore(storageBackend); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	if storageBackend == services.StorageMemory {
+		log.Println("Using in-memory storage; data will not persist across restarts")
+		return func() {}
+	}
+
+	// Initialize MongoDB
+	if err := config.InitMongoDB(); err != nil {
+		log.Fatalf("Failed to initialize MongoDB: %v", err)
+	}
+
+	// Create database indexes for optimal performance
+	if err := config.CreateIndexes(); err != nil {
+		log.Printf("Warning: Failed to create some indexes: %v", err)
+	}
+
+	return func() { config.DisconnectMongoDB() }
+}
+
+// runCommand runs a maintenance subcommand
+func runCommand(name string, args []string) {
+	switch name {
+	case "reindex":
+		flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+		dryRun := flags.Bool("dry-run", false, "report differences without changing stored data")
+		flags.Parse(args)
+
+		report, err := services.ReindexUTXOs(*dryRun)
+		if err != nil {
+			log.Fatalf("Reindex failed: %v", err)
+		}
+		printJSON(report)
+	case "migrate-amounts":
+		migrated, err := services.MigrateAmounts()
+		if err != nil {
+			log.Fatalf("Amount migration failed: %v", err)
+		}
+		printJSON(migrated)
+	case "reencrypt-keys":
+		flags := flag.NewFlagSet("reencrypt-keys", flag.ExitOnError)
+		batchSize := flags.Int("batch-size", services.DefaultReencryptBatchSize, "users loaded and saved per batch")
+		dryRun := flags.Bool("dry-run", false, "report counts without changing stored keys")
+		flags.Parse(args)
+
+		report, err := services.ReencryptPrivateKeys(*batchSize, *dryRun)
+		if err != nil {
+			log.Fatalf("Re-encryption failed: %v", err)
+		}
+		printJSON(report)
+		if len(report.Failures) > 0 {
+			os.Exit(1)
+		}
+	default:
+		log.Fatalf("Unknown command %q (available: reindex, migrate-amounts, reencrypt-keys, derive-key, hd-account-keys)", name)
+	}
+}
+
+// deriveKey reads a mnemonic from stdin and prints the private key of one of its HD
+// addresses, for signing transactions from that address
+func deriveKey(args []string) {
+	flags := flag.NewFlagSet("derive-key", flag.ExitOnError)
+	account := flags.Uint("account", 0, "HD account number")
+	index := flags.Uint("index", 0, "receiving address index within the account")
+	path := flags.String("path", "", "full derivation path, overriding -account and -index")
+	flags.Parse(args)
+
+	if *path == "" {
+		*path = crypto.HDAddressPath(uint32(*account), uint32(*index))
+	}
+
+	master := masterKey(readMnemonic())
+	key, err := master.DerivePath(*path)
+	if err != nil {
+		log.Fatalf("Key derivation failed: %v", err)
+	}
+
+	privateKey, err := key.PrivateKeyPEM()
+	if err != nil {
+		log.Fatalf("Key derivation failed: %v", err)
+	}
+	walletID, err := crypto.WalletIDFromPublicKey(key.PublicKeyPEM())
+	if err != nil {
+		log.Fatalf("Key derivation failed: %v", err)
+	}
+
+	printJSON(map[string]string{
+		"path":       *path,
+		"walletId":   walletID,
+		"publicKey":  key.PublicKeyPEM(),
+		"privateKey": privateKey,
+	})
+}
+
+// hdAccountKeys prints the extended public keys of a mnemonic's first accounts, which
+// POST /api/wallet/hd takes to set up an HD wallet. With -new it generates the mnemonic
+// and prints it as well; otherwise the mnemonic is read from stdin.
+func hdAccountKeys(args []string) {
+	flags := flag.NewFlagSet("hd-account-keys", flag.ExitOnError)
+	accounts := flags.Int("accounts", 1, fmt.Sprintf("number of accounts (1-%d)", services.MaxHDAccounts))
+	generate := flags.Bool("new", false, "generate a new mnemonic instead of reading one")
+	flags.Parse(args)
+
+	if *accounts < 1 || *accounts > services.MaxHDAccounts {
+		log.Fatalf("-accounts must be between 1 and %d", services.MaxHDAccounts)
+	}
+
+	var mnemonic string
+	if *generate {
+		generated, err := crypto.NewMnemonic()
+		if err != nil {
+			log.Fatalf("Failed to generate mnemonic: %v", err)
+		}
+		mnemonic = generated
+	} else {
+		mnemonic = readMnemonic()
+	}
+
+	master := masterKey(mnemonic)
+	accountKeys := make([]string, 0, *accounts)
+	for i := uint32(0); i < uint32(*accounts); i++ {
+		accountKey, err := master.DerivePath(crypto.HDAccountPath(i))
+		if err != nil {
+			log.Fatalf("Key derivation failed: %v", err)
+		}
+		accountKeys = append(accountKeys, accountKey.Public().String())
+	}
+
+	result := map[string]interface{}{"accountKeys": accountKeys}
+	if *generate {
+		result["mnemonic"] = mnemonic
+		result["warning"] = "Write down this mnemonic. It is the only backup of your HD wallet keys."
+	}
+	printJSON(result)
+}
+
+// readMnemonic prompts for a mnemonic on stdin
+func readMnemonic() string {
+	fmt.Fprint(os.Stderr, "Mnemonic: ")
+	mnemonic, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && mnemonic == "" {
+		log.Fatalf("Failed to read mnemonic: %v", err)
+	}
+	return mnemonic
+}
+
+// masterKey derives the HD master key of a mnemonic
+func masterKey(mnemonic string) *crypto.ExtendedKey {
+	seed, err := crypto.MnemonicToSeed(mnemonic, "")
+	if err != nil {
+		log.Fatalf("Key derivation failed: %v", err)
+	}
+	master, err := crypto.NewMasterKey(seed)
+	if err != nil {
+		log.Fatalf("Key derivation failed: %v", err)
+	}
+	return master
+}
+
+// printJSON writes a command result to stdout
+func printJSON(v interface{}) {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode result: %v", err)
+	}
+	fmt.Println(string(output))
+}
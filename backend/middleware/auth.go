@@ -104,3 +104,33 @@ func GetEmail(c *gin.Context) string {
 	}
 	return email.(string)
 }
+
+// AdminMiddleware allows only the users listed in ADMIN_USER_IDS, a comma-separated list
+// of user IDs. It must run after AuthMiddleware. User IDs are used rather than emails
+// because users can change their email.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		if !IsAdmin(userID) {
+			services.LogSystemEvent("auth_failure", "Admin access denied: "+c.FullPath(), userID, c.ClientIP())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether a user ID is listed in ADMIN_USER_IDS
+func IsAdmin(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	for _, adminID := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if strings.TrimSpace(adminID) == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"backend/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := services.GetStore()
+	services.SetStore(services.NewMemoryStore())
+	t.Cleanup(func() { services.SetStore(previous) })
+
+	t.Setenv("ADMIN_USER_IDS", "admin-1, admin-2")
+
+	tests := []struct {
+		name   string
+		userID string
+		want   int
+	}{
+		{"listed admin", "admin-1", http.StatusOK},
+		{"listed admin after a space", "admin-2", http.StatusOK},
+		{"other user", "user-1", http.StatusForbidden},
+		{"no user", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/admin", func(c *gin.Context) {
+				if tt.userID != "" {
+					c.Set("userID", tt.userID)
+				}
+				c.Next()
+			}, AdminMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
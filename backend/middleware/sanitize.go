@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"backend/crypto"
 	"regexp"
 	"strings"
 
@@ -48,12 +49,8 @@ func ValidateEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
 
-// ValidateWalletID checks if wallet ID format is valid
+// ValidateWalletID checks if wallet ID format is valid: a checksummed address for this
+// network, or a legacy hex wallet ID. Mistyped addresses fail the checksum offline.
 func ValidateWalletID(walletID string) bool {
-	// Wallet ID should be alphanumeric and at least 10 characters
-	if len(walletID) < 10 {
-		return false
-	}
-	walletRegex := regexp.MustCompile(`^[a-zA-Z0-9]+$`)
-	return walletRegex.MatchString(walletID)
+	return crypto.ValidateWalletIDFormat(walletID) == nil
 }
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// Amount is a quantity of BC stored as an integer number of base units
+type Amount int64
+
+// AmountDecimals is the number of decimal places a BC amount can have
+const AmountDecimals = 8
+
+// BC is one coin in base units
+const BC Amount = 100000000
+
+// AmountFromFloat converts a BC value to the nearest base unit. It is only meant for
+// configuration values, percentages and legacy data.
+func AmountFromFloat(value float64) Amount {
+	return Amount(math.Round(value * float64(BC)))
+}
+
+// ParseAmount parses a decimal BC value such as "12.5" exactly. More than
+// AmountDecimals decimal places is an error rather than being rounded.
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	digits, negative := strings.CutPrefix(s, "-")
+	if !negative {
+		digits = strings.TrimPrefix(digits, "+")
+	}
+
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(fraction) > AmountDecimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, AmountDecimals)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	fraction += strings.Repeat("0", AmountDecimals-len(fraction))
+
+	for _, part := range []string{whole, fraction} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	units, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %v", s, err)
+	}
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// Float64 returns the amount in BC as a float, for display and ratios only
+func (a Amount) Float64() float64 {
+	return float64(a) / float64(BC)
+}
+
+// Fixed formats the amount with all AmountDecimals places, e.g. "12.50000000"
+func (a Amount) Fixed() string {
+	sign := ""
+	units := int64(a)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, units/int64(BC), units%int64(BC))
+}
+
+// String formats the amount in BC without trailing zeros, e.g. "12.5"
+func (a Amount) String() string {
+	return strings.TrimSuffix(strings.TrimRight(a.Fixed(), "0"), ".")
+}
+
+// Percent returns p percent of the amount, rounded to the nearest base unit
+func (a Amount) Percent(p float64) Amount {
+	return Amount(math.Round(float64(a) * p / 100))
+}
+
+// Add returns a + b, or an error if the sum does not fit in an Amount
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, fmt.Errorf("amount %s + %s overflows", a, b)
+	}
+	return sum, nil
+}
+
+// MarshalJSON encodes the amount as an exact decimal number of BC
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a decimal number or a quoted decimal string of BC
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "null" {
+		return nil
+	}
+
+	parsed, err := ParseAmount(text)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// MarshalBSONValue stores the amount as a 64-bit integer of base units
+func (a Amount) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(int64(a))
+}
+
+// UnmarshalBSONValue reads base units, or a legacy double holding BC
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: t, Data: data}
+
+	switch t {
+	case bsontype.Int64:
+		*a = Amount(value.Int64())
+	case bsontype.Int32:
+		*a = Amount(value.Int32())
+	case bsontype.Double:
+		*a = AmountFromFloat(value.Double())
+	case bsontype.Null, bsontype.Undefined:
+		*a = 0
+	default:
+		return fmt.Errorf("cannot decode %s into an amount", t)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{"12.5", 12*BC + BC/2, false},
+		{"0.00000001", 1, false},
+		{"1.23456789", 123456789, false},
+		{"1.", BC, false},
+		{".5", BC / 2, false},
+		{" 3 ", 3 * BC, false},
+		{"+2", 2 * BC, false},
+		{"-2.5", -(2*BC + BC/2), false},
+		{"-0.00000001", -1, false},
+		{"92233720368.54775807", math.MaxInt64, false},
+		{"1.000000001", 0, true},
+		{"0.123456789", 0, true},
+		{"92233720368.54775808", 0, true},
+		{"", 0, true},
+		{".", 0, true},
+		{"-", 0, true},
+		{"--1", 0, true},
+		{"+-1", 0, true},
+		{"-+1", 0, true},
+		{"1e8", 0, true},
+		{"1,5", 0, true},
+		{"1.2.3", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseAmount(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAmount(%q) err = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAmount(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmountFromFloatRounds(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want Amount
+	}{
+		{0.1 + 0.2, 30000000},
+		{12.5, 12*BC + BC/2},
+		{0.123456789, 12345679},
+		{-0.123456789, -12345679},
+		{0.000000004, 0},
+		{2.5 / 100, 2500000},
+	}
+
+	for _, tt := range tests {
+		if got := AmountFromFloat(tt.in); got != tt.want {
+			t.Errorf("AmountFromFloat(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAmountFormatting(t *testing.T) {
+	tests := []struct {
+		in     Amount
+		fixed  string
+		string string
+	}{
+		{0, "0.00000000", "0"},
+		{1, "0.00000001", "0.00000001"},
+		{12*BC + BC/2, "12.50000000", "12.5"},
+		{-(BC + 1), "-1.00000001", "-1.00000001"},
+		{100 * BC, "100.00000000", "100"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.Fixed(); got != tt.fixed {
+			t.Errorf("Amount(%d).Fixed() = %s, want %s", int64(tt.in), got, tt.fixed)
+		}
+		if got := tt.in.String(); got != tt.string {
+			t.Errorf("Amount(%d).String() = %s, want %s", int64(tt.in), got, tt.string)
+		}
+		if parsed, err := ParseAmount(tt.in.String()); err != nil || parsed != tt.in {
+			t.Errorf("ParseAmount(%s) = %d, %v; want %d", tt.in, parsed, err, int64(tt.in))
+		}
+	}
+}
+
+func TestAmountAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Amount
+		want    Amount
+		wantErr bool
+	}{
+		{"positive", BC, 2 * BC, 3 * BC, false},
+		{"negative", -BC, -2 * BC, -3 * BC, false},
+		{"mixed signs", math.MaxInt64, math.MinInt64, -1, false},
+		{"up to the maximum", math.MaxInt64 - 1, 1, math.MaxInt64, false},
+		{"past the maximum", math.MaxInt64, 1, 0, true},
+		{"past the minimum", math.MinInt64, -1, 0, true},
+		{"two large amounts", 1 << 62, 1 << 62, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Add err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Add = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{`12.5`, 12*BC + BC/2, false},
+		{`"12.5"`, 12*BC + BC/2, false},
+		{`0.1`, BC / 10, false},
+		{`-1`, -BC, false},
+		{`0.000000001`, 0, true},
+		{`"abc"`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var got Amount
+			err := json.Unmarshal([]byte(tt.in), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) err = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	kept := 5 * BC
+	if err := json.Unmarshal([]byte(`null`), &kept); err != nil || kept != 5*BC {
+		t.Errorf("Unmarshal(null) = %d, %v; want the amount unchanged", kept, err)
+	}
+
+	data, err := json.Marshal(struct{ Amount Amount }{12*BC + BC/2})
+	if err != nil || string(data) != `{"Amount":12.5}` {
+		t.Errorf("Marshal = %s, %v; want {\"Amount\":12.5}", data, err)
+	}
+}
+
+func TestAmountBSON(t *testing.T) {
+	type record struct {
+		Amount Amount `bson:"amount"`
+	}
+
+	tests := []struct {
+		name    string
+		stored  interface{}
+		want    Amount
+		wantErr bool
+	}{
+		{"base units", int64(1250000000), 12*BC + BC/2, false},
+		{"int32 base units", int32(5), 5, false},
+		{"legacy double", 12.5, 12*BC + BC/2, false},
+		{"legacy double rounding", 0.1 + 0.2, 30000000, false},
+		{"legacy negative double", -2.5, -(2*BC + BC/2), false},
+		{"null", nil, 0, false},
+		{"string", "12.5", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := bson.Marshal(bson.M{"amount": tt.stored})
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var got record
+			err = bson.Unmarshal(data, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal err = %v, want error %v", err, tt.wantErr)
+			}
+			if got.Amount != tt.want {
+				t.Errorf("Unmarshal = %d, want %d", got.Amount, tt.want)
+			}
+		})
+	}
+
+	data, err := bson.Marshal(record{Amount: 12*BC + BC/2})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if value := bson.Raw(data).Lookup("amount"); value.Type != bson.TypeInt64 || value.Int64() != 1250000000 {
+		t.Errorf("stored amount = %v, want int64 1250000000", value)
+	}
+}
@@ -6,76 +6,138 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID            string    `bson:"_id,omitempty" json:"id"`
-	FullName      string    `bson:"fullName" json:"fullName"`
-	Email         string    `bson:"email" json:"email"`
-	Password      string    `bson:"password" json:"-"` // Hashed password, not sent in JSON
-	CNIC          string    `bson:"cnic" json:"cnic"`
-	WalletID      string    `bson:"walletId" json:"walletId"`
-	PublicKey     string    `bson:"publicKey" json:"publicKey"`
-	PrivateKey    string    `bson:"privateKey" json:"privateKey"` // Encrypted
-	Beneficiaries []string  `bson:"beneficiaries" json:"beneficiaries"`
-	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
-	UpdatedAt     time.Time `bson:"updatedAt" json:"updatedAt"`
-	ZakatTracking ZakatInfo `bson:"zakatTracking" json:"zakatTracking"`
+	ID            string      `bson:"_id,omitempty" json:"id"`
+	FullName      string      `bson:"fullName" json:"fullName"`
+	Email         string      `bson:"email" json:"email"`
+	Password      string      `bson:"password" json:"-"` // Hashed password, not sent in JSON
+	CNIC          string      `bson:"cnic" json:"cnic"`
+	WalletID      string      `bson:"walletId" json:"walletId"`
+	PublicKey     string      `bson:"publicKey" json:"publicKey"`
+	PrivateKey    string      `bson:"privateKey" json:"privateKey"` // Encrypted
+	Beneficiaries []string    `bson:"beneficiaries" json:"beneficiaries"`
+	CreatedAt     time.Time   `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time   `bson:"updatedAt" json:"updatedAt"`
+	ZakatTracking ZakatInfo   `bson:"zakatTracking" json:"zakatTracking"`
+	HDAccounts    []HDAccount `bson:"hdAccounts,omitempty" json:"hdAccounts,omitempty"` // Set once an HD wallet is created
+}
+
+// HDAccount is a sub-account of a user's HD wallet. The server keeps only the account's
+// extended public key, from which it derives receiving addresses.
+type HDAccount struct {
+	Index             uint32 `bson:"index" json:"index"`
+	Name              string `bson:"name" json:"name"`
+	ExtendedPublicKey string `bson:"extendedPublicKey" json:"extendedPublicKey"`
+	NextAddressIndex  uint32 `bson:"nextAddressIndex" json:"nextAddressIndex"`
 }
 
 // ZakatInfo tracks zakat deductions for a user
 type ZakatInfo struct {
 	LastDeduction   time.Time `bson:"lastDeduction" json:"lastDeduction"`
-	TotalDeducted   float64   `bson:"totalDeducted" json:"totalDeducted"`
-	MonthlyDeducted float64   `bson:"monthlyDeducted" json:"monthlyDeducted"`
+	TotalDeducted   Amount    `bson:"totalDeducted" json:"totalDeducted"`
+	MonthlyDeducted Amount    `bson:"monthlyDeducted" json:"monthlyDeducted"`
 }
 
 // Wallet represents a cryptocurrency wallet
 type Wallet struct {
-	WalletID  string    `bson:"walletId" json:"walletId"`
-	UserID    string    `bson:"userId" json:"userId"`
-	PublicKey string    `bson:"publicKey" json:"publicKey"`
-	Balance   float64   `bson:"balance" json:"balance"` // Cached balance
-	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
-	IsActive  bool      `bson:"isActive" json:"isActive"`
+	WalletID       string    `bson:"walletId" json:"walletId"`
+	UserID         string    `bson:"userId" json:"userId"`
+	PublicKey      string    `bson:"publicKey" json:"publicKey"`
+	Balance        Amount    `bson:"balance" json:"balance"` // Cached balance
+	CreatedAt      time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time `bson:"updatedAt" json:"updatedAt"`
+	IsActive       bool      `bson:"isActive" json:"isActive"`
+	Label          string    `bson:"label,omitempty" json:"label,omitempty"`
+	DerivationPath string    `bson:"derivationPath,omitempty" json:"derivationPath,omitempty"` // HD addresses only
+	Multisig       *Multisig `bson:"multisig,omitempty" json:"multisig,omitempty"`             // Multisig wallets only; PublicKey holds the encoded policy
+}
+
+// Multisig describes the co-signers of an M-of-N wallet
+type Multisig struct {
+	Threshold  int      `bson:"threshold" json:"threshold"`
+	PublicKeys []string `bson:"publicKeys" json:"publicKeys"` // Sorted PEM public keys
 }
 
 // UTXO represents an Unspent Transaction Output
 type UTXO struct {
-	ID              string    `bson:"_id,omitempty" json:"id"`
-	TransactionHash string    `bson:"transactionHash" json:"transactionHash"`
-	OutputIndex     int       `bson:"outputIndex" json:"outputIndex"`
-	WalletID        string    `bson:"walletId" json:"walletId"`
-	Amount          float64   `bson:"amount" json:"amount"`
-	Spent           bool      `bson:"spent" json:"spent"`
-	SpentInTxHash   string    `bson:"spentInTxHash,omitempty" json:"spentInTxHash,omitempty"`
-	CreatedAt       time.Time `bson:"createdAt" json:"createdAt"`
-	SpentAt         time.Time `bson:"spentAt,omitempty" json:"spentAt,omitempty"`
+	ID              string        `bson:"_id,omitempty" json:"id"`
+	TransactionHash string        `bson:"transactionHash" json:"transactionHash"`
+	OutputIndex     int           `bson:"outputIndex" json:"outputIndex"`
+	WalletID        string        `bson:"walletId" json:"walletId"`
+	Amount          Amount        `bson:"amount" json:"amount"`
+	Spent           bool          `bson:"spent" json:"spent"`
+	SpentInTxHash   string        `bson:"spentInTxHash,omitempty" json:"spentInTxHash,omitempty"`
+	CreatedAt       time.Time     `bson:"createdAt" json:"createdAt"`
+	SpentAt         time.Time     `bson:"spentAt,omitempty" json:"spentAt,omitempty"`
+	CoinbaseHeight  int64         `bson:"coinbaseHeight,omitempty" json:"coinbaseHeight,omitempty"` // Height of the coinbase that created it; 0 otherwise
+	Lock            *HashTimeLock `bson:"lock,omitempty" json:"lock,omitempty"`                     // Escrow outputs: spendable only under these conditions
+	Script          string        `bson:"script,omitempty" json:"script,omitempty"`                 // Hex locking script; empty locks it to the wallet's key
+}
+
+// HashTimeLock locks an escrow output to a secret and a deadline. The claim wallet can
+// spend it by revealing the preimage of HashLock before Timeout; from Timeout on the
+// refund wallet can take it back.
+type HashTimeLock struct {
+	HashLock       string `bson:"hashLock" json:"hashLock"` // Hex SHA-256 of the secret
+	ClaimWalletID  string `bson:"claimWalletId" json:"claimWalletId"`
+	RefundWalletID string `bson:"refundWalletId" json:"refundWalletId"`
+	Timeout        int64  `bson:"timeout" json:"timeout"` // Block height, or Unix time from 500000000
 }
 
 // Transaction represents a blockchain transaction
 type Transaction struct {
-	Hash             string       `bson:"hash" json:"hash"`
-	SenderWalletID   string       `bson:"senderWalletId" json:"senderWalletId"`
-	ReceiverWalletID string       `bson:"receiverWalletId" json:"receiverWalletId"`
-	Amount           float64      `bson:"amount" json:"amount"`
-	Note             string       `bson:"note,omitempty" json:"note,omitempty"`
-	Timestamp        time.Time    `bson:"timestamp" json:"timestamp"`
-	SenderPublicKey  string       `bson:"senderPublicKey" json:"senderPublicKey"`
-	Signature        string       `bson:"signature" json:"signature"`
-	InputUTXOs       []string     `bson:"inputUtxos" json:"inputUtxos"`   // UTXO IDs being spent
-	OutputUTXOs      []UTXOOutput `bson:"outputUtxos" json:"outputUtxos"` // New UTXOs created
-	Type             string       `bson:"type" json:"type"`               // "transfer", "zakat_deduction", "mining_reward"
-	Status           string       `bson:"status" json:"status"`           // "pending", "confirmed", "failed"
-	BlockHash        string       `bson:"blockHash,omitempty" json:"blockHash,omitempty"`
+	Version          int            `bson:"version" json:"version"` // 0 = legacy payload signature, 1 = canonical hash signature, 2 = with lock time, 3 = with escrow locks, 4 = with locking scripts, 5 = with output notes
+	Hash             string         `bson:"hash" json:"hash"`
+	SenderWalletID   string         `bson:"senderWalletId" json:"senderWalletId"`
+	ReceiverWalletID string         `bson:"receiverWalletId" json:"receiverWalletId"`
+	Amount           Amount         `bson:"amount" json:"amount"`
+	Fee              Amount         `bson:"fee" json:"fee"` // Inputs minus outputs, paid to the block's miner
+	Note             string         `bson:"note,omitempty" json:"note,omitempty"`
+	Timestamp        time.Time      `bson:"timestamp" json:"timestamp"`
+	LockTime         int64          `bson:"lockTime,omitempty" json:"lockTime,omitempty"` // Block height, or Unix time from 500000000, before which it cannot be mined
+	SenderPublicKey  string         `bson:"senderPublicKey" json:"senderPublicKey"`
+	Signature        string         `bson:"signature" json:"signature"`
+	Signatures       []KeySignature `bson:"signatures,omitempty" json:"signatures,omitempty"`             // Multisig spends: one per co-signer
+	Preimage         string         `bson:"preimage,omitempty" json:"preimage,omitempty"`                 // Escrow claims: hex secret whose SHA-256 is the hash lock
+	UnlockingScripts []string       `bson:"unlockingScripts,omitempty" json:"unlockingScripts,omitempty"` // Hex unlocking script per input; empty entries use the signature
+	InputUTXOs       []string       `bson:"inputUtxos" json:"inputUtxos"`                                 // UTXO IDs being spent
+	OutputUTXOs      []UTXOOutput   `bson:"outputUtxos" json:"outputUtxos"`                               // New UTXOs created
+	Type             string         `bson:"type" json:"type"`                                             // "transfer", "zakat_deduction", "mining_reward", "escrow", "escrow_claim", "escrow_refund"
+	Status           string         `bson:"status" json:"status"`                                         // "pending", "confirmed", "failed"
+	BlockHash        string         `bson:"blockHash,omitempty" json:"blockHash,omitempty"`
+}
+
+// KeySignature is a co-signer's signature on a multisig spend
+type KeySignature struct {
+	KeyIndex  int    `bson:"keyIndex" json:"keyIndex"` // Position of the signing key in the wallet's policy
+	Signature string `bson:"signature" json:"signature"`
+}
+
+// MultisigProposal is a spend from a multisig wallet collecting co-signer signatures
+type MultisigProposal struct {
+	ID          string         `bson:"_id" json:"id"`
+	WalletID    string         `bson:"walletId" json:"walletId"`
+	Transaction Transaction    `bson:"transaction" json:"transaction"` // Unsigned; its hash is what co-signers sign
+	Signatures  []KeySignature `bson:"signatures" json:"signatures"`
+	Threshold   int            `bson:"threshold" json:"threshold"`
+	Status      string         `bson:"status" json:"status"` // "open", "submitted", "failed"
+	Error       string         `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedBy   string         `bson:"createdBy" json:"createdBy"`
+	CreatedAt   time.Time      `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time      `bson:"updatedAt" json:"updatedAt"`
 }
 
 // UTXOOutput represents a new UTXO created in a transaction
 type UTXOOutput struct {
-	WalletID string  `bson:"walletId" json:"walletId"`
-	Amount   float64 `bson:"amount" json:"amount"`
+	WalletID string        `bson:"walletId" json:"walletId"`
+	Amount   Amount        `bson:"amount" json:"amount"`
+	Lock     *HashTimeLock `bson:"lock,omitempty" json:"lock,omitempty"`
+	Script   string        `bson:"script,omitempty" json:"script,omitempty"` // Hex locking script; empty locks it to the wallet's key
+	Note     string        `bson:"note,omitempty" json:"note,omitempty"`     // The receiver's line of a batch payment
 }
 
 // Block represents a block in the blockchain
 type Block struct {
+	Version      int           `bson:"version" json:"version"` // 0 = legacy hex-zero difficulty, 1 = bits difficulty, 2 = binary header hash, 3 = coinbase subsidy, 4 = canonical coinbase
 	Index        int64         `bson:"index" json:"index"`
 	Timestamp    time.Time     `bson:"timestamp" json:"timestamp"`
 	Transactions []Transaction `bson:"transactions" json:"transactions"`
@@ -83,10 +145,36 @@ type Block struct {
 	Nonce        int64         `bson:"nonce" json:"nonce"`
 	Hash         string        `bson:"hash" json:"hash"`
 	MerkleRoot   string        `bson:"merkleRoot" json:"merkleRoot"`
-	Difficulty   int           `bson:"difficulty" json:"difficulty"`
+	Difficulty   int           `bson:"difficulty" json:"difficulty"` // Interpretation depends on Version
 	MinedBy      string        `bson:"minedBy,omitempty" json:"minedBy,omitempty"`
 }
 
+// BlockHeader holds the fields of a block that are hashed for proof-of-work
+type BlockHeader struct {
+	Version      int       `json:"version"`
+	Index        int64     `json:"index"`
+	PreviousHash string    `json:"previousHash"`
+	MerkleRoot   string    `json:"merkleRoot"`
+	Timestamp    time.Time `json:"timestamp"`
+	Difficulty   int       `json:"difficulty"`
+	Nonce        int64     `json:"nonce"`
+}
+
+// MerkleProofStep is one sibling hash on the path from a transaction to the merkle root
+type MerkleProofStep struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // "left" or "right" of the running hash
+}
+
+// MerkleProof proves that a transaction is included in a block
+type MerkleProof struct {
+	TransactionHash  string            `json:"transactionHash"`
+	TransactionIndex int               `json:"transactionIndex"`
+	BlockHash        string            `json:"blockHash"`
+	Header           BlockHeader       `json:"header"`
+	Path             []MerkleProofStep `json:"path"`
+}
+
 // SystemLog represents system-wide logs
 type SystemLog struct {
 	ID        string                 `bson:"_id,omitempty" json:"id"`
@@ -105,7 +193,7 @@ type TransactionLog struct {
 	Action          string    `bson:"action" json:"action"` // "sent", "received", "mined", "zakat_deducted"
 	UserID          string    `bson:"userId" json:"userId"`
 	WalletID        string    `bson:"walletId" json:"walletId"`
-	Amount          float64   `bson:"amount" json:"amount"`
+	Amount          Amount    `bson:"amount" json:"amount"`
 	BlockHash       string    `bson:"blockHash,omitempty" json:"blockHash,omitempty"`
 	Status          string    `bson:"status" json:"status"`
 	IPAddress       string    `bson:"ipAddress,omitempty" json:"ipAddress,omitempty"`
@@ -118,9 +206,9 @@ type ZakatDeduction struct {
 	ID              string    `bson:"_id,omitempty" json:"id"`
 	UserID          string    `bson:"userId" json:"userId"`
 	WalletID        string    `bson:"walletId" json:"walletId"`
-	Amount          float64   `bson:"amount" json:"amount"`
-	BalanceBefore   float64   `bson:"balanceBefore" json:"balanceBefore"`
-	BalanceAfter    float64   `bson:"balanceAfter" json:"balanceAfter"`
+	Amount          Amount    `bson:"amount" json:"amount"`
+	BalanceBefore   Amount    `bson:"balanceBefore" json:"balanceBefore"`
+	BalanceAfter    Amount    `bson:"balanceAfter" json:"balanceAfter"`
 	TransactionHash string    `bson:"transactionHash" json:"transactionHash"`
 	BlockHash       string    `bson:"blockHash,omitempty" json:"blockHash,omitempty"`
 	Month           string    `bson:"month" json:"month"` // "2024-12"
@@ -128,6 +216,36 @@ type ZakatDeduction struct {
 	Status          string    `bson:"status" json:"status"` // "pending", "completed", "failed"
 }
 
+// ScheduledTransfer is a future or recurring payment from a user's registration wallet,
+// signed with the user's stored key when it falls due
+type ScheduledTransfer struct {
+	ID               string                 `bson:"_id" json:"id"`
+	UserID           string                 `bson:"userId" json:"userId"`
+	ReceiverWalletID string                 `bson:"receiverWalletId" json:"receiverWalletId"`
+	Amount           Amount                 `bson:"amount" json:"amount"`
+	Fee              Amount                 `bson:"fee" json:"fee"` // 0 pays the minimum fee at the time of each run
+	Note             string                 `bson:"note,omitempty" json:"note,omitempty"`
+	Frequency        string                 `bson:"frequency" json:"frequency"`         // "once", "daily", "weekly", "monthly"
+	ServerSigning    bool                   `bson:"serverSigning" json:"serverSigning"` // The user agreed to runs being signed with their stored key
+	StartAt          time.Time              `bson:"startAt" json:"startAt"`
+	EndAt            *time.Time             `bson:"endAt,omitempty" json:"endAt,omitempty"` // Recurring transfers stop after this time
+	NextRunAt        time.Time              `bson:"nextRunAt" json:"nextRunAt"`
+	RunCount         int                    `bson:"runCount" json:"runCount"`
+	Runs             []ScheduledTransferRun `bson:"runs" json:"runs"`     // Most recent runs, oldest first
+	Status           string                 `bson:"status" json:"status"` // "active", "completed", "cancelled", "failed"
+	CreatedAt        time.Time              `bson:"createdAt" json:"createdAt"`
+	UpdatedAt        time.Time              `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ScheduledTransferRun records the outcome of one run of a scheduled transfer
+type ScheduledTransferRun struct {
+	DueAt           time.Time `bson:"dueAt" json:"dueAt"`
+	RanAt           time.Time `bson:"ranAt" json:"ranAt"`
+	TransactionHash string    `bson:"transactionHash,omitempty" json:"transactionHash,omitempty"`
+	Status          string    `bson:"status" json:"status"` // "running", "submitted", "failed"
+	Error           string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
 // PendingTransaction represents transactions waiting to be mined
 type PendingTransaction struct {
 	Transaction Transaction `bson:"transaction" json:"transaction"`
@@ -46,6 +46,8 @@ func SetupRoutes(r *gin.Engine) {
 
 			// Transaction (public read)
 			public.GET("/transaction/:hash", handlers.GetTransactionByHash)
+			public.GET("/transaction/:hash/wait", handlers.WaitForTransaction)
+			public.GET("/transaction/:hash/proof", handlers.GetTransactionProof)
 			public.GET("/transactions/pending", handlers.GetPendingTransactions)
 		}
 
@@ -67,17 +69,43 @@ func SetupRoutes(r *gin.Engine) {
 			protected.GET("/balance", handlers.GetBalance)
 			protected.GET("/wallet/utxos", handlers.GetWalletUTXOs)
 
+			// HD wallet and receiving addresses
+			protected.POST("/wallet/hd", handlers.CreateHDWallet)
+			protected.GET("/wallet/addresses", handlers.GetAddresses)
+			protected.POST("/wallet/addresses", handlers.CreateAddress)
+
+			// Multisig wallets and co-signed spend proposals
+			protected.POST("/multisig", handlers.CreateMultisigWallet)
+			protected.GET("/multisig", handlers.GetMultisigWallets)
+			protected.GET("/multisig/:walletId", handlers.GetMultisigWallet)
+			protected.GET("/multisig-proposals/:id", handlers.GetMultisigProposal)
+
+			// Hash-time-locked escrow payments
+			protected.GET("/escrow", handlers.GetEscrows)
+
 			// Transactions with separate rate limiter
 			transactions := protected.Group("/")
 			transactions.Use(transactionLimiter.RateLimit())
 			{
-				transactions.POST("/transaction", handlers.CreateTransaction)
-				transactions.POST("/mine", handlers.MineBlockManual)
+				transactions.POST("/transaction", handlers.CreateTransaction) // Retired: 410 Gone
+				transactions.POST("/transaction/build", handlers.BuildTransaction)
+				transactions.POST("/transaction/batch/build", handlers.BuildBatch)
+				transactions.POST("/transaction/submit", handlers.SubmitTransaction)
+				transactions.POST("/multisig/:walletId/proposals", handlers.ProposeMultisigSpend)
+				transactions.POST("/multisig-proposals/:id/signatures", handlers.SignMultisigProposal)
+				transactions.POST("/escrow/build", handlers.BuildEscrow)
+				transactions.POST("/escrow/:utxoId/claim", handlers.ClaimEscrow)
+				transactions.POST("/escrow/:utxoId/refund", handlers.RefundEscrow)
 			}
 
 			// Transaction history (read-only, less restrictive)
 			protected.GET("/transactions", handlers.GetTransactionHistory)
 
+			// Scheduled and recurring transfers
+			protected.GET("/scheduled-transfers", handlers.GetScheduledTransfers)
+			protected.POST("/scheduled-transfers", handlers.CreateScheduledTransfer)
+			protected.DELETE("/scheduled-transfers/:id", handlers.CancelScheduledTransfer)
+
 			// Zakat
 			protected.GET("/zakat/history", handlers.GetZakatHistory)
 			protected.GET("/zakat/summary", handlers.GetZakatSummary)
@@ -91,6 +119,18 @@ func SetupRoutes(r *gin.Engine) {
 			// Reports
 			protected.GET("/reports", handlers.GetReports)
 		}
+
+		// Admin routes (authentication and ADMIN_USER_IDS required)
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware())
+		admin.Use(middleware.AdminMiddleware())
+		admin.Use(apiLimiter.RateLimit())
+		{
+			admin.GET("/blockchain/validate", handlers.ValidateBlockchainDeep)
+			admin.POST("/reindex", handlers.ReindexUTXOs)
+			admin.POST("/reencrypt-keys", handlers.ReencryptPrivateKeys)
+			admin.POST("/mine", handlers.MineBlockManual)
+		}
 	}
 
 	// Health check
@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"backend/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminRoutesRequireAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := services.GetStore()
+	services.SetStore(services.NewMemoryStore())
+	t.Cleanup(func() { services.SetStore(previous) })
+
+	r := gin.New()
+	SetupRoutes(r)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/api/blockchain/validate?deep=true", http.StatusForbidden},
+		{http.MethodGet, "/api/admin/blockchain/validate", http.StatusUnauthorized},
+		{http.MethodPost, "/api/admin/reindex", http.StatusUnauthorized},
+		{http.MethodPost, "/api/admin/reencrypt-keys", http.StatusUnauthorized},
+		{http.MethodPost, "/api/admin/mine", http.StatusUnauthorized},
+		{http.MethodPost, "/api/mine", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Checker supplies the parts of evaluation that depend on the spending transaction
+type Checker interface {
+	// CheckSignature reports whether signature is a valid signature by publicKey over
+	// the spending transaction
+	CheckSignature(signature, publicKey []byte) bool
+
+	// CheckLockTime reports whether the spending transaction cannot be mined before
+	// lockTime, a block height or Unix time like the transaction's own lock time
+	CheckLockTime(lockTime int64) bool
+}
+
+// Execute checks that an unlocking script satisfies a locking script. The unlocking
+// script may only push data. Both scripts share the step limit, and each must close the
+// conditionals it opens.
+func Execute(unlocking, locking []byte, checker Checker) error {
+	unlockingInstructions, err := Parse(unlocking)
+	if err != nil {
+		return fmt.Errorf("unlocking script: %w", err)
+	}
+	for _, ins := range unlockingInstructions {
+		if !ins.isPush() {
+			return ErrNotPushOnly
+		}
+	}
+
+	lockingInstructions, err := Parse(locking)
+	if err != nil {
+		return fmt.Errorf("locking script: %w", err)
+	}
+
+	e := &engine{checker: checker}
+	if err := e.run(unlockingInstructions); err != nil {
+		return fmt.Errorf("unlocking script: %w", err)
+	}
+	if err := e.run(lockingInstructions); err != nil {
+		return err
+	}
+
+	if len(e.stack) == 0 || !asBool(e.stack[len(e.stack)-1]) {
+		return ErrScriptFalse
+	}
+	return nil
+}
+
+// engine is the state of one evaluation
+type engine struct {
+	stack   [][]byte
+	steps   int
+	checker Checker
+}
+
+// run executes instructions on the engine's stack
+func (e *engine) run(instructions []Instruction) error {
+	// branches holds whether each open IF/ELSE branch is taken; instructions only run
+	// while every enclosing branch is taken
+	var branches []bool
+
+	for _, ins := range instructions {
+		if err := e.step(1); err != nil {
+			return err
+		}
+
+		executing := true
+		for _, taken := range branches {
+			executing = executing && taken
+		}
+
+		switch ins.Op {
+		case OpIf, OpNotIf:
+			taken := false
+			if executing {
+				value, err := e.pop()
+				if err != nil {
+					return err
+				}
+				taken = asBool(value) == (ins.Op == OpIf)
+			}
+			branches = append(branches, taken)
+			continue
+		case OpElse:
+			if len(branches) == 0 {
+				return ErrUnbalancedIf
+			}
+			branches[len(branches)-1] = !branches[len(branches)-1]
+			continue
+		case OpEndIf:
+			if len(branches) == 0 {
+				return ErrUnbalancedIf
+			}
+			branches = branches[:len(branches)-1]
+			continue
+		}
+
+		if !executing {
+			continue
+		}
+		if err := e.execute(ins); err != nil {
+			return fmt.Errorf("%s: %w", instructionName(ins), err)
+		}
+	}
+
+	if len(branches) != 0 {
+		return ErrUnbalancedIf
+	}
+	return nil
+}
+
+// execute runs one instruction outside of the conditionals
+func (e *engine) execute(ins Instruction) error {
+	switch {
+	case ins.Op >= Op1 && ins.Op <= Op16:
+		return e.push(encodeNumber(int64(ins.Op-Op1) + 1))
+	case ins.isPush():
+		return e.push(ins.Data)
+	}
+
+	switch ins.Op {
+	case OpVerify:
+		value, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(value) {
+			return ErrVerifyFailed
+		}
+
+	case OpReturn:
+		return ErrOpReturn
+
+	case OpDrop:
+		_, err := e.pop()
+		return err
+
+	case OpDup:
+		value, err := e.peek()
+		if err != nil {
+			return err
+		}
+		return e.push(value)
+
+	case OpSwap:
+		if len(e.stack) < 2 {
+			return ErrStackUnderflow
+		}
+		top := len(e.stack) - 1
+		e.stack[top], e.stack[top-1] = e.stack[top-1], e.stack[top]
+
+	case OpSize:
+		value, err := e.peek()
+		if err != nil {
+			return err
+		}
+		return e.push(encodeNumber(int64(len(value))))
+
+	case OpEqual, OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		return e.result(bytes.Equal(a, b), ins.Op == OpEqualVerify)
+
+	case OpSHA256:
+		value, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(value)
+		return e.push(hash[:])
+
+	case OpCheckSig, OpCheckSigVerify:
+		publicKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := e.pop()
+		if err != nil {
+			return err
+		}
+		return e.result(e.checker.CheckSignature(signature, publicKey), ins.Op == OpCheckSigVerify)
+
+	case OpCheckMultisig, OpCheckMultisigVerify:
+		valid, err := e.checkMultisig()
+		if err != nil {
+			return err
+		}
+		return e.result(valid, ins.Op == OpCheckMultisigVerify)
+
+	case OpCheckLockTimeVerify:
+		value, err := e.peek()
+		if err != nil {
+			return err
+		}
+		lockTime, err := decodeNumber(value)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return fmt.Errorf("%w: negative lock time", ErrInvalidNumber)
+		}
+		if !e.checker.CheckLockTime(lockTime) {
+			return ErrLockTime
+		}
+
+	default:
+		return ErrUnknownOpcode
+	}
+	return nil
+}
+
+// checkMultisig pops <sig 1..m> m <key 1..n> n and reports whether the m signatures are
+// valid for m of the keys, in the same order as the keys. Each key counts as a step.
+func (e *engine) checkMultisig() (bool, error) {
+	keyCount, err := e.popNumber(0, MaxMultisigKeys)
+	if err != nil {
+		return false, err
+	}
+	if err := e.step(int(keyCount)); err != nil {
+		return false, err
+	}
+	keys := make([][]byte, keyCount)
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	sigCount, err := e.popNumber(0, keyCount)
+	if err != nil {
+		return false, err
+	}
+	signatures := make([][]byte, sigCount)
+	for i := len(signatures) - 1; i >= 0; i-- {
+		if signatures[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	// Walk the keys once, matching each signature to the next key that verifies it
+	next := 0
+	for _, signature := range signatures {
+		for next < len(keys) && !e.checker.CheckSignature(signature, keys[next]) {
+			next++
+		}
+		if next == len(keys) {
+			return false, nil
+		}
+		next++
+	}
+	return true, nil
+}
+
+// result pushes a comparison result, or fails on false for the VERIFY forms
+func (e *engine) result(ok, verify bool) error {
+	if verify {
+		if !ok {
+			return ErrVerifyFailed
+		}
+		return nil
+	}
+	if ok {
+		return e.push([]byte{1})
+	}
+	return e.push(nil)
+}
+
+// step counts n steps against MaxSteps
+func (e *engine) step(n int) error {
+	e.steps += n
+	if e.steps > MaxSteps {
+		return fmt.Errorf("%w of %d", ErrStepLimit, MaxSteps)
+	}
+	return nil
+}
+
+func (e *engine) push(value []byte) error {
+	if len(value) > MaxElementSize {
+		return ErrElementTooLarge
+	}
+	if len(e.stack) >= MaxStackDepth {
+		return fmt.Errorf("%w of %d", ErrStackDepth, MaxStackDepth)
+	}
+	e.stack = append(e.stack, value)
+	return nil
+}
+
+func (e *engine) pop() ([]byte, error) {
+	value, err := e.peek()
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return value, nil
+}
+
+func (e *engine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	return e.stack[len(e.stack)-1], nil
+}
+
+// popNumber pops a number that must lie between low and high
+func (e *engine) popNumber(low, high int64) (int64, error) {
+	value, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	n, err := decodeNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < low || n > high {
+		return 0, fmt.Errorf("%w: %d is outside %d to %d", ErrInvalidNumber, n, low, high)
+	}
+	return n, nil
+}
+
+// asBool interprets a stack element as a condition: empty, zero and negative zero are
+// false
+func asBool(value []byte) bool {
+	for i, b := range value {
+		if b != 0 {
+			return !(i == len(value)-1 && b == 0x80)
+		}
+	}
+	return false
+}
+
+// instructionName names an instruction for error messages
+func instructionName(ins Instruction) string {
+	if name, ok := opcodeNames[ins.Op]; ok {
+		return name
+	}
+	return fmt.Sprintf("push of %d bytes", len(ins.Data))
+}
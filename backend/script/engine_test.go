@@ -0,0 +1,177 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testChecker accepts a signature "sig:<key>" for a key, and lock times up to lockTime
+type testChecker struct {
+	lockTime int64
+}
+
+func (c testChecker) CheckSignature(signature, publicKey []byte) bool {
+	return bytes.Equal(signature, sign(publicKey))
+}
+
+func (c testChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= c.lockTime
+}
+
+// sign returns the signature testChecker accepts for a key
+func sign(publicKey []byte) []byte {
+	return append([]byte("sig:"), publicKey...)
+}
+
+// scripts fails the test if building a script failed, for use as
+// scripts(t)(PayToPubKeyHash(hash))
+func scripts(t *testing.T) func([]byte, error) []byte {
+	return func(script []byte, err error) []byte {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("building script: %v", err)
+		}
+		return script
+	}
+}
+
+// mustAssemble assembles script text, failing the test on error
+func mustAssemble(t *testing.T, text string) []byte {
+	t.Helper()
+	return scripts(t)(Assemble(text))
+}
+
+// hexOf writes data as an Assemble push
+func hexOf(data []byte) string {
+	return "0x" + hex.EncodeToString(data)
+}
+
+// repeat joins n copies of an instruction sequence
+func repeat(text string, n int) string {
+	return strings.TrimSpace(strings.Repeat(text+" ", n))
+}
+
+func TestExecute(t *testing.T) {
+	must := scripts(t)
+	alice, bob, carol := []byte("alice-key"), []byte("bob-key"), []byte("carol-key")
+	aliceHash := sha256.Sum256(alice)
+	p2pkh := must(PayToPubKeyHash(aliceHash[:]))
+
+	multisig := NewBuilder().AddInt(2).AddData(alice).AddData(bob).AddData(carol).AddInt(3).AddOp(OpCheckMultisig)
+	twoOfThree := must(multisig.Script())
+	signatures := func(keys ...[]byte) []byte {
+		b := NewBuilder()
+		for _, key := range keys {
+			b.AddData(sign(key))
+		}
+		return must(b.Script())
+	}
+
+	largest := NewBuilder().AddData(make([]byte, MaxElementSize))
+	largestPush := must(largest.Script())
+	tooLargePush := append([]byte{byte(OpPushData2), byte((MaxElementSize + 1) & 0xff), byte((MaxElementSize + 1) >> 8)}, make([]byte, MaxElementSize+1)...)
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		locking   []byte
+		want      error
+	}{
+		// Pay to pubkey hash
+		{"p2pkh", must(SignatureUnlock(sign(alice), alice)), p2pkh, nil},
+		{"p2pkh with another key", must(SignatureUnlock(sign(bob), bob)), p2pkh, ErrVerifyFailed},
+		{"p2pkh with a bad signature", must(SignatureUnlock(sign(bob), alice)), p2pkh, ErrScriptFalse},
+		{"p2pkh with nothing", nil, p2pkh, ErrStackUnderflow},
+
+		// Multisig
+		{"2 of 3 multisig", signatures(alice, carol), twoOfThree, nil},
+		{"2 of 3 multisig out of key order", signatures(carol, alice), twoOfThree, ErrScriptFalse},
+		{"2 of 3 multisig with a repeated signature", signatures(alice, alice), twoOfThree, ErrScriptFalse},
+		{"2 of 3 multisig with one signature", signatures(alice), twoOfThree, ErrStackUnderflow},
+		{"multisig verify", signatures(bob), mustAssemble(t, "1 "+hexOf(bob)+" 1 OP_CHECKMULTISIGVERIFY 1"), nil},
+		{"multisig with too many keys", nil, mustAssemble(t, "0 "+repeat("0x01", 16)+" 16 OP_CHECKMULTISIG"), ErrInvalidNumber},
+
+		// Step limit
+		{"exactly MaxSteps", mustAssemble(t, "1"), mustAssemble(t, repeat("OP_DUP OP_DROP", (MaxSteps-2)/2)+" OP_DUP"), nil},
+		{"one step over MaxSteps", mustAssemble(t, "1"), mustAssemble(t, repeat("OP_DUP OP_DROP", (MaxSteps-2)/2)+" OP_DUP OP_DROP"), ErrStepLimit},
+		{"multisig keys within MaxSteps", signatures(alice), mustAssemble(t, repeat("OP_DUP OP_DROP", 124)+" 1 "+hexOf(alice)+" "+hexOf(bob)+" 2 OP_CHECKMULTISIG"), nil},
+		{"multisig keys count as steps", signatures(alice), mustAssemble(t, repeat("OP_DUP OP_DROP", 125)+" 1 "+hexOf(alice)+" "+hexOf(bob)+" 2 OP_CHECKMULTISIG"), ErrStepLimit},
+
+		// Element size
+		{"element of MaxElementSize", largestPush, mustAssemble(t, "OP_SIZE 10240 OP_EQUALVERIFY OP_DROP 1"), nil},
+		{"element over MaxElementSize", tooLargePush, mustAssemble(t, "1"), ErrElementTooLarge},
+
+		// Stack underflow
+		{"drop from an empty stack", nil, mustAssemble(t, "OP_DROP 1"), ErrStackUnderflow},
+		{"swap one item", mustAssemble(t, "1"), mustAssemble(t, "OP_SWAP"), ErrStackUnderflow},
+		{"equal with one item", mustAssemble(t, "1"), mustAssemble(t, "OP_EQUAL"), ErrStackUnderflow},
+		{"if on an empty stack", nil, mustAssemble(t, "OP_IF 1 OP_ENDIF"), ErrStackUnderflow},
+
+		// Malformed scripts
+		{"unknown opcode", nil, []byte{0xff}, ErrUnknownOpcode},
+		{"truncated push", nil, []byte{0x05, 0x01, 0x02}, ErrMalformedPush},
+		{"truncated PUSHDATA1 length", nil, []byte{byte(OpPushData1)}, ErrMalformedPush},
+		{"non-minimal push", nil, []byte{byte(OpPushData1), 0x01, 0xaa}, ErrMalformedPush},
+		{"unlocking script that is not push-only", mustAssemble(t, "1 OP_DUP"), mustAssemble(t, "1"), ErrNotPushOnly},
+		{"OP_RETURN", mustAssemble(t, "1"), mustAssemble(t, "OP_RETURN"), ErrOpReturn},
+		{"non-minimal number", mustAssemble(t, "0x0100"), mustAssemble(t, "OP_CHECKLOCKTIMEVERIFY"), ErrInvalidNumber},
+
+		// Conditionals
+		{"IF branch", mustAssemble(t, "1"), mustAssemble(t, "OP_IF 1 OP_ELSE 0 OP_ENDIF"), nil},
+		{"ELSE branch", mustAssemble(t, "0"), mustAssemble(t, "OP_IF 0 OP_ELSE 1 OP_ENDIF"), nil},
+		{"NOTIF", mustAssemble(t, "0"), mustAssemble(t, "OP_NOTIF 1 OP_ELSE 0 OP_ENDIF"), nil},
+		{"nested skipped IF", mustAssemble(t, "0"), mustAssemble(t, "OP_IF OP_IF 0 OP_ENDIF OP_ELSE 1 OP_ENDIF"), nil},
+		{"IF without ENDIF", mustAssemble(t, "1"), mustAssemble(t, "OP_IF 1"), ErrUnbalancedIf},
+		{"ELSE without IF", mustAssemble(t, "1"), mustAssemble(t, "OP_ELSE 1"), ErrUnbalancedIf},
+		{"ENDIF without IF", mustAssemble(t, "1"), mustAssemble(t, "OP_ENDIF"), ErrUnbalancedIf},
+		{"IF closed twice", mustAssemble(t, "1"), mustAssemble(t, "OP_IF 1 OP_ENDIF OP_ENDIF"), ErrUnbalancedIf},
+
+		// Lock time
+		{"lock time reached", nil, mustAssemble(t, "100 OP_CHECKLOCKTIMEVERIFY OP_DROP 1"), nil},
+		{"lock time not reached", nil, mustAssemble(t, "101 OP_CHECKLOCKTIMEVERIFY OP_DROP 1"), ErrLockTime},
+
+		// Result
+		{"false result", nil, mustAssemble(t, "0"), ErrScriptFalse},
+		{"empty stack", nil, nil, ErrScriptFalse},
+		{"negative zero is false", mustAssemble(t, "0x80"), nil, ErrScriptFalse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Execute(tt.unlocking, tt.locking, testChecker{lockTime: 100})
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Execute: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Execute: err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembleRoundTrip(t *testing.T) {
+	text := "OP_DUP OP_SHA256 " + hexOf([]byte("hash")) + " OP_EQUALVERIFY OP_CHECKSIG 0 16 1000"
+	script := mustAssemble(t, text)
+
+	disassembled, err := Disassemble(script)
+	if err != nil {
+		t.Fatalf("Disassemble: %v", err)
+	}
+	if again := mustAssemble(t, disassembled); !bytes.Equal(again, script) {
+		t.Errorf("%q assembles to different bytes after a round trip", disassembled)
+	}
+
+	if _, err := Assemble("OP_NOPE"); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("unknown opcode name: err = %v, want ErrUnknownOpcode", err)
+	}
+	if _, err := Assemble("0x" + strings.Repeat("00", MaxElementSize+1)); !errors.Is(err, ErrElementTooLarge) {
+		t.Errorf("oversized push: err = %v, want ErrElementTooLarge", err)
+	}
+}
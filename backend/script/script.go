@@ -0,0 +1,380 @@
+// Package script implements the small stack language that locks transaction outputs.
+//
+// A locking script on an output states the conditions for spending it; the spending
+// input supplies an unlocking script that only pushes data, such as a signature and a
+// public key. The unlocking script runs first and the locking script then runs on the
+// stack it leaves; the output is unlocked if that ends with a true value on top.
+//
+// The language has no loops or jumps, only forward IF/ELSE branches, and evaluation is
+// bounded by MaxScriptSize, MaxElementSize, MaxStackDepth and MaxSteps.
+package script
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits on script size and evaluation
+const (
+	MaxScriptSize   = 16384 // Bytes in a locking or unlocking script
+	MaxElementSize  = 10240 // Bytes in one stack element; fits a multisig policy of 15 RSA keys
+	MaxStackDepth   = 256   // Items on the stack
+	MaxSteps        = 256   // Opcodes processed per evaluation, counting each multisig key
+	MaxMultisigKeys = 15    // Keys in one OP_CHECKMULTISIG
+	maxNumberSize   = 5     // Bytes in a number operand; enough for Unix lock times
+)
+
+// Errors returned when parsing or evaluating scripts
+var (
+	ErrScriptTooLarge  = errors.New("script exceeds the maximum size")
+	ErrMalformedPush   = errors.New("malformed data push")
+	ErrUnknownOpcode   = errors.New("unknown opcode")
+	ErrElementTooLarge = errors.New("stack element exceeds the maximum size")
+	ErrStepLimit       = errors.New("script exceeds the step limit")
+	ErrStackDepth      = errors.New("script exceeds the stack depth limit")
+	ErrStackUnderflow  = errors.New("not enough items on the stack")
+	ErrUnbalancedIf    = errors.New("unbalanced IF/ELSE/ENDIF")
+	ErrNotPushOnly     = errors.New("unlocking scripts may only push data")
+	ErrInvalidNumber   = errors.New("invalid number")
+	ErrVerifyFailed    = errors.New("verify failed")
+	ErrOpReturn        = errors.New("OP_RETURN executed")
+	ErrLockTime        = errors.New("spending transaction lock time is below the script lock time")
+	ErrScriptFalse     = errors.New("script evaluated to false")
+)
+
+// Opcode is one script instruction. Bytes 0x01-0x4b push that many bytes of data.
+type Opcode byte
+
+// Opcodes. The values follow Bitcoin script, of which this is a small subset.
+const (
+	Op0                   Opcode = 0x00 // Push an empty element (false, 0)
+	OpPushData1           Opcode = 0x4c // Push data with a 1-byte length
+	OpPushData2           Opcode = 0x4d // Push data with a 2-byte little-endian length
+	Op1                   Opcode = 0x51 // Op1 to Op16 push the numbers 1 to 16
+	Op16                  Opcode = 0x60
+	OpIf                  Opcode = 0x63
+	OpNotIf               Opcode = 0x64
+	OpElse                Opcode = 0x67
+	OpEndIf               Opcode = 0x68
+	OpVerify              Opcode = 0x69
+	OpReturn              Opcode = 0x6a
+	OpDrop                Opcode = 0x75
+	OpDup                 Opcode = 0x76
+	OpSwap                Opcode = 0x7c
+	OpSize                Opcode = 0x82
+	OpEqual               Opcode = 0x87
+	OpEqualVerify         Opcode = 0x88
+	OpSHA256              Opcode = 0xa8
+	OpCheckSig            Opcode = 0xac
+	OpCheckSigVerify      Opcode = 0xad
+	OpCheckMultisig       Opcode = 0xae
+	OpCheckMultisigVerify Opcode = 0xaf
+	OpCheckLockTimeVerify Opcode = 0xb1
+)
+
+// opcodeNames are the text names of the opcodes other than data pushes
+var opcodeNames = map[Opcode]string{
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSHA256:              "OP_SHA256",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultisig:       "OP_CHECKMULTISIG",
+	OpCheckMultisigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+// opcodesByName maps text names back to opcodes for Assemble
+var opcodesByName = func() map[string]Opcode {
+	byName := make(map[string]Opcode, len(opcodeNames))
+	for op, name := range opcodeNames {
+		byName[name] = op
+	}
+	return byName
+}()
+
+// Instruction is a parsed opcode with the data it pushes
+type Instruction struct {
+	Op   Opcode
+	Data []byte
+}
+
+// isPush reports whether an instruction only pushes data or a small number
+func (ins Instruction) isPush() bool {
+	return ins.Op <= OpPushData2 || (ins.Op >= Op1 && ins.Op <= Op16)
+}
+
+// Parse splits a script into instructions. Every opcode must be known and every data
+// push must use the shortest encoding, so each script has exactly one byte form.
+func Parse(script []byte) ([]Instruction, error) {
+	if len(script) > MaxScriptSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrScriptTooLarge, len(script), MaxScriptSize)
+	}
+
+	var instructions []Instruction
+	for pos := 0; pos < len(script); {
+		op := Opcode(script[pos])
+		pos++
+
+		size := -1
+		switch {
+		case op == Op0 || (op >= Op1 && op <= Op16):
+			instructions = append(instructions, Instruction{Op: op})
+			continue
+		case op < OpPushData1:
+			size = int(op)
+		case op == OpPushData1:
+			if pos+1 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(script[pos])
+			pos++
+		case op == OpPushData2:
+			if pos+2 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(script[pos]) | int(script[pos+1])<<8
+			pos += 2
+		}
+
+		if size < 0 {
+			if _, ok := opcodeNames[op]; !ok {
+				return nil, fmt.Errorf("%w 0x%02x at byte %d", ErrUnknownOpcode, byte(op), pos-1)
+			}
+			instructions = append(instructions, Instruction{Op: op})
+			continue
+		}
+
+		if pos+size > len(script) {
+			return nil, ErrMalformedPush
+		}
+		if size > MaxElementSize {
+			return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrElementTooLarge, size, MaxElementSize)
+		}
+		if pushOpcode(size) != op {
+			return nil, fmt.Errorf("%w: %d bytes pushed with 0x%02x", ErrMalformedPush, size, byte(op))
+		}
+		instructions = append(instructions, Instruction{Op: op, Data: script[pos : pos+size]})
+		pos += size
+	}
+	return instructions, nil
+}
+
+// pushOpcode returns the shortest push opcode for data of the given size
+func pushOpcode(size int) Opcode {
+	switch {
+	case size == 0:
+		return Op0
+	case size < int(OpPushData1):
+		return Opcode(size)
+	case size <= 0xff:
+		return OpPushData1
+	}
+	return OpPushData2
+}
+
+// Builder assembles a script one instruction at a time. The first error is kept and
+// returned by Script.
+type Builder struct {
+	script []byte
+	err    error
+}
+
+// NewBuilder starts an empty script
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// AddOp appends an opcode that is not a data push
+func (b *Builder) AddOp(op Opcode) *Builder {
+	b.script = append(b.script, byte(op))
+	return b
+}
+
+// AddData appends a push of data using the shortest encoding
+func (b *Builder) AddData(data []byte) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if len(data) > MaxElementSize {
+		b.err = fmt.Errorf("%w: %d bytes, limit %d", ErrElementTooLarge, len(data), MaxElementSize)
+		return b
+	}
+
+	op := pushOpcode(len(data))
+	b.script = append(b.script, byte(op))
+	switch op {
+	case OpPushData1:
+		b.script = append(b.script, byte(len(data)))
+	case OpPushData2:
+		b.script = append(b.script, byte(len(data)), byte(len(data)>>8))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt appends a push of a number, using Op0 to Op16 for small values
+func (b *Builder) AddInt(n int64) *Builder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n >= 1 && n <= 16:
+		return b.AddOp(Op1 + Opcode(n-1))
+	}
+	return b.AddData(encodeNumber(n))
+}
+
+// Script returns the assembled script
+func (b *Builder) Script() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.script) > MaxScriptSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrScriptTooLarge, len(b.script), MaxScriptSize)
+	}
+	return b.script, nil
+}
+
+// PayToPubKeyHash locks an output to the public key whose SHA-256 is hash, which is the
+// hash a wallet address encodes:
+//
+//	OP_DUP OP_SHA256 <hash> OP_EQUALVERIFY OP_CHECKSIG
+func PayToPubKeyHash(hash []byte) ([]byte, error) {
+	return NewBuilder().AddOp(OpDup).AddOp(OpSHA256).AddData(hash).AddOp(OpEqualVerify).AddOp(OpCheckSig).Script()
+}
+
+// SignatureUnlock unlocks a pay-to-pubkey-hash output: <signature> <publicKey>
+func SignatureUnlock(signature, publicKey []byte) ([]byte, error) {
+	return NewBuilder().AddData(signature).AddData(publicKey).Script()
+}
+
+// Assemble compiles the text form of a script: opcode names such as OP_DUP, decimal
+// numbers, and data pushes written as 0x-prefixed hex, separated by whitespace
+func Assemble(text string) ([]byte, error) {
+	b := NewBuilder()
+	for _, token := range strings.Fields(text) {
+		upper := strings.ToUpper(token)
+		if op, ok := opcodesByName[upper]; ok {
+			b.AddOp(op)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(upper, "0X"):
+			data, err := hex.DecodeString(token[2:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex push %q", token)
+			}
+			b.AddData(data)
+		case strings.HasPrefix(upper, "OP_"):
+			n, err := strconv.Atoi(upper[3:])
+			if err != nil || n < 0 || n > 16 {
+				return nil, fmt.Errorf("%w %q", ErrUnknownOpcode, token)
+			}
+			b.AddInt(int64(n))
+		default:
+			n, err := strconv.ParseInt(token, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unrecognised token %q", token)
+			}
+			if len(encodeNumber(n)) > maxNumberSize {
+				return nil, fmt.Errorf("%w: %d is out of range", ErrInvalidNumber, n)
+			}
+			b.AddInt(n)
+		}
+	}
+	return b.Script()
+}
+
+// Disassemble renders a script in the text form Assemble reads
+func Disassemble(script []byte) (string, error) {
+	instructions, err := Parse(script)
+	if err != nil {
+		return "", err
+	}
+
+	tokens := make([]string, 0, len(instructions))
+	for _, ins := range instructions {
+		switch {
+		case ins.Op == Op0:
+			tokens = append(tokens, "0")
+		case ins.Op >= Op1 && ins.Op <= Op16:
+			tokens = append(tokens, strconv.Itoa(int(ins.Op-Op1)+1))
+		case ins.isPush():
+			tokens = append(tokens, "0x"+hex.EncodeToString(ins.Data))
+		default:
+			tokens = append(tokens, opcodeNames[ins.Op])
+		}
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+// encodeNumber encodes n as a minimal little-endian sign-magnitude number
+func encodeNumber(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	magnitude := uint64(n)
+	if negative {
+		magnitude = uint64(-n)
+	}
+
+	var encoded []byte
+	for magnitude > 0 {
+		encoded = append(encoded, byte(magnitude&0xff))
+		magnitude >>= 8
+	}
+
+	// The top bit of the last byte is the sign
+	if encoded[len(encoded)-1]&0x80 != 0 {
+		extra := byte(0)
+		if negative {
+			extra = 0x80
+		}
+		encoded = append(encoded, extra)
+	} else if negative {
+		encoded[len(encoded)-1] |= 0x80
+	}
+	return encoded
+}
+
+// decodeNumber decodes a minimally encoded number of at most maxNumberSize bytes
+func decodeNumber(data []byte) (int64, error) {
+	if len(data) > maxNumberSize {
+		return 0, fmt.Errorf("%w: %d bytes, limit %d", ErrInvalidNumber, len(data), maxNumberSize)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	// The last byte may only be a sign byte if the one before needs its top bit
+	last := data[len(data)-1]
+	if last&0x7f == 0 && (len(data) == 1 || data[len(data)-2]&0x80 == 0) {
+		return 0, fmt.Errorf("%w: not minimally encoded", ErrInvalidNumber)
+	}
+
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << (8 * i)
+	}
+	if last&0x80 != 0 {
+		n &^= int64(0x80) << (8 * (len(data) - 1))
+		n = -n
+	}
+	return n, nil
+}
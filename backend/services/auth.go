@@ -4,13 +4,28 @@ import (
 	"backend/crypto"
 	"backend/models"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// RegisterUser creates a new user account with wallet
+// GetDefaultKeyAlgorithm returns the key algorithm for new wallets (KEY_ALGORITHM)
+func GetDefaultKeyAlgorithm() crypto.KeyAlgorithm {
+	if algorithm := os.Getenv("KEY_ALGORITHM"); algorithm != "" {
+		return crypto.KeyAlgorithm(algorithm)
+	}
+	return crypto.AlgorithmRSA
+}
+
+// RegisterUser creates a new user account with a wallet using the default key algorithm
 func RegisterUser(fullName, email, cnic, hashedPassword string) (*models.User, string, error) {
+	return RegisterUserWithKeyAlgorithm(fullName, email, cnic, hashedPassword, GetDefaultKeyAlgorithm())
+}
+
+// RegisterUserWithKeyAlgorithm creates a new user account with a wallet whose key uses
+// algorithm. It returns the user and the unencrypted PEM private key.
+func RegisterUserWithKeyAlgorithm(fullName, email, cnic, hashedPassword string, algorithm crypto.KeyAlgorithm) (*models.User, string, error) {
 	// Check if user already exists
 	existingUser, _ := GetUserByEmail(email)
 	if existingUser != nil {
@@ -18,23 +33,22 @@ func RegisterUser(fullName, email, cnic, hashedPassword string) (*models.User, s
 	}
 
 	// Generate keypair
-	privateKey, publicKey, err := crypto.GenerateKeyPair()
+	privateKeyStr, publicKeyStr, err := crypto.GenerateKeys(algorithm)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate keypair: %v", err)
 	}
 
-	// Convert keys to strings
-	privateKeyStr := crypto.PrivateKeyToString(privateKey)
-	publicKeyStr := crypto.PublicKeyToString(publicKey)
-
 	// Encrypt private key
 	encryptedPrivateKey, err := crypto.EncryptPrivateKey(privateKeyStr)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to encrypt private key: %v", err)
 	}
 
-	// Generate wallet ID from public key
-	walletID := crypto.GenerateWalletID(publicKey)
+	// Generate wallet ID from public key; it encodes the key algorithm
+	walletID, err := crypto.WalletIDFromPublicKey(publicKeyStr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to derive wallet ID: %v", err)
+	}
 
 	// Create user
 	user := &models.User{
@@ -66,7 +80,7 @@ func RegisterUser(fullName, email, cnic, hashedPassword string) (*models.User, s
 		WalletID:  walletID,
 		UserID:    user.ID,
 		PublicKey: publicKeyStr,
-		Balance:   1000, // Initial balance
+		Balance:   1000 * models.BC, // Initial balance
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		IsActive:  true,
@@ -78,7 +92,7 @@ func RegisterUser(fullName, email, cnic, hashedPassword string) (*models.User, s
 
 	// Create initial UTXO with 1000 BC for new users
 	initialTxHash := fmt.Sprintf("genesis-%s", user.ID)
-	_, err = CreateUTXO(walletID, 1000.0, initialTxHash, 0)
+	_, err = CreateUTXO(walletID, 1000*models.BC, initialTxHash, 0)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create initial UTXO: %v", err)
 	}
@@ -120,11 +134,12 @@ func UpdateUserProfile(userID, fullName, email string) error {
 
 // AddBeneficiary adds a beneficiary wallet ID to user's list
 func AddBeneficiary(userID, beneficiaryWalletID string) error {
-	// Validate beneficiary wallet exists
-	_, err := GetWalletByID(beneficiaryWalletID)
+	// Validate beneficiary wallet exists and store it under its canonical ID
+	wallet, err := GetWalletByID(beneficiaryWalletID)
 	if err != nil {
 		return fmt.Errorf("invalid beneficiary wallet ID")
 	}
+	beneficiaryWalletID = wallet.WalletID
 
 	user, err := GetUserByID(userID)
 	if err != nil {
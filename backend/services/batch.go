@@ -0,0 +1,282 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"fmt"
+	"time"
+)
+
+// A batch payment pays many receivers from one transaction: its inputs are selected and
+// signed once, and each receiver gets an output of its own carrying a note. Batches are
+// "batch" transactions whose receiver is BatchReceiverID; the receivers are the wallets
+// of the outputs other than the change back to the sender, and the amount is their sum.
+
+// BatchReceiverID is the receiver of batch transactions, which pay the wallets of their
+// outputs
+const BatchReceiverID = "BATCH"
+
+// MaxBatchPayments is the largest number of receivers one batch can pay
+const MaxBatchPayments = 250
+
+// BatchPayment is one receiver's line of a batch payment
+type BatchPayment struct {
+	WalletID string        `json:"walletId"`
+	Amount   models.Amount `json:"amount"`
+	Note     string        `json:"note,omitempty"`
+}
+
+// PaymentLine is one payment made by a transaction: a batch has a line per receiver,
+// any other transaction a single line to its receiver
+type PaymentLine struct {
+	TransactionHash  string        `json:"transactionHash"`
+	Type             string        `json:"type"`
+	SenderWalletID   string        `json:"senderWalletId"`
+	ReceiverWalletID string        `json:"receiverWalletId"`
+	Amount           models.Amount `json:"amount"`
+	Note             string        `json:"note,omitempty"`
+	Status           string        `json:"status"`
+	Timestamp        time.Time     `json:"timestamp"`
+}
+
+// BuildBatchTransaction selects inputs once and builds an unsigned transaction paying
+// every receiver its amount, with any change returned to the sender. It is signed and
+// submitted like BuildTransaction.
+func BuildBatchTransaction(senderWalletID string, payments []BatchPayment, fee models.Amount, note, senderPublicKey string) (*models.Transaction, error) {
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("a batch must pay at least one receiver")
+	}
+	if len(payments) > MaxBatchPayments {
+		return nil, fmt.Errorf("a batch can pay at most %d receivers", MaxBatchPayments)
+	}
+
+	if minFee := GetMinimumFee(); fee < minFee {
+		return nil, fmt.Errorf("minimum transaction fee is %s BC", minFee)
+	}
+
+	senderWallet, err := GetWalletByID(senderWalletID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender wallet ID: %v", err)
+	}
+	senderWalletID = senderWallet.WalletID
+
+	// Each receiver is paid under its stored wallet ID, whichever alias was given
+	outputs := make([]models.UTXOOutput, 0, len(payments)+1)
+	amount := models.Amount(0)
+	for idx, payment := range payments {
+		if payment.Amount < models.BC/100 {
+			return nil, fmt.Errorf("payment %d: minimum transaction amount is 0.01 BC", idx)
+		}
+		if payment.Amount > MaxTransferAmount {
+			return nil, fmt.Errorf("payment %d: maximum transaction amount is %s BC", idx, MaxTransferAmount)
+		}
+		if err := crypto.ValidateWalletIDFormat(payment.WalletID); err != nil {
+			return nil, fmt.Errorf("payment %d: invalid receiver wallet ID: %v", idx, err)
+		}
+		receiverWallet, err := GetWalletByID(payment.WalletID)
+		if err != nil {
+			return nil, fmt.Errorf("payment %d: invalid receiver wallet ID: %v", idx, err)
+		}
+		if receiverWallet.WalletID == senderWalletID {
+			return nil, fmt.Errorf("payment %d: cannot send money to yourself", idx)
+		}
+
+		outputs = append(outputs, models.UTXOOutput{
+			WalletID: receiverWallet.WalletID,
+			Amount:   payment.Amount,
+			Note:     payment.Note,
+		})
+		if amount, err = amount.Add(payment.Amount); err != nil {
+			return nil, fmt.Errorf("batch total: %v", err)
+		}
+	}
+	required, err := amount.Add(fee)
+	if err != nil {
+		return nil, fmt.Errorf("batch total: %v", err)
+	}
+
+	balance, err := CalculateBalance(senderWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if balance < required {
+		return nil, fmt.Errorf("insufficient balance: have %s, need %s", balance, required)
+	}
+
+	selectedUTXOs, total, err := SelectUTXOs(senderWalletID, required)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &models.Transaction{
+		Version:          currentTransactionVersion,
+		SenderWalletID:   senderWalletID,
+		ReceiverWalletID: BatchReceiverID,
+		Amount:           amount,
+		Fee:              fee,
+		Note:             note,
+		Timestamp:        storageTime(),
+		SenderPublicKey:  senderPublicKey,
+		OutputUTXOs:      outputs,
+		Type:             "batch",
+		Status:           "pending",
+	}
+
+	for _, utxo := range selectedUTXOs {
+		tx.InputUTXOs = append(tx.InputUTXOs, utxo.ID)
+	}
+
+	if change := total - amount - fee; change > 0 {
+		tx.OutputUTXOs = append(tx.OutputUTXOs, models.UTXOOutput{
+			WalletID: senderWalletID,
+			Amount:   change,
+		})
+	}
+
+	// Catch duplicate and self payments before the client signs
+	if err := validateBatchOutputs(*tx); err != nil {
+		return nil, err
+	}
+
+	tx.Hash = CalculateTransactionHash(*tx)
+	return tx, nil
+}
+
+// validateBatchOutputs checks that output notes appear only in batches, and that a batch
+// pays between one and MaxBatchPayments distinct receivers other than the sender at least
+// 0.01 BC each, returns at most one change output to the sender, and declares the sum of
+// the payments as its amount
+func validateBatchOutputs(tx models.Transaction) error {
+	if tx.Type != "batch" {
+		if tx.ReceiverWalletID == BatchReceiverID {
+			return fmt.Errorf("only batch transactions pay %s", BatchReceiverID)
+		}
+		for idx, output := range tx.OutputUTXOs {
+			if output.Note != "" {
+				return fmt.Errorf("output %d carries a note outside a batch", idx)
+			}
+		}
+		return nil
+	}
+
+	if tx.Version < TransactionVersionBatch {
+		return fmt.Errorf("only version %d transactions can be batches", TransactionVersionBatch)
+	}
+	if tx.ReceiverWalletID != BatchReceiverID {
+		return fmt.Errorf("batch receiver must be %s", BatchReceiverID)
+	}
+
+	paid := make(map[string]bool, len(tx.OutputUTXOs))
+	payments, changes := 0, 0
+	amount := models.Amount(0)
+	for idx, output := range tx.OutputUTXOs {
+		switch {
+		case output.WalletID == tx.SenderWalletID:
+			if output.Note != "" {
+				return fmt.Errorf("change output %d cannot carry a note", idx)
+			}
+			if changes++; changes > 1 {
+				return fmt.Errorf("a batch returns at most one change output")
+			}
+			continue
+		case output.WalletID == EscrowWalletID || output.WalletID == BatchReceiverID:
+			return fmt.Errorf("output %d cannot pay %s in a batch", idx, output.WalletID)
+		case paid[output.WalletID]:
+			return fmt.Errorf("output %d pays %s a second time; list each receiver once", idx, output.WalletID)
+		case output.Amount < models.BC/100:
+			return fmt.Errorf("output %d: minimum transaction amount is 0.01 BC", idx)
+		}
+		paid[output.WalletID] = true
+		payments++
+		var err error
+		if amount, err = amount.Add(output.Amount); err != nil {
+			return fmt.Errorf("batch payments: %v", err)
+		}
+	}
+
+	if payments == 0 {
+		return fmt.Errorf("a batch must pay at least one receiver")
+	}
+	if payments > MaxBatchPayments {
+		return fmt.Errorf("a batch can pay at most %d receivers", MaxBatchPayments)
+	}
+	if amount != tx.Amount {
+		return fmt.Errorf("batch amount %s does not match its payments %s", tx.Amount, amount)
+	}
+	return nil
+}
+
+// validateBatchReceivers checks that every receiver of a batch exists under the wallet ID
+// its output is locked to
+func validateBatchReceivers(tx models.Transaction) error {
+	for idx, output := range tx.OutputUTXOs {
+		if output.WalletID == tx.SenderWalletID {
+			continue
+		}
+		receiver, err := GetWalletByID(output.WalletID)
+		if err != nil {
+			return fmt.Errorf("invalid receiver wallet ID in output %d", idx)
+		}
+		if receiver.WalletID != output.WalletID {
+			return fmt.Errorf("output %d must pay wallet ID %s, not an alias", idx, receiver.WalletID)
+		}
+	}
+	return nil
+}
+
+// TransactionPaymentLines splits a transaction into the payments it makes. A version 5
+// transaction paying BatchReceiverID pays the wallets of its outputs, so it has a line
+// per output other than the change; any other transaction pays its receiver.
+func TransactionPaymentLines(tx models.Transaction) []PaymentLine {
+	line := PaymentLine{
+		TransactionHash:  tx.Hash,
+		Type:             tx.Type,
+		SenderWalletID:   tx.SenderWalletID,
+		ReceiverWalletID: tx.ReceiverWalletID,
+		Amount:           tx.Amount,
+		Note:             tx.Note,
+		Status:           tx.Status,
+		Timestamp:        tx.Timestamp,
+	}
+	if !isBatchPayment(tx) {
+		return []PaymentLine{line}
+	}
+
+	var lines []PaymentLine
+	for _, output := range tx.OutputUTXOs {
+		if output.WalletID == tx.SenderWalletID {
+			continue
+		}
+		line.ReceiverWalletID = output.WalletID
+		line.Amount = output.Amount
+		line.Note = output.Note
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// isBatchPayment reports whether a transaction's receivers are the wallets of its
+// outputs rather than its ReceiverWalletID
+func isBatchPayment(tx models.Transaction) bool {
+	return tx.Version >= TransactionVersionBatch && tx.ReceiverWalletID == BatchReceiverID
+}
+
+// GetWalletPaymentLines lists the payment lines of transactions in which any of the
+// wallets is the sender or a receiver. A batch sent by one of them is listed in full; of
+// a batch sent to them, only their own lines are.
+func GetWalletPaymentLines(transactions []models.Transaction, walletIDs []string) []PaymentLine {
+	owned := make(map[string]bool, len(walletIDs))
+	for _, walletID := range walletIDs {
+		owned[walletID] = true
+	}
+
+	lines := []PaymentLine{}
+	for _, tx := range transactions {
+		for _, line := range TransactionPaymentLines(tx) {
+			if owned[line.SenderWalletID] || owned[line.ReceiverWalletID] {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
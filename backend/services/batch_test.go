@@ -0,0 +1,70 @@
+package services
+
+import (
+	"backend/models"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestBuildBatchTransactionChecksLimits(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	carol := newTestWallet(t, 0)
+
+	tests := []struct {
+		name     string
+		payments []BatchPayment
+		fee      models.Amount
+		wantErr  string
+	}{
+		{
+			name:     "line below the minimum",
+			payments: []BatchPayment{{WalletID: bob.WalletID, Amount: models.BC / 1000}},
+			fee:      GetMinimumFee(),
+			wantErr:  "minimum transaction amount",
+		},
+		{
+			name:     "line over the maximum",
+			payments: []BatchPayment{{WalletID: bob.WalletID, Amount: MaxTransferAmount + 1}},
+			fee:      GetMinimumFee(),
+			wantErr:  "maximum transaction amount",
+		},
+		{
+			name: "overflowing fee",
+			payments: []BatchPayment{
+				{WalletID: bob.WalletID, Amount: MaxTransferAmount},
+				{WalletID: carol.WalletID, Amount: MaxTransferAmount},
+			},
+			fee:     models.Amount(math.MaxInt64 - MaxTransferAmount),
+			wantErr: "overflows",
+		},
+		{
+			name: "lines within the limits",
+			payments: []BatchPayment{
+				{WalletID: bob.WalletID, Amount: 10 * models.BC},
+				{WalletID: carol.WalletID, Amount: 20 * models.BC},
+			},
+			fee: GetMinimumFee(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := BuildBatchTransaction(alice.WalletID, tt.payments, tt.fee, "", alice.PublicKey)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("BuildBatchTransaction: %v", err)
+				}
+				if tx.Amount != 30*models.BC {
+					t.Errorf("amount = %s, want 30", tx.Amount)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
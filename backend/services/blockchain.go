@@ -1,14 +1,11 @@
 package services
 
 import (
-	"backend/crypto"
 	"backend/models"
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -17,17 +14,13 @@ var (
 	blockchain          []models.Block
 	pendingTransactions []models.Transaction
 	blockchainMutex     sync.RWMutex
+	miningMutex         sync.Mutex
 	difficulty          int
 )
 
 // InitBlockchain initializes the blockchain with genesis block
 func InitBlockchain() {
-	diffStr := os.Getenv("MINING_DIFFICULTY")
-	if diffStr == "" {
-		difficulty = 4
-	} else {
-		difficulty, _ = strconv.Atoi(diffStr)
-	}
+	initDifficultyConfig()
 
 	// Check if blockchain exists in database
 	blocks, err := GetAllBlocks()
@@ -46,6 +39,14 @@ func InitBlockchain() {
 		blockchain = blocks
 		log.Printf("Loaded %d blocks from database", len(blocks))
 	}
+
+	// Restore the in-memory pool so pending transactions are still mined after a restart
+	if pending, err := GetPendingTransactions(); err == nil {
+		pendingTransactions = pending
+	}
+
+	// Verify the loaded chain end to end
+	logValidationReport(ValidateChainDeep())
 }
 
 // createGenesisBlock creates the first block in the blockchain
@@ -63,11 +64,12 @@ func createGenesisBlock() models.Block {
 
 	block := models.Block{
 		Index:        0,
-		Timestamp:    time.Now(),
+		Timestamp:    storageTime(),
 		Transactions: []models.Transaction{genesisTransaction},
 		PreviousHash: "0",
 		Nonce:        0,
 		Difficulty:   difficulty,
+		Version:      currentBlockVersion,
 	}
 
 	block.MerkleRoot = calculateMerkleRoot(block.Transactions)
@@ -89,170 +91,154 @@ func GetLatestBlock() models.Block {
 
 // AddPendingTransaction adds a transaction to the pending pool
 func AddPendingTransaction(tx models.Transaction) error {
-	blockchainMutex.Lock()
-	defer blockchainMutex.Unlock()
-
-	pendingTransactions = append(pendingTransactions, tx)
-
 	// Save to database
 	if err := SavePendingTransaction(tx); err != nil {
 		return err
 	}
 
-	log.Printf("Transaction %s added to pending pool", tx.Hash)
+	queuePendingTransaction(tx)
 	return nil
 }
 
-// MineBlock mines a new block with pending transactions
-func MineBlock(minerWalletID string) (models.Block, error) {
+// queuePendingTransaction adds an already persisted transaction to the in-memory pool
+func queuePendingTransaction(tx models.Transaction) {
 	blockchainMutex.Lock()
 	defer blockchainMutex.Unlock()
 
+	pendingTransactions = append(pendingTransactions, tx)
+
+	log.Printf("Transaction %s added to pending pool", tx.Hash)
+}
+
+// MineBlock mines a new block with pending transactions
+func MineBlock(minerWalletID string) (models.Block, error) {
+	ctx, cancel := miningContext()
+	defer cancel()
+	return MineBlockWithContext(ctx, minerWalletID)
+}
+
+// MineBlockWithContext mines a new block with pending transactions, giving up when ctx
+// is cancelled. The chain lock is only held while snapshotting the pool and appending
+// the finished block, so readers are not blocked during proof-of-work.
+func MineBlockWithContext(ctx context.Context, minerWalletID string) (models.Block, error) {
+	// Only one block is mined at a time
+	miningMutex.Lock()
+	defer miningMutex.Unlock()
+
+	// Escrow transactions that missed their deadline while pending cannot be mined
+	timestamp := storageTime()
+	if err := dropExpiredEscrows(nextBlockHeight(), timestamp); err != nil {
+		return models.Block{}, err
+	}
+
+	blockchainMutex.RLock()
 	if len(pendingTransactions) == 0 {
+		blockchainMutex.RUnlock()
 		return models.Block{}, fmt.Errorf("no pending transactions to mine")
 	}
 
 	latestBlock := blockchain[len(blockchain)-1]
+	blockDifficulty := nextDifficulty(blockchain)
+	pool := append([]models.Transaction(nil), pendingTransactions...)
+	blockchainMutex.RUnlock()
+
+	// Highest fee rate first, up to the block size limit
+	transactions := selectBlockTransactions(pool, getMaxBlockSize())
+	if len(transactions) == 0 {
+		return models.Block{}, fmt.Errorf("no pending transactions fit in a block")
+	}
 
 	newBlock := models.Block{
 		Index:        latestBlock.Index + 1,
-		Timestamp:    time.Now(),
-		Transactions: pendingTransactions,
+		Timestamp:    timestamp,
 		PreviousHash: latestBlock.Hash,
-		Difficulty:   difficulty,
+		Difficulty:   blockDifficulty,
+		Version:      currentBlockVersion,
 		MinedBy:      minerWalletID,
 	}
 
+	// The coinbase comes first and pays the miner the block subsidy plus collected fees
+	subsidy := GetBlockSubsidy(newBlock.Index)
+	if fees := totalFees(transactions); subsidy+fees > 0 {
+		coinbase := newCoinbaseTransaction(newBlock.Index, minerWalletID, subsidy, fees, newBlock.Timestamp)
+		newBlock.Transactions = append(newBlock.Transactions, coinbase)
+	}
+	newBlock.Transactions = append(newBlock.Transactions, transactions...)
+
 	newBlock.MerkleRoot = calculateMerkleRoot(newBlock.Transactions)
 
 	// Proof of Work
 	log.Printf("Mining block %d with %d transactions...", newBlock.Index, len(newBlock.Transactions))
-	newBlock = proofOfWork(newBlock)
+	newBlock, err := proofOfWork(ctx, newBlock)
+	if err != nil {
+		return models.Block{}, fmt.Errorf("mining block %d aborted: %v", newBlock.Index, err)
+	}
+
+	// Persist the block, its rewards and confirmations before the chain or pool change,
+	// so a failed save leaves both as they were
+	blockchainMutex.RLock()
+	tipChanged := blockchain[len(blockchain)-1].Hash != latestBlock.Hash
+	blockchainMutex.RUnlock()
+	if tipChanged {
+		return models.Block{}, fmt.Errorf("chain tip changed while mining block %d", newBlock.Index)
+	}
+	if err := CommitBlock(newBlock, blockRewardUTXOs(newBlock)); err != nil {
+		return models.Block{}, fmt.Errorf("saving block %d: %w", newBlock.Index, err)
+	}
 
 	// Add block to chain
+	blockchainMutex.Lock()
 	blockchain = append(blockchain, newBlock)
 
-	// Clear pending transactions
-	pendingTransactions = []models.Transaction{}
-
-	// Update transaction statuses
-	for _, tx := range newBlock.Transactions {
-		tx.Status = "confirmed"
-		tx.BlockHash = newBlock.Hash
-		if err := UpdateTransaction(tx); err != nil {
-			log.Printf("Error updating transaction: %v", err)
-		}
+	// Remove mined transactions from the pool, keeping the rest and any that arrived while mining
+	mined := make(map[string]bool, len(transactions))
+	for _, tx := range transactions {
+		mined[tx.Hash] = true
 	}
-
-	// Save block to database
-	if err := SaveBlock(newBlock); err != nil {
-		return models.Block{}, err
+	remaining := make([]models.Transaction, 0, len(pendingTransactions))
+	for _, tx := range pendingTransactions {
+		if !mined[tx.Hash] {
+			remaining = append(remaining, tx)
+		}
 	}
+	pendingTransactions = remaining
+	blockchainMutex.Unlock()
 
-	// Clear pending transactions from database
-	if err := ClearPendingTransactions(); err != nil {
-		log.Printf("Error clearing pending transactions: %v", err)
-	}
+	// The reward is stored; refresh the miner's cached balance
+	refreshMinerBalance(newBlock)
 
 	log.Printf("Block %d mined successfully with hash: %s", newBlock.Index, newBlock.Hash)
 
 	// Log mining event
 	LogSystemEvent("mining", fmt.Sprintf("Block %d mined by %s", newBlock.Index, minerWalletID), minerWalletID, "")
 
-	return newBlock, nil
-}
-
-// proofOfWork performs the mining process
-func proofOfWork(block models.Block) models.Block {
-	target := strings.Repeat("0", difficulty)
-
-	for {
-		block.Hash = calculateBlockHash(block)
-
-		if strings.HasPrefix(block.Hash, target) {
-			break
-		}
+	// Wake clients waiting for confirmations
+	notifyBlockMined()
 
-		block.Nonce++
-	}
-
-	return block
+	return newBlock, nil
 }
 
-// calculateBlockHash calculates the hash of a block
+// calculateBlockHash calculates the hash of a block. Current blocks hash only their
+// serialized header; transactions are committed through MerkleRoot.
 func calculateBlockHash(block models.Block) string {
-	transactionsJSON, _ := json.Marshal(block.Transactions)
-
-	data := fmt.Sprintf("%d%s%s%s%d%s",
-		block.Index,
-		block.Timestamp.String(),
-		string(transactionsJSON),
-		block.PreviousHash,
-		block.Nonce,
-		block.MerkleRoot,
-	)
-
-	return crypto.HashSHA256(data)
-}
-
-// calculateMerkleRoot calculates the merkle root of transactions
-func calculateMerkleRoot(transactions []models.Transaction) string {
-	if len(transactions) == 0 {
-		return ""
-	}
-
-	var hashes []string
-	for _, tx := range transactions {
-		hashes = append(hashes, tx.Hash)
-	}
-
-	for len(hashes) > 1 {
-		if len(hashes)%2 != 0 {
-			hashes = append(hashes, hashes[len(hashes)-1])
-		}
-
-		var newHashes []string
-		for i := 0; i < len(hashes); i += 2 {
-			combined := hashes[i] + hashes[i+1]
-			newHashes = append(newHashes, crypto.HashSHA256(combined))
-		}
-		hashes = newHashes
+	if block.Version < BlockVersionHeader {
+		return legacyBlockHash(block)
 	}
-
-	return hashes[0]
+	return HashBlockHeader(HeaderOf(block))
 }
 
-// ValidateChain validates the entire blockchain
+// ValidateChain validates block links, hashes, difficulty and proof-of-work.
+// See ValidateChainDeep for transaction-level checks.
 func ValidateChain() bool {
 	blockchainMutex.RLock()
 	defer blockchainMutex.RUnlock()
 
-	for i := 1; i < len(blockchain); i++ {
-		currentBlock := blockchain[i]
-		previousBlock := blockchain[i-1]
-
-		// Check if previous hash matches
-		if currentBlock.PreviousHash != previousBlock.Hash {
-			log.Printf("Invalid previous hash at block %d", i)
-			return false
-		}
-
-		// Recalculate hash
-		calculatedHash := calculateBlockHash(currentBlock)
-		if currentBlock.Hash != calculatedHash {
-			log.Printf("Invalid hash at block %d", i)
-			return false
-		}
-
-		// Check PoW
-		target := strings.Repeat("0", currentBlock.Difficulty)
-		if !strings.HasPrefix(currentBlock.Hash, target) {
-			log.Printf("Invalid PoW at block %d", i)
-			return false
-		}
+	violations := blockViolations(blockchain)
+	for _, v := range violations {
+		log.Printf("Invalid %s at block %d: %s", v.Type, v.BlockIndex, v.Message)
 	}
 
-	return true
+	return len(violations) == 0
 }
 
 // GetBlockchain returns the entire blockchain
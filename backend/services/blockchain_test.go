@@ -0,0 +1,62 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"testing"
+)
+
+// failingCommitBlockStore is a memory store that cannot save blocks
+type failingCommitBlockStore struct {
+	*MemoryStore
+}
+
+func (s failingCommitBlockStore) CommitBlock(block models.Block, rewards []models.UTXO) error {
+	return errors.New("commit failed")
+}
+
+func TestMineBlockLeavesChainWhenCommitFails(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	miner := newTestWallet(t, 0)
+
+	tx := alice.transfer(t, bob, 10*models.BC)
+	if err := ProcessTransaction(tx); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	height := GetLatestBlock().Index
+
+	SetStore(failingCommitBlockStore{memory})
+	if _, err := MineBlock(miner.WalletID); err == nil {
+		t.Fatal("MineBlock succeeded although the block was not saved")
+	}
+	SetStore(memory)
+
+	if got := GetLatestBlock().Index; got != height {
+		t.Errorf("chain height = %d after failed save, want %d", got, height)
+	}
+	if pool := GetPendingTransactionsFromMemory(); len(pool) != 1 || pool[0].Hash != tx.Hash {
+		t.Errorf("pending pool = %v, want only %s", pool, tx.Hash)
+	}
+	if stored, _ := memory.GetTransaction(tx.Hash); stored.Status != "pending" || stored.BlockHash != "" {
+		t.Errorf("stored transaction is %q in block %q, want pending", stored.Status, stored.BlockHash)
+	}
+	if got := mustBalance(t, miner.WalletID); got != 0 {
+		t.Errorf("miner balance = %s after failed save, want 0", got)
+	}
+
+	block := mustMine(t, miner.WalletID)
+	if stored, _ := memory.GetTransaction(tx.Hash); stored.Status != "confirmed" || stored.BlockHash != block.Hash {
+		t.Errorf("stored transaction is %q in block %q, want confirmed in %s", stored.Status, stored.BlockHash, block.Hash)
+	}
+	if pending, _ := memory.GetPendingTransactions(); len(pending) != 0 {
+		t.Errorf("stored pending pool = %v, want empty", pending)
+	}
+	if _, err := memory.GetBlockByHash(block.Hash); err != nil {
+		t.Errorf("mined block not stored: %v", err)
+	}
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Errorf("chain invalid after retry: %v", violationTypes(report))
+	}
+}
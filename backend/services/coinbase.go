@@ -0,0 +1,179 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"fmt"
+	"log"
+	"time"
+)
+
+// CoinbaseSenderID is the sender of mining reward transactions, which have no inputs
+const CoinbaseSenderID = "COINBASE"
+
+// GetBlockSubsidy returns the newly created coins a block at height may pay its miner.
+// The subsidy starts at MINING_REWARD and halves every HALVING_INTERVAL blocks,
+// rounding down to whole base units.
+func GetBlockSubsidy(height int64) models.Amount {
+	subsidy := models.AmountFromFloat(getEnvFloat("MINING_REWARD", 50))
+	interval := int64(getEnvInt("HALVING_INTERVAL", 1000))
+	if interval <= 0 || height < 0 {
+		return subsidy
+	}
+
+	halvings := height / interval
+	if halvings >= 63 {
+		return 0
+	}
+	return subsidy >> uint(halvings)
+}
+
+// getCoinbaseMaturity returns how many blocks must be mined on top of a coinbase
+// before its output can be spent (COINBASE_MATURITY)
+func getCoinbaseMaturity() int64 {
+	return int64(getEnvInt("COINBASE_MATURITY", 10))
+}
+
+// isRewardTransaction reports whether a transaction is a block's coinbase
+func isRewardTransaction(tx models.Transaction) bool {
+	return tx.Type == "mining_reward"
+}
+
+// isMatureUTXO reports whether a UTXO may be spent in a block at height. Only coinbase
+// outputs have to wait.
+func isMatureUTXO(utxo models.UTXO, height int64) bool {
+	return utxo.CoinbaseHeight == 0 || height-utxo.CoinbaseHeight >= getCoinbaseMaturity()
+}
+
+// nextBlockHeight returns the height of the block currently being assembled
+func nextBlockHeight() int64 {
+	return GetLatestBlock().Index + 1
+}
+
+// newCoinbaseTransaction builds the transaction paying a block's miner the subsidy plus
+// the fees it collected. Like any canonical transaction it is hashed from its
+// serialization; the note names the block index, so each block's coinbase is unique.
+func newCoinbaseTransaction(index int64, minerWalletID string, subsidy, fees models.Amount, timestamp time.Time) models.Transaction {
+	amount := subsidy + fees
+	tx := models.Transaction{
+		SenderWalletID:   CoinbaseSenderID,
+		ReceiverWalletID: minerWalletID,
+		Amount:           amount,
+		Note:             fmt.Sprintf("Block %d reward: %s subsidy + %s fees", index, subsidy, fees),
+		Timestamp:        timestamp,
+		InputUTXOs:       []string{},
+		OutputUTXOs: []models.UTXOOutput{{
+			WalletID: minerWalletID,
+			Amount:   amount,
+		}},
+		Type:    "mining_reward",
+		Status:  "pending",
+		Version: currentTransactionVersion,
+	}
+	tx.Hash = CalculateTransactionHash(tx)
+	return tx
+}
+
+// legacyCoinbaseHash is the hash given to coinbases before BlockVersionCanonicalCoinbase,
+// derived only from the block position and miner
+func legacyCoinbaseHash(index int64, previousHash, minerWalletID string) string {
+	return crypto.HashSHA256(fmt.Sprintf("reward:%d:%s:%s", index, previousHash, minerWalletID))
+}
+
+// coinbaseOutputUTXOs builds the UTXO records for a coinbase mined at height
+func coinbaseOutputUTXOs(tx models.Transaction, height int64) []models.UTXO {
+	outputs := NewOutputUTXOs(tx)
+	for i := range outputs {
+		outputs[i].CoinbaseHeight = height
+	}
+	return outputs
+}
+
+// blockRewardUTXOs builds the UTXOs paying a mined block's reward to its miner
+func blockRewardUTXOs(block models.Block) []models.UTXO {
+	var rewards []models.UTXO
+	for _, tx := range block.Transactions {
+		if isRewardTransaction(tx) {
+			rewards = append(rewards, coinbaseOutputUTXOs(tx, block.Index)...)
+		}
+	}
+	return rewards
+}
+
+// refreshMinerBalance updates the cached balance of a block's miner once its reward is
+// stored. The miner may be a system wallet with no stored record.
+func refreshMinerBalance(block models.Block) {
+	if _, err := GetWalletByID(block.MinedBy); err != nil {
+		return
+	}
+	if err := RecalculateWalletBalance(block.MinedBy); err != nil {
+		log.Printf("Warning: failed to update miner wallet balance: %v", err)
+	}
+}
+
+// coinbaseViolations checks that a block pays its miner exactly the subsidy for its
+// height plus the fees it collected, in at most one coinbase whose outputs all go to the
+// block's MinedBy wallet. Blocks before BlockVersionCoinbase carried no subsidy, only
+// fees. From BlockVersionCanonicalCoinbase the coinbase must be a canonical transaction,
+// whose hash the replay recomputes; earlier coinbases must carry the legacy hash of their
+// block position.
+func coinbaseViolations(block models.Block) []ChainViolation {
+	var violations []ChainViolation
+	report := func(txHash, message string) {
+		violations = append(violations, ChainViolation{
+			Type:            ViolationReward,
+			BlockIndex:      block.Index,
+			TransactionHash: txHash,
+			Message:         message,
+		})
+	}
+
+	fees := models.Amount(0)
+	paid := models.Amount(0)
+	coinbases := 0
+	for i, tx := range block.Transactions {
+		if !isRewardTransaction(tx) {
+			fees += tx.Fee
+			continue
+		}
+
+		coinbases++
+		if block.Version >= BlockVersionCoinbase && i != 0 {
+			report(tx.Hash, "coinbase is not the first transaction")
+		}
+		if len(tx.InputUTXOs) > 0 {
+			report(tx.Hash, "coinbase spends inputs")
+		}
+		if block.Version >= BlockVersionCanonicalCoinbase {
+			if tx.Version < TransactionVersionCanonical {
+				report(tx.Hash, fmt.Sprintf("coinbase has legacy transaction version %d", tx.Version))
+			}
+		} else if block.Version >= BlockVersionCoinbase && tx.Hash != legacyCoinbaseHash(block.Index, block.PreviousHash, tx.ReceiverWalletID) {
+			report(tx.Hash, "coinbase hash does not match its block position")
+		}
+		if block.Version >= BlockVersionCoinbase && tx.ReceiverWalletID != block.MinedBy {
+			report(tx.Hash, fmt.Sprintf("coinbase receiver %s is not the miner %s", tx.ReceiverWalletID, block.MinedBy))
+		}
+		for _, output := range tx.OutputUTXOs {
+			if block.Version >= BlockVersionCoinbase && output.WalletID != block.MinedBy {
+				report(tx.Hash, fmt.Sprintf("coinbase pays %s to %s, not the miner %s", output.Amount, output.WalletID, block.MinedBy))
+			}
+			paid += output.Amount
+		}
+	}
+
+	if coinbases > 1 {
+		report("", fmt.Sprintf("block has %d coinbase transactions", coinbases))
+	}
+
+	subsidy := models.Amount(0)
+	if block.Version >= BlockVersionCoinbase {
+		subsidy = GetBlockSubsidy(block.Index)
+	}
+
+	if expected := subsidy + fees; paid != expected {
+		report("", fmt.Sprintf("miner paid %s, expected subsidy %s + fees %s", paid, subsidy, fees))
+	}
+
+	return violations
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestMinedCoinbaseIsCanonical(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	miner := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	block := mustMine(t, miner.WalletID)
+
+	coinbase := block.Transactions[0]
+	if !isRewardTransaction(coinbase) {
+		t.Fatalf("first transaction is %q, want the coinbase", coinbase.Type)
+	}
+	if coinbase.Hash != CalculateTransactionHash(coinbase) {
+		t.Errorf("coinbase hash %s is not its canonical hash", coinbase.Hash)
+	}
+	if block.MinedBy != miner.WalletID || coinbase.OutputUTXOs[0].WalletID != miner.WalletID {
+		t.Errorf("block mined by %s pays %s, want %s", block.MinedBy, coinbase.OutputUTXOs[0].WalletID, miner.WalletID)
+	}
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Fatalf("chain invalid: %v", report.Violations)
+	}
+}
+
+func TestValidateChainDeepReportsRedirectedCoinbase(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	miner := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	block := mustMine(t, miner.WalletID)
+
+	// Pay the reward to someone else without changing the coinbase hash
+	blockchainMutex.Lock()
+	coinbase := &blockchain[block.Index].Transactions[0]
+	coinbase.OutputUTXOs = []models.UTXOOutput{{WalletID: bob.WalletID, Amount: coinbase.Amount}}
+	blockchainMutex.Unlock()
+
+	report := ValidateChainDeep()
+	if report.Valid || !hasViolation(report, ViolationTransactionHash) || !hasViolation(report, ViolationReward) {
+		t.Fatalf("redirected coinbase not reported: %v", violationTypes(report))
+	}
+}
+
+func TestCoinbaseViolations(t *testing.T) {
+	useTestStore(t)
+
+	const miner, other = "miner-wallet", "other-wallet"
+	subsidy := GetBlockSubsidy(5)
+	timestamp := time.Now().UTC()
+
+	blockWith := func(version int, coinbase models.Transaction) models.Block {
+		return models.Block{
+			Index:        5,
+			PreviousHash: "previous",
+			Version:      version,
+			MinedBy:      miner,
+			Transactions: []models.Transaction{coinbase},
+		}
+	}
+	legacyCoinbase := func(receiver string) models.Transaction {
+		tx := newCoinbaseTransaction(5, receiver, subsidy, 0, timestamp)
+		tx.Version = TransactionVersionLegacy
+		tx.Hash = legacyCoinbaseHash(5, "previous", receiver)
+		return tx
+	}
+	redirected := newCoinbaseTransaction(5, miner, subsidy, 0, timestamp)
+	redirected.OutputUTXOs = []models.UTXOOutput{{WalletID: other, Amount: subsidy}}
+	redirected.Hash = CalculateTransactionHash(redirected)
+	wrongLegacyHash := legacyCoinbase(miner)
+	wrongLegacyHash.Hash = legacyCoinbaseHash(6, "previous", miner)
+
+	tests := []struct {
+		name  string
+		block models.Block
+		valid bool
+	}{
+		{"canonical coinbase", blockWith(BlockVersionCanonicalCoinbase, newCoinbaseTransaction(5, miner, subsidy, 0, timestamp)), true},
+		{"coinbase to another receiver", blockWith(BlockVersionCanonicalCoinbase, newCoinbaseTransaction(5, other, subsidy, 0, timestamp)), false},
+		{"output to another wallet", blockWith(BlockVersionCanonicalCoinbase, redirected), false},
+		{"legacy coinbase in a canonical block", blockWith(BlockVersionCanonicalCoinbase, legacyCoinbase(miner)), false},
+		{"overpaid coinbase", blockWith(BlockVersionCanonicalCoinbase, newCoinbaseTransaction(5, miner, subsidy+1, 0, timestamp)), false},
+		{"legacy coinbase block", blockWith(BlockVersionCoinbase, legacyCoinbase(miner)), true},
+		{"legacy coinbase with another block's hash", blockWith(BlockVersionCoinbase, wrongLegacyHash), false},
+		{"legacy coinbase to another receiver", blockWith(BlockVersionCoinbase, legacyCoinbase(other)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := coinbaseViolations(tt.block)
+			if valid := len(violations) == 0; valid != tt.valid {
+				t.Errorf("valid = %v, want %v: %v", valid, tt.valid, violations)
+			}
+		})
+	}
+}
@@ -23,12 +23,22 @@ const (
 	ZakatDeductionsCollection     = "zakatDeductions"
 	SystemLogsCollection          = "systemLogs"
 	TransactionLogsCollection     = "transactionLogs"
+	MultisigProposalsCollection   = "multisigProposals"
+	ScheduledTransfersCollection  = "scheduledTransfers"
 )
 
+// MongoStore is the Store implementation backed by MongoDB
+type MongoStore struct{}
+
+// NewMongoStore creates a MongoDB-backed store. config.InitMongoDB must be called first.
+func NewMongoStore() *MongoStore {
+	return &MongoStore{}
+}
+
 // User operations
 
 // SaveUser saves a user to MongoDB
-func SaveUser(user *models.User) error {
+func (m *MongoStore) SaveUser(user *models.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -50,7 +60,7 @@ func SaveUser(user *models.User) error {
 }
 
 // GetUserByID retrieves a user by ID
-func GetUserByID(userID string) (*models.User, error) {
+func (m *MongoStore) GetUserByID(userID string) (*models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -66,7 +76,7 @@ func GetUserByID(userID string) (*models.User, error) {
 }
 
 // GetUserByEmail retrieves a user by email
-func GetUserByEmail(email string) (*models.User, error) {
+func (m *MongoStore) GetUserByEmail(email string) (*models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -84,14 +94,8 @@ func GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-// UpdateUser updates a user
-func UpdateUser(user *models.User) error {
-	user.UpdatedAt = time.Now()
-	return SaveUser(user)
-}
-
 // GetAllUsers retrieves all users
-func GetAllUsers() ([]models.User, error) {
+func (m *MongoStore) GetAllUsers() ([]models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -111,10 +115,32 @@ func GetAllUsers() ([]models.User, error) {
 	return users, nil
 }
 
+// GetUsersAfter retrieves up to limit users with IDs after afterID, in ID order
+func (m *MongoStore) GetUsersAfter(afterID string, limit int) ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(UsersCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$gt": afterID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 // Wallet operations
 
 // SaveWallet saves a wallet to MongoDB
-func SaveWallet(wallet *models.Wallet) error {
+func (m *MongoStore) SaveWallet(wallet *models.Wallet) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -136,7 +162,7 @@ func SaveWallet(wallet *models.Wallet) error {
 }
 
 // GetWalletByID retrieves a wallet by ID
-func GetWalletByID(walletID string) (*models.Wallet, error) {
+func (m *MongoStore) GetWalletByID(walletID string) (*models.Wallet, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -151,16 +177,75 @@ func GetWalletByID(walletID string) (*models.Wallet, error) {
 	return &wallet, nil
 }
 
-// UpdateWallet updates a wallet
-func UpdateWallet(wallet *models.Wallet) error {
-	wallet.UpdatedAt = time.Now()
-	return SaveWallet(wallet)
+// GetAllWallets retrieves all wallets
+func (m *MongoStore) GetAllWallets() ([]models.Wallet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(WalletsCollection)
+
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var wallets []models.Wallet
+	if err = cursor.All(ctx, &wallets); err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+// GetUserWallets retrieves a user's wallets from MongoDB ordered by creation time
+func (m *MongoStore) GetUserWallets(userID string) ([]models.Wallet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(WalletsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var wallets []models.Wallet
+	if err = cursor.All(ctx, &wallets); err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+// GetMultisigWallets retrieves the multisig wallets a public key co-signs from MongoDB
+func (m *MongoStore) GetMultisigWallets(publicKey string) ([]models.Wallet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(WalletsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"multisig.publicKeys": publicKey}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var wallets []models.Wallet
+	if err = cursor.All(ctx, &wallets); err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
 }
 
 // UTXO operations
 
 // SaveUTXO saves a UTXO to MongoDB
-func SaveUTXO(utxo *models.UTXO) error {
+func (m *MongoStore) SaveUTXO(utxo *models.UTXO) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -175,7 +260,7 @@ func SaveUTXO(utxo *models.UTXO) error {
 }
 
 // GetUTXOByID retrieves a UTXO by ID
-func GetUTXOByID(utxoID string) (*models.UTXO, error) {
+func (m *MongoStore) GetUTXOByID(utxoID string) (*models.UTXO, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -191,7 +276,7 @@ func GetUTXOByID(utxoID string) (*models.UTXO, error) {
 }
 
 // GetUnspentUTXOs retrieves all unspent UTXOs for a wallet
-func GetUnspentUTXOs(walletID string) ([]models.UTXO, error) {
+func (m *MongoStore) GetUnspentUTXOs(walletID string) ([]models.UTXO, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -217,7 +302,7 @@ func GetUnspentUTXOs(walletID string) ([]models.UTXO, error) {
 }
 
 // GetAllUTXOs retrieves all UTXOs
-func GetAllUTXOs() ([]models.UTXO, error) {
+func (m *MongoStore) GetAllUTXOs() ([]models.UTXO, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -237,15 +322,44 @@ func GetAllUTXOs() ([]models.UTXO, error) {
 	return utxos, nil
 }
 
-// UpdateUTXO updates a UTXO
-func UpdateUTXO(utxo *models.UTXO) error {
-	return SaveUTXO(utxo)
+// ReplaceUTXOs deletes every UTXO and inserts utxos in one MongoDB transaction, so a
+// failure or crash part way through leaves the previous set in place
+func (m *MongoStore) ReplaceUTXOs(utxos []models.UTXO) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	session, err := config.MongoClient.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	collection := config.GetCollection(UTXOsCollection)
+
+	documents := make([]interface{}, len(utxos))
+	for i := range utxos {
+		documents[i] = utxos[i]
+	}
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := collection.DeleteMany(sc, bson.M{}); err != nil {
+			return nil, err
+		}
+		if len(documents) > 0 {
+			if _, err := collection.InsertMany(sc, documents); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+
+	return err
 }
 
 // Transaction operations
 
 // SaveTransaction saves a transaction to MongoDB
-func SaveTransaction(tx *models.Transaction) error {
+func (m *MongoStore) SaveTransaction(tx *models.Transaction) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -266,8 +380,8 @@ func SaveTransaction(tx *models.Transaction) error {
 	return err
 }
 
-// GetTransactionFromDB retrieves a transaction by hash
-func GetTransactionFromDB(hash string) (*models.Transaction, error) {
+// GetTransaction retrieves a transaction by hash
+func (m *MongoStore) GetTransaction(hash string) (*models.Transaction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -282,13 +396,8 @@ func GetTransactionFromDB(hash string) (*models.Transaction, error) {
 	return &tx, nil
 }
 
-// UpdateTransaction updates a transaction
-func UpdateTransaction(tx models.Transaction) error {
-	return SaveTransaction(&tx)
-}
-
 // GetWalletTransactions retrieves all transactions for a wallet
-func GetWalletTransactions(walletID string) ([]models.Transaction, error) {
+func (m *MongoStore) GetWalletTransactions(walletID string) ([]models.Transaction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -298,6 +407,7 @@ func GetWalletTransactions(walletID string) ([]models.Transaction, error) {
 		"$or": []bson.M{
 			{"senderWalletId": walletID},
 			{"receiverWalletId": walletID},
+			{"outputUtxos.walletId": walletID}, // Receivers of batch payments
 		},
 	}
 
@@ -319,7 +429,7 @@ func GetWalletTransactions(walletID string) ([]models.Transaction, error) {
 // Pending Transaction operations
 
 // SavePendingTransaction saves a pending transaction
-func SavePendingTransaction(tx models.Transaction) error {
+func (m *MongoStore) SavePendingTransaction(tx models.Transaction) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -339,7 +449,7 @@ func SavePendingTransaction(tx models.Transaction) error {
 }
 
 // GetPendingTransactions retrieves all pending transactions
-func GetPendingTransactions() ([]models.Transaction, error) {
+func (m *MongoStore) GetPendingTransactions() ([]models.Transaction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -365,7 +475,7 @@ func GetPendingTransactions() ([]models.Transaction, error) {
 }
 
 // ClearPendingTransactions removes all pending transactions
-func ClearPendingTransactions() error {
+func (m *MongoStore) ClearPendingTransactions() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -376,7 +486,7 @@ func ClearPendingTransactions() error {
 }
 
 // RemovePendingTransaction removes a specific pending transaction
-func RemovePendingTransaction(txHash string) error {
+func (m *MongoStore) RemovePendingTransaction(txHash string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -386,10 +496,71 @@ func RemovePendingTransaction(txHash string) error {
 	return err
 }
 
+// CommitTransaction spends inputs, creates outputs, saves the transaction and queues it
+// inside a single multi-document session transaction. Inputs are only spent if they
+// are still unspent, so concurrent attempts to spend the same UTXO fail here.
+func (m *MongoStore) CommitTransaction(tx models.Transaction, outputs []models.UTXO) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	session, err := config.MongoClient.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	utxos := config.GetCollection(UTXOsCollection)
+	transactions := config.GetCollection(TransactionsCollection)
+	pending := config.GetCollection(PendingTransactionsCollection)
+	upsert := options.Update().SetUpsert(true)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		spentAt := time.Now()
+		for _, utxoID := range tx.InputUTXOs {
+			result, err := utxos.UpdateOne(sc,
+				bson.M{"_id": utxoID, "spent": false},
+				bson.M{"$set": bson.M{
+					"spent":         true,
+					"spentInTxHash": tx.Hash,
+					"spentAt":       spentAt,
+				}},
+			)
+			if err != nil {
+				return nil, err
+			}
+			if result.MatchedCount == 0 {
+				return nil, fmt.Errorf("%w: %s", ErrUTXOAlreadySpent, utxoID)
+			}
+		}
+
+		for i := range outputs {
+			if _, err := utxos.UpdateOne(sc, bson.M{"_id": outputs[i].ID}, bson.M{"$set": outputs[i]}, upsert); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := transactions.UpdateOne(sc, bson.M{"hash": tx.Hash}, bson.M{"$set": tx}, upsert); err != nil {
+			return nil, err
+		}
+
+		pendingTx := models.PendingTransaction{
+			Transaction: tx,
+			ReceivedAt:  time.Now(),
+		}
+		if _, err := pending.UpdateOne(sc, bson.M{"transaction.hash": tx.Hash}, bson.M{"$set": pendingTx}, upsert); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	})
+
+	return err
+}
+
 // Block operations
 
 // SaveBlock saves a block to MongoDB
-func SaveBlock(block models.Block) error {
+func (m *MongoStore) SaveBlock(block models.Block) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -410,8 +581,62 @@ func SaveBlock(block models.Block) error {
 	return err
 }
 
+// CommitBlock saves a mined block, creates its reward UTXOs, confirms its transactions
+// and removes them from the pending pool inside a single multi-document session
+// transaction, so a failure leaves no trace of the block
+func (m *MongoStore) CommitBlock(block models.Block, rewards []models.UTXO) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	blocks := config.GetCollection(BlocksCollection)
+	utxos := config.GetCollection(UTXOsCollection)
+	transactions := config.GetCollection(TransactionsCollection)
+	pending := config.GetCollection(PendingTransactionsCollection)
+	upsert := options.Update().SetUpsert(true)
+
+	// Indexes cannot be created inside a session transaction
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, _ = blocks.Indexes().CreateOne(ctx, indexModel)
+
+	session, err := config.MongoClient.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := blocks.UpdateOne(sc, bson.M{"hash": block.Hash}, bson.M{"$set": block}, upsert); err != nil {
+			return nil, err
+		}
+
+		for i := range rewards {
+			if _, err := utxos.UpdateOne(sc, bson.M{"_id": rewards[i].ID}, bson.M{"$set": rewards[i]}, upsert); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, tx := range block.Transactions {
+			tx.Status = "confirmed"
+			tx.BlockHash = block.Hash
+			if _, err := transactions.UpdateOne(sc, bson.M{"hash": tx.Hash}, bson.M{"$set": tx}, upsert); err != nil {
+				return nil, err
+			}
+			if _, err := pending.DeleteOne(sc, bson.M{"transaction.hash": tx.Hash}); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	})
+
+	return err
+}
+
 // GetAllBlocks retrieves all blocks
-func GetAllBlocks() ([]models.Block, error) {
+func (m *MongoStore) GetAllBlocks() ([]models.Block, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -433,7 +658,7 @@ func GetAllBlocks() ([]models.Block, error) {
 }
 
 // GetBlockByHash retrieves a block by hash
-func GetBlockByHash(hash string) (*models.Block, error) {
+func (m *MongoStore) GetBlockByHash(hash string) (*models.Block, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -449,7 +674,7 @@ func GetBlockByHash(hash string) (*models.Block, error) {
 }
 
 // GetBlockByIndex retrieves a block by index
-func GetBlockByIndex(index int64) (*models.Block, error) {
+func (m *MongoStore) GetBlockByIndex(index int64) (*models.Block, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -467,7 +692,7 @@ func GetBlockByIndex(index int64) (*models.Block, error) {
 // Zakat operations
 
 // SaveZakatDeduction saves a zakat deduction
-func SaveZakatDeduction(deduction *models.ZakatDeduction) error {
+func (m *MongoStore) SaveZakatDeduction(deduction *models.ZakatDeduction) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -482,7 +707,7 @@ func SaveZakatDeduction(deduction *models.ZakatDeduction) error {
 }
 
 // GetUserZakatDeductions retrieves all zakat deductions for a user
-func GetUserZakatDeductions(userID string) ([]models.ZakatDeduction, error) {
+func (m *MongoStore) GetUserZakatDeductions(userID string) ([]models.ZakatDeduction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -504,7 +729,7 @@ func GetUserZakatDeductions(userID string) ([]models.ZakatDeduction, error) {
 }
 
 // GetAllZakatDeductions retrieves all zakat deductions
-func GetAllZakatDeductions() ([]models.ZakatDeduction, error) {
+func (m *MongoStore) GetAllZakatDeductions() ([]models.ZakatDeduction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -525,10 +750,142 @@ func GetAllZakatDeductions() ([]models.ZakatDeduction, error) {
 	return deductions, nil
 }
 
+// Multisig proposal operations
+
+// SaveMultisigProposal saves a multisig spend proposal to MongoDB
+func (m *MongoStore) SaveMultisigProposal(proposal *models.MultisigProposal) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(MultisigProposalsCollection)
+
+	filter := bson.M{"_id": proposal.ID}
+	update := bson.M{"$set": proposal}
+	opts := options.Update().SetUpsert(true)
+
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
+// GetMultisigProposal retrieves a multisig spend proposal by ID from MongoDB
+func (m *MongoStore) GetMultisigProposal(id string) (*models.MultisigProposal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(MultisigProposalsCollection)
+
+	var proposal models.MultisigProposal
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&proposal); err != nil {
+		return nil, err
+	}
+
+	return &proposal, nil
+}
+
+// GetWalletMultisigProposals retrieves a multisig wallet's proposals from MongoDB, newest first
+func (m *MongoStore) GetWalletMultisigProposals(walletID string) ([]models.MultisigProposal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(MultisigProposalsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.M{"walletId": walletID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var proposals []models.MultisigProposal
+	if err = cursor.All(ctx, &proposals); err != nil {
+		return nil, err
+	}
+
+	return proposals, nil
+}
+
+// Scheduled transfer operations
+
+// SaveScheduledTransfer saves a scheduled transfer to MongoDB
+func (m *MongoStore) SaveScheduledTransfer(transfer *models.ScheduledTransfer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(ScheduledTransfersCollection)
+
+	filter := bson.M{"_id": transfer.ID}
+	update := bson.M{"$set": transfer}
+	opts := options.Update().SetUpsert(true)
+
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
+// GetScheduledTransfer retrieves a scheduled transfer by ID from MongoDB
+func (m *MongoStore) GetScheduledTransfer(id string) (*models.ScheduledTransfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(ScheduledTransfersCollection)
+
+	var transfer models.ScheduledTransfer
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&transfer); err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// GetUserScheduledTransfers retrieves a user's scheduled transfers from MongoDB, newest first
+func (m *MongoStore) GetUserScheduledTransfers(userID string) ([]models.ScheduledTransfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(ScheduledTransfersCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := collection.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var transfers []models.ScheduledTransfer
+	if err = cursor.All(ctx, &transfers); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+// GetDueScheduledTransfers retrieves active scheduled transfers due at or before now
+// from MongoDB, earliest first
+func (m *MongoStore) GetDueScheduledTransfers(now time.Time) ([]models.ScheduledTransfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := config.GetCollection(ScheduledTransfersCollection)
+
+	filter := bson.M{"status": "active", "nextRunAt": bson.M{"$lte": now}}
+	opts := options.Find().SetSort(bson.D{{Key: "nextRunAt", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var transfers []models.ScheduledTransfer
+	if err = cursor.All(ctx, &transfers); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
 // Logging operations
 
 // SaveSystemLog saves a system log
-func SaveSystemLog(log *models.SystemLog) error {
+func (m *MongoStore) SaveSystemLog(log *models.SystemLog) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -543,7 +900,7 @@ func SaveSystemLog(log *models.SystemLog) error {
 }
 
 // GetSystemLogs retrieves system logs with optional filters
-func GetSystemLogs(logType string, limit int) ([]models.SystemLog, error) {
+func (m *MongoStore) GetSystemLogs(logType string, limit int) ([]models.SystemLog, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -574,7 +931,7 @@ func GetSystemLogs(logType string, limit int) ([]models.SystemLog, error) {
 }
 
 // SaveTransactionLog saves a transaction log
-func SaveTransactionLog(log *models.TransactionLog) error {
+func (m *MongoStore) SaveTransactionLog(log *models.TransactionLog) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -589,7 +946,7 @@ func SaveTransactionLog(log *models.TransactionLog) error {
 }
 
 // GetUserTransactionLogs retrieves transaction logs for a user
-func GetUserTransactionLogs(userID string, limit int) ([]models.TransactionLog, error) {
+func (m *MongoStore) GetUserTransactionLogs(userID string, limit int) ([]models.TransactionLog, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -613,3 +970,84 @@ func GetUserTransactionLogs(userID string, limit int) ([]models.TransactionLog,
 
 	return logs, nil
 }
+
+// Migrations
+
+// MigrateAmounts rewrites documents whose money fields are still BC doubles from before
+// amounts were stored as integer base units. Migrated documents no longer match, so the
+// migration can be re-run or resumed after a failure.
+func (m *MongoStore) MigrateAmounts() (map[string]int, error) {
+	migrated := make(map[string]int)
+	var err error
+
+	if migrated[UsersCollection], err = migrateAmountFields[models.User](UsersCollection,
+		"zakatTracking.totalDeducted", "zakatTracking.monthlyDeducted"); err != nil {
+		return migrated, err
+	}
+	if migrated[WalletsCollection], err = migrateAmountFields[models.Wallet](WalletsCollection,
+		"balance"); err != nil {
+		return migrated, err
+	}
+	if migrated[UTXOsCollection], err = migrateAmountFields[models.UTXO](UTXOsCollection,
+		"amount"); err != nil {
+		return migrated, err
+	}
+	if migrated[TransactionsCollection], err = migrateAmountFields[models.Transaction](TransactionsCollection,
+		"amount", "fee", "outputUtxos.amount"); err != nil {
+		return migrated, err
+	}
+	if migrated[PendingTransactionsCollection], err = migrateAmountFields[models.PendingTransaction](PendingTransactionsCollection,
+		"transaction.amount", "transaction.fee", "transaction.outputUtxos.amount"); err != nil {
+		return migrated, err
+	}
+	if migrated[BlocksCollection], err = migrateAmountFields[models.Block](BlocksCollection,
+		"transactions.amount", "transactions.fee", "transactions.outputUtxos.amount"); err != nil {
+		return migrated, err
+	}
+	if migrated[ZakatDeductionsCollection], err = migrateAmountFields[models.ZakatDeduction](ZakatDeductionsCollection,
+		"amount", "balanceBefore", "balanceAfter"); err != nil {
+		return migrated, err
+	}
+	if migrated[TransactionLogsCollection], err = migrateAmountFields[models.TransactionLog](TransactionLogsCollection,
+		"amount"); err != nil {
+		return migrated, err
+	}
+
+	return migrated, nil
+}
+
+// migrateAmountFields re-saves every document in a collection where any of the given
+// fields is a double. Decoding into T converts the doubles to base units.
+func migrateAmountFields[T any](collectionName string, fields ...string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	collection := config.GetCollection(collectionName)
+
+	var legacy []bson.M
+	for _, field := range fields {
+		legacy = append(legacy, bson.M{field: bson.M{"$type": "double"}})
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"$or": legacy})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var doc T
+		if err := cursor.Decode(&doc); err != nil {
+			return count, fmt.Errorf("failed to decode %s document: %v", collectionName, err)
+		}
+
+		filter := bson.M{"_id": cursor.Current.Lookup("_id")}
+		if _, err := collection.UpdateOne(ctx, filter, bson.M{"$set": doc}); err != nil {
+			return count, fmt.Errorf("failed to update %s document: %v", collectionName, err)
+		}
+		count++
+	}
+
+	return count, cursor.Err()
+}
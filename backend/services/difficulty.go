@@ -0,0 +1,158 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Block versions
+const (
+	// BlockVersionLegacy blocks count difficulty in leading hex zeros of the hash
+	BlockVersionLegacy = 0
+	// BlockVersionBits blocks count difficulty in leading zero bits of the hash
+	BlockVersionBits = 1
+	// BlockVersionHeader blocks hash a canonical binary header instead of a string of all fields
+	BlockVersionHeader = 2
+	// BlockVersionCoinbase blocks start with a coinbase paying the block subsidy plus fees
+	BlockVersionCoinbase = 3
+	// BlockVersionCanonicalCoinbase blocks hash their coinbase from its canonical
+	// serialization, so the merkle root commits to the reward's payee and amount
+	BlockVersionCanonicalCoinbase = 4
+)
+
+// currentBlockVersion is the version assigned to newly mined blocks
+const currentBlockVersion = BlockVersionCanonicalCoinbase
+
+// Retargeting configuration, loaded by InitBlockchain. All values are in bits.
+var (
+	retargetInterval  int64
+	targetBlockTime   time.Duration
+	maxDifficultyStep int
+	minDifficultyBits int
+	maxDifficultyBits int
+)
+
+// initDifficultyConfig loads the initial difficulty and retargeting parameters from environment.
+// MINING_DIFFICULTY_BITS takes precedence; the older MINING_DIFFICULTY counts hex zeros
+// and is converted to bits.
+func initDifficultyConfig() {
+	difficulty = getEnvInt("MINING_DIFFICULTY_BITS", 4*getEnvInt("MINING_DIFFICULTY", 4))
+
+	retargetInterval = int64(getEnvInt("DIFFICULTY_ADJUSTMENT_INTERVAL", 10))
+	targetBlockTime = time.Duration(getEnvInt("TARGET_BLOCK_TIME", 60)) * time.Second
+	maxDifficultyStep = getEnvInt("DIFFICULTY_MAX_STEP", 2)
+	minDifficultyBits = getEnvInt("MIN_DIFFICULTY_BITS", 4)
+	maxDifficultyBits = getEnvInt("MAX_DIFFICULTY_BITS", 32)
+}
+
+// difficultyBits returns a block's difficulty as a count of leading zero bits.
+// Legacy blocks required Difficulty leading hex zeros, which is 4 bits each.
+func difficultyBits(block models.Block) int {
+	if block.Version < BlockVersionBits {
+		return 4 * block.Difficulty
+	}
+	return block.Difficulty
+}
+
+// targetForBits returns the 256-bit target a hash must be below to have the given
+// number of leading zero bits
+func targetForBits(bits int) *big.Int {
+	if bits < 0 {
+		bits = 0
+	}
+	if bits > 256 {
+		bits = 256
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(256-bits))
+}
+
+// hashMeetsTarget reports whether a hex-encoded hash, read as a big-endian integer,
+// is below target
+func hashMeetsTarget(hash string, target *big.Int) bool {
+	hashBytes, err := hex.DecodeString(hash)
+	if err != nil || len(hashBytes) != 32 {
+		return false
+	}
+	return new(big.Int).SetBytes(hashBytes).Cmp(target) < 0
+}
+
+// blockMeetsDifficulty checks a block's hash against its own difficulty
+func blockMeetsDifficulty(block models.Block) bool {
+	return hashMeetsTarget(block.Hash, targetForBits(difficultyBits(block)))
+}
+
+// nextDifficulty returns the difficulty in bits required for the block that follows chain.
+// Every retargetInterval blocks, the time taken by the last interval is compared with
+// the target block time and difficulty moves by at most maxDifficultyStep bits, where
+// each bit doubles the expected work.
+func nextDifficulty(chain []models.Block) int {
+	if len(chain) == 0 {
+		return difficulty
+	}
+
+	tip := chain[len(chain)-1]
+	tipBits := difficultyBits(tip)
+	height := tip.Index + 1
+
+	if retargetInterval <= 1 || height%retargetInterval != 0 || int64(len(chain)) < retargetInterval {
+		return tipBits
+	}
+
+	first := chain[int64(len(chain))-retargetInterval]
+	actual := tip.Timestamp.Sub(first.Timestamp)
+	expected := targetBlockTime * time.Duration(retargetInterval-1)
+
+	// Guard against zero or negative spans from clock skew
+	if actual <= 0 {
+		actual = time.Millisecond
+	}
+
+	// Faster than target means more work per block
+	steps := int(math.Round(math.Log2(float64(expected) / float64(actual))))
+	if steps > maxDifficultyStep {
+		steps = maxDifficultyStep
+	}
+	if steps < -maxDifficultyStep {
+		steps = -maxDifficultyStep
+	}
+
+	next := tipBits + steps
+	if next < minDifficultyBits {
+		next = minDifficultyBits
+	}
+	if next > maxDifficultyBits {
+		next = maxDifficultyBits
+	}
+
+	return next
+}
+
+// GetCurrentDifficulty returns the difficulty in bits the next mined block must meet
+func GetCurrentDifficulty() int {
+	blockchainMutex.RLock()
+	defer blockchainMutex.RUnlock()
+	return nextDifficulty(blockchain)
+}
+
+// getEnvInt reads an integer from environment, falling back to a default
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvFloat reads a decimal number from environment, falling back to a default
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+// useRetargetConfig sets the retargeting parameters for a test and restores them after
+func useRetargetConfig(t *testing.T, interval int64, blockTime time.Duration, maxStep, minBits, maxBits int) {
+	t.Helper()
+
+	savedInterval, savedBlockTime := retargetInterval, targetBlockTime
+	savedStep, savedMin, savedMax, savedDifficulty := maxDifficultyStep, minDifficultyBits, maxDifficultyBits, difficulty
+	t.Cleanup(func() {
+		retargetInterval, targetBlockTime = savedInterval, savedBlockTime
+		maxDifficultyStep, minDifficultyBits, maxDifficultyBits, difficulty = savedStep, savedMin, savedMax, savedDifficulty
+	})
+
+	retargetInterval = interval
+	targetBlockTime = blockTime
+	maxDifficultyStep = maxStep
+	minDifficultyBits = minBits
+	maxDifficultyBits = maxBits
+}
+
+// difficultyTestChain returns n blocks at the given difficulty in bits, spacing apart
+func difficultyTestChain(n int, bits int, spacing time.Duration) []models.Block {
+	start := time.Unix(1700000000, 0)
+	chain := make([]models.Block, n)
+	for i := range chain {
+		chain[i] = models.Block{
+			Version:    BlockVersionBits,
+			Index:      int64(i),
+			Timestamp:  start.Add(time.Duration(i) * spacing),
+			Difficulty: bits,
+		}
+	}
+	return chain
+}
+
+func TestNextDifficultyRetargets(t *testing.T) {
+	useRetargetConfig(t, 10, time.Minute, 2, 4, 32)
+
+	tests := []struct {
+		name    string
+		bits    int
+		spacing time.Duration
+		want    int
+	}{
+		{"on target", 16, time.Minute, 16},
+		{"slightly fast", 16, 50 * time.Second, 16},
+		{"twice as fast", 16, 30 * time.Second, 17},
+		{"four times as fast", 16, 15 * time.Second, 18},
+		{"far too fast is bounded by the step", 16, time.Second, 18},
+		{"no time passed", 16, 0, 18},
+		{"twice as slow", 16, 2 * time.Minute, 15},
+		{"far too slow is bounded by the step", 16, time.Hour, 14},
+		{"clamped to the maximum", 31, time.Second, 32},
+		{"at the maximum", 32, time.Second, 32},
+		{"clamped to the minimum", 5, time.Hour, 4},
+		{"at the minimum", 4, time.Hour, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := difficultyTestChain(10, tt.bits, tt.spacing)
+			if got := nextDifficulty(chain); got != tt.want {
+				t.Errorf("nextDifficulty = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDifficultyKeepsTipOffInterval(t *testing.T) {
+	useRetargetConfig(t, 10, time.Minute, 2, 4, 32)
+
+	// Blocks far faster than target only move difficulty at multiples of the interval
+	for _, n := range []int{2, 5, 9, 11, 15, 19, 21} {
+		chain := difficultyTestChain(n, 16, time.Second)
+		if got := nextDifficulty(chain); got != 16 {
+			t.Errorf("height %d: nextDifficulty = %d, want the tip's 16", n, got)
+		}
+	}
+	for _, n := range []int{10, 20} {
+		chain := difficultyTestChain(n, 16, time.Second)
+		if got := nextDifficulty(chain); got != 18 {
+			t.Errorf("height %d: nextDifficulty = %d, want 18", n, got)
+		}
+	}
+
+	// A legacy tip counts its hex zeros as four bits each
+	legacy := difficultyTestChain(5, 4, time.Minute)
+	legacy[4].Version = BlockVersionLegacy
+	if got := nextDifficulty(legacy); got != 16 {
+		t.Errorf("legacy tip: nextDifficulty = %d, want 16", got)
+	}
+}
+
+func TestNextDifficultyWithoutRetargeting(t *testing.T) {
+	useRetargetConfig(t, 1, time.Minute, 2, 4, 32)
+	difficulty = 12
+
+	if got := nextDifficulty(nil); got != 12 {
+		t.Errorf("empty chain: nextDifficulty = %d, want the configured 12", got)
+	}
+	if got := nextDifficulty(difficultyTestChain(10, 16, time.Second)); got != 16 {
+		t.Errorf("interval 1: nextDifficulty = %d, want the tip's 16", got)
+	}
+}
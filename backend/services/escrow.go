@@ -0,0 +1,399 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Escrow payments are hash-time-locked: the sender locks an output to the SHA-256 of a
+// secret and a timeout. The receiver claims it with an "escrow_claim" transaction that
+// reveals the secret before the timeout; from the timeout on the sender takes it back
+// with an "escrow_refund". Until then the output belongs to neither balance: it is held
+// by EscrowWalletID with the parties recorded in its lock.
+
+// EscrowWalletID holds escrow outputs while they are locked
+const EscrowWalletID = "ESCROW"
+
+// Errors returned for escrow payments
+var (
+	ErrEscrowNotFound   = errors.New("escrow not found or already settled")
+	ErrEscrowExpired    = errors.New("escrow timeout has passed; only the sender can reclaim it")
+	ErrInvalidPreimage  = errors.New("preimage does not match the escrow hash lock")
+	ErrNotEscrowParty   = errors.New("wallet is not a party to this escrow")
+	ErrEscrowSpendShape = errors.New("escrow claims and refunds spend exactly one escrow output")
+)
+
+// Escrow is a locked escrow output as seen by one of its parties
+type Escrow struct {
+	UTXO    models.UTXO `json:"utxo"`
+	Role    string      `json:"role"`    // "receiver" or "sender"
+	Expired bool        `json:"expired"` // The receiver can no longer claim; the sender can reclaim
+}
+
+// BuildEscrowTransaction builds an unsigned payment whose output to the receiver is held
+// in escrow under hashLock until timeout, a block height or Unix time (see
+// LockTimeThreshold)
+func BuildEscrowTransaction(senderWalletID, receiverWalletID string, amount, fee models.Amount, hashLock string, timeout int64, note, senderPublicKey string) (*models.Transaction, error) {
+	if err := validateHashLock(hashLock); err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("escrow timeout must be a block height or Unix time")
+	}
+
+	tx, err := BuildTransaction(senderWalletID, receiverWalletID, amount, fee, note, senderPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The payment output built for the receiver is moved into escrow
+	lock := &models.HashTimeLock{
+		HashLock:       hashLock,
+		ClaimWalletID:  tx.ReceiverWalletID,
+		RefundWalletID: tx.SenderWalletID,
+		Timeout:        timeout,
+	}
+	tx.Type = "escrow"
+	if err := checkEscrowDeadline(*tx, lock, nextBlockHeight(), time.Now()); err != nil {
+		return nil, err
+	}
+
+	tx.OutputUTXOs[0].WalletID = EscrowWalletID
+	tx.OutputUTXOs[0].Lock = lock
+	tx.Hash = CalculateTransactionHash(*tx)
+
+	return tx, nil
+}
+
+// BuildEscrowClaim builds the unsigned transaction by which the receiver of an escrow
+// takes the payment by revealing the secret, paying fee from the escrowed amount
+func BuildEscrowClaim(walletID, utxoID, preimage string, fee models.Amount, publicKey string) (*models.Transaction, error) {
+	return buildEscrowSpend("escrow_claim", walletID, utxoID, preimage, fee, publicKey)
+}
+
+// BuildEscrowRefund builds the unsigned transaction by which the sender of an expired
+// escrow takes the payment back, paying fee from the escrowed amount
+func BuildEscrowRefund(walletID, utxoID string, fee models.Amount, publicKey string) (*models.Transaction, error) {
+	return buildEscrowSpend("escrow_refund", walletID, utxoID, "", fee, publicKey)
+}
+
+// buildEscrowSpend builds a claim or refund of one escrow output to walletID, checking the
+// spend conditions up front so the caller gets the reason before signing
+func buildEscrowSpend(txType, walletID, utxoID, preimage string, fee models.Amount, publicKey string) (*models.Transaction, error) {
+	if minFee := GetMinimumFee(); fee < minFee {
+		return nil, fmt.Errorf("minimum transaction fee is %s BC", minFee)
+	}
+
+	utxo, err := GetUTXOByID(utxoID)
+	if err != nil || utxo.Spent || utxo.Lock == nil {
+		return nil, ErrEscrowNotFound
+	}
+	if utxo.Amount <= fee {
+		return nil, fmt.Errorf("escrowed amount %s does not cover the fee %s", utxo.Amount, fee)
+	}
+
+	action := "claim"
+	if txType == "escrow_refund" {
+		action = "refund"
+	}
+
+	tx := &models.Transaction{
+		Version:          currentTransactionVersion,
+		SenderWalletID:   walletID,
+		ReceiverWalletID: walletID,
+		Amount:           utxo.Amount - fee,
+		Fee:              fee,
+		Note:             fmt.Sprintf("Escrow %s of %s", action, utxo.TransactionHash),
+		Timestamp:        storageTime(),
+		SenderPublicKey:  publicKey,
+		Preimage:         preimage,
+		InputUTXOs:       []string{utxo.ID},
+		OutputUTXOs: []models.UTXOOutput{{
+			WalletID: walletID,
+			Amount:   utxo.Amount - fee,
+		}},
+		Type:   txType,
+		Status: "pending",
+	}
+
+	if err := checkEscrowSpend(*tx, utxo.Lock); err != nil {
+		return nil, err
+	}
+	if err := checkEscrowDeadline(*tx, utxo.Lock, nextBlockHeight(), time.Now()); err != nil {
+		return nil, err
+	}
+
+	tx.Hash = CalculateTransactionHash(*tx)
+	return tx, nil
+}
+
+// GetEscrowPartyWallet returns the user's wallet that can claim an escrow output, or
+// reclaim it when refund is set
+func GetEscrowPartyWallet(userID, utxoID string, refund bool) (*models.Wallet, error) {
+	utxo, err := GetUTXOByID(utxoID)
+	if err != nil || utxo.Spent || utxo.Lock == nil {
+		return nil, ErrEscrowNotFound
+	}
+
+	walletID := utxo.Lock.ClaimWalletID
+	if refund {
+		walletID = utxo.Lock.RefundWalletID
+	}
+	wallet, err := GetOwnedWallet(userID, walletID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInputNotOwned, ErrNotEscrowParty)
+	}
+	return wallet, nil
+}
+
+// GetWalletEscrows lists the unsettled escrows in which any of the wallets is the
+// receiver or the sender
+func GetWalletEscrows(walletIDs []string) ([]Escrow, error) {
+	utxos, err := GetUTXOsByWallet(EscrowWalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make(map[string]bool, len(walletIDs))
+	for _, walletID := range walletIDs {
+		owned[walletID] = true
+	}
+
+	height, now := nextBlockHeight(), time.Now()
+	escrows := []Escrow{}
+	for _, utxo := range utxos {
+		if utxo.Lock == nil {
+			continue
+		}
+		role := ""
+		switch {
+		case owned[utxo.Lock.ClaimWalletID]:
+			role = "receiver"
+		case owned[utxo.Lock.RefundWalletID]:
+			role = "sender"
+		default:
+			continue
+		}
+		escrows = append(escrows, Escrow{
+			UTXO:    utxo,
+			Role:    role,
+			Expired: escrowExpired(utxo.Lock, height, now),
+		})
+	}
+	return escrows, nil
+}
+
+// validateEscrowOutputs checks that locked outputs appear only as the payment of an
+// "escrow" transaction, held by EscrowWalletID for its receiver and refundable to its
+// sender, and that escrow claims and refunds spend exactly one input
+func validateEscrowOutputs(tx models.Transaction) error {
+	if tx.Preimage != "" && (tx.Type != "escrow_claim" || tx.Version < TransactionVersionEscrow) {
+		return fmt.Errorf("only version %d escrow claims carry a preimage", TransactionVersionEscrow)
+	}
+	if (tx.Type == "escrow_claim" || tx.Type == "escrow_refund") && len(tx.InputUTXOs) != 1 {
+		return ErrEscrowSpendShape
+	}
+
+	locked := 0
+	for idx, output := range tx.OutputUTXOs {
+		if output.Lock == nil {
+			if output.WalletID == EscrowWalletID {
+				return fmt.Errorf("output %d pays the escrow wallet without a lock", idx)
+			}
+			continue
+		}
+		locked++
+
+		lock := output.Lock
+		switch {
+		case tx.Type != "escrow" || tx.Version < TransactionVersionEscrow:
+			return fmt.Errorf("output %d is escrow-locked in a %s transaction", idx, tx.Type)
+		case output.WalletID != EscrowWalletID:
+			return fmt.Errorf("escrow output %d must be held by %s", idx, EscrowWalletID)
+		case lock.ClaimWalletID != tx.ReceiverWalletID || lock.RefundWalletID != tx.SenderWalletID:
+			return fmt.Errorf("escrow output %d must be claimable by the receiver and refundable to the sender", idx)
+		case lock.Timeout <= 0:
+			return fmt.Errorf("escrow output %d has no timeout", idx)
+		}
+		if err := validateHashLock(lock.HashLock); err != nil {
+			return fmt.Errorf("escrow output %d: %v", idx, err)
+		}
+	}
+
+	if tx.Type == "escrow" && locked != 1 {
+		return fmt.Errorf("an escrow transaction must lock exactly one output")
+	}
+	return nil
+}
+
+// checkEscrowSpend checks that a transaction may spend an escrow output: a claim by the
+// receiver revealing the preimage of the hash lock, or a refund to the sender
+func checkEscrowSpend(tx models.Transaction, lock *models.HashTimeLock) error {
+	switch tx.Type {
+	case "escrow_claim":
+		if tx.SenderWalletID != lock.ClaimWalletID {
+			return fmt.Errorf("%w: %w", ErrInputNotOwned, ErrNotEscrowParty)
+		}
+		secret, err := hex.DecodeString(tx.Preimage)
+		if err != nil {
+			return fmt.Errorf("%w: preimage must be hex", ErrInvalidPreimage)
+		}
+		if hash := sha256.Sum256(secret); hex.EncodeToString(hash[:]) != lock.HashLock {
+			return ErrInvalidPreimage
+		}
+		return nil
+
+	case "escrow_refund":
+		if tx.SenderWalletID != lock.RefundWalletID {
+			return fmt.Errorf("%w: %w", ErrInputNotOwned, ErrNotEscrowParty)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: escrow outputs can only be claimed or refunded", ErrInputNotOwned)
+}
+
+// checkEscrowDeadline checks an escrow's timeout against the block at height mined at
+// blockTime: an escrow must be created and claimed before it, and refunded from it on.
+func checkEscrowDeadline(tx models.Transaction, lock *models.HashTimeLock, height int64, blockTime time.Time) error {
+	expired := escrowExpired(lock, height, blockTime)
+
+	switch {
+	case tx.Type == "escrow" && expired:
+		return fmt.Errorf("escrow timeout %s is already in the past", describeLockTime(lock.Timeout))
+	case tx.Type == "escrow_claim" && expired:
+		return ErrEscrowExpired
+	case tx.Type == "escrow_refund" && !expired:
+		return fmt.Errorf("%w: escrow can be reclaimed from %s", ErrTransactionLocked, describeLockTime(lock.Timeout))
+	}
+	return nil
+}
+
+// validateEscrowDeadlines checks the deadlines of the escrow a transaction creates or
+// spends for the next block
+func validateEscrowDeadlines(tx models.Transaction) error {
+	return checkEscrowDeadlines(tx, nextBlockHeight(), time.Now())
+}
+
+// checkEscrowDeadlines checks the deadlines of the escrow a transaction creates or
+// spends for the block at height mined at blockTime
+func checkEscrowDeadlines(tx models.Transaction, height int64, blockTime time.Time) error {
+	for _, output := range tx.OutputUTXOs {
+		if output.Lock != nil {
+			if err := checkEscrowDeadline(tx, output.Lock, height, blockTime); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, utxoID := range tx.InputUTXOs {
+		utxo, err := GetUTXOByID(utxoID)
+		if err != nil {
+			return fmt.Errorf("UTXO %s not found", utxoID)
+		}
+		if utxo.Lock != nil {
+			if err := checkEscrowDeadline(tx, utxo.Lock, height, blockTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dropExpiredEscrows removes from the pending pool the escrow transactions whose deadline
+// has passed for the block at height mined at blockTime, such as a claim accepted just
+// before the timeout that waited too long, together with pending transactions spending
+// their outputs. They are marked failed and the UTXO set is rebuilt without them, so the
+// escrow can still be refunded. The caller must hold miningMutex.
+func dropExpiredEscrows(height int64, blockTime time.Time) error {
+	// Check without blocking transfers first; expired escrows are rare
+	blockchainMutex.RLock()
+	pool := append([]models.Transaction(nil), pendingTransactions...)
+	blockchainMutex.RUnlock()
+	if len(expiredEscrowTransactions(pool, height, blockTime)) == 0 {
+		return nil
+	}
+
+	utxoSetMutex.Lock()
+	defer utxoSetMutex.Unlock()
+
+	blockchainMutex.Lock()
+	expired := expiredEscrowTransactions(pendingTransactions, height, blockTime)
+	remaining := make([]models.Transaction, 0, len(pendingTransactions))
+	var dropped []models.Transaction
+	for _, tx := range pendingTransactions {
+		if expired[tx.Hash] {
+			dropped = append(dropped, tx)
+		} else {
+			remaining = append(remaining, tx)
+		}
+	}
+	pendingTransactions = remaining
+	blockchainMutex.Unlock()
+
+	for _, tx := range dropped {
+		if err := RemovePendingTransaction(tx.Hash); err != nil {
+			return fmt.Errorf("failed to remove pending transaction %s: %v", tx.Hash, err)
+		}
+		tx.Status = "failed"
+		if err := UpdateTransaction(tx); err != nil {
+			log.Printf("Error updating transaction: %v", err)
+		}
+		LogSystemEvent("escrow_expired", fmt.Sprintf("Dropped pending transaction %s: escrow deadline passed before it was mined", tx.Hash), "", "")
+	}
+
+	if _, err := rebuildUTXOSet(false); err != nil {
+		return fmt.Errorf("failed to rebuild UTXOs after dropping expired escrows: %v", err)
+	}
+	return nil
+}
+
+// expiredEscrowTransactions returns the hashes of pending transactions that miss an
+// escrow deadline at height and blockTime, and of those spending their outputs
+func expiredEscrowTransactions(pool []models.Transaction, height int64, blockTime time.Time) map[string]bool {
+	expired := make(map[string]bool)
+	for _, tx := range pool {
+		if err := checkEscrowDeadlines(tx, height, blockTime); err != nil {
+			expired[tx.Hash] = true
+		}
+	}
+
+	for changed := len(expired) > 0; changed; {
+		changed = false
+		for _, tx := range pool {
+			if expired[tx.Hash] {
+				continue
+			}
+			for _, utxoID := range tx.InputUTXOs {
+				if utxo, err := GetUTXOByID(utxoID); err == nil && expired[utxo.TransactionHash] {
+					expired[tx.Hash] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return expired
+}
+
+// escrowExpired reports whether an escrow's timeout has been reached by the block at
+// height mined at blockTime
+func escrowExpired(lock *models.HashTimeLock, height int64, blockTime time.Time) bool {
+	if lock.Timeout < LockTimeThreshold {
+		return height >= lock.Timeout
+	}
+	return !blockTime.Before(time.Unix(lock.Timeout, 0))
+}
+
+// validateHashLock checks that a hash lock is a hex SHA-256 digest
+func validateHashLock(hashLock string) error {
+	if decoded, err := hex.DecodeString(hashLock); err != nil || len(decoded) != sha256.Size || hex.EncodeToString(decoded) != hashLock {
+		return fmt.Errorf("hash lock must be a lowercase hex SHA-256 digest")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// testPreimage is the escrow secret used by the tests, and testHashLock its hash lock
+var (
+	testPreimage = hex.EncodeToString([]byte("escrow secret"))
+	testHashLock = func() string {
+		hash := sha256.Sum256([]byte("escrow secret"))
+		return hex.EncodeToString(hash[:])
+	}()
+)
+
+// mustEscrow pays amount from one wallet into escrow for another until timeout, mines it
+// and returns the escrow output
+func mustEscrow(t *testing.T, from, to testWallet, amount models.Amount, timeout int64) models.UTXO {
+	t.Helper()
+
+	tx, err := BuildEscrowTransaction(from.WalletID, to.WalletID, amount, GetMinimumFee(), testHashLock, timeout, "", from.PublicKey)
+	if err != nil {
+		t.Fatalf("BuildEscrowTransaction: %v", err)
+	}
+	from.sign(t, tx)
+	if err := ProcessTransaction(*tx); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+
+	utxos, _ := GetUnspentUTXOs(EscrowWalletID)
+	for _, utxo := range utxos {
+		if utxo.TransactionHash == tx.Hash {
+			return utxo
+		}
+	}
+	t.Fatal("escrow output not found")
+	return models.UTXO{}
+}
+
+// escrowSpend builds and signs a claim or refund of an escrow output
+func escrowSpend(t *testing.T, w testWallet, utxoID string, refund bool) models.Transaction {
+	t.Helper()
+
+	build := func() (*models.Transaction, error) {
+		if refund {
+			return BuildEscrowRefund(w.WalletID, utxoID, GetMinimumFee(), w.PublicKey)
+		}
+		return BuildEscrowClaim(w.WalletID, utxoID, testPreimage, GetMinimumFee(), w.PublicKey)
+	}
+	tx, err := build()
+	if err != nil {
+		t.Fatalf("build escrow spend: %v", err)
+	}
+	w.sign(t, tx)
+	return *tx
+}
+
+func TestReplayTransactionChecksEscrowDeadline(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	// Mined in block 1; claimable in block 2, refundable from block 3
+	escrow := mustEscrow(t, alice, bob, 10*models.BC, 3)
+	claim := escrowSpend(t, bob, escrow.ID, false)
+	refund := models.Transaction{
+		Version:         currentTransactionVersion,
+		Type:            "escrow_refund",
+		SenderWalletID:  alice.WalletID,
+		SenderPublicKey: alice.PublicKey,
+		InputUTXOs:      []string{escrow.ID},
+		OutputUTXOs:     []models.UTXOOutput{{WalletID: alice.WalletID, Amount: escrow.Amount - GetMinimumFee()}},
+		Fee:             GetMinimumFee(),
+	}
+	refund.Hash = CalculateTransactionHash(refund)
+	alice.sign(t, &refund)
+
+	tests := []struct {
+		name       string
+		tx         models.Transaction
+		blockIndex int64
+		height     int64
+		valid      bool
+	}{
+		{"claim before timeout", claim, 2, 2, true},
+		{"claim mined at timeout", claim, 3, 3, false},
+		{"refund before timeout", refund, 2, 2, false},
+		{"refund at timeout", refund, 3, 3, true},
+		{"pending claim past timeout", claim, -1, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utxosByID := map[string]models.UTXO{escrow.ID: escrow}
+			replayed := map[outpoint]*replayedOutput{
+				{escrow.TransactionHash, escrow.OutputIndex}: {walletID: EscrowWalletID, amount: escrow.Amount, lock: escrow.Lock},
+			}
+			violations := replayTransaction(tt.tx, tt.blockIndex, tt.height, time.Now(), utxosByID, replayed)
+			if valid := len(violations) == 0; valid != tt.valid {
+				t.Errorf("valid = %v, want %v: %v", valid, tt.valid, violations)
+			}
+		})
+	}
+}
+
+func TestMinerDropsClaimPastEscrowDeadline(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	carol := newTestWallet(t, 0)
+
+	escrow := mustEscrow(t, alice, bob, 10*models.BC, 3)
+	claim := escrowSpend(t, bob, escrow.ID, false)
+	if err := ProcessTransaction(claim); err != nil {
+		t.Fatalf("claim before timeout: %v", err)
+	}
+
+	// The claim is still pending when block 3, the timeout, is mined
+	miningMutex.Lock()
+	err := dropExpiredEscrows(3, storageTime())
+	miningMutex.Unlock()
+	if err != nil {
+		t.Fatalf("dropExpiredEscrows: %v", err)
+	}
+
+	if pending, _ := memory.GetPendingTransactions(); len(pending) != 0 {
+		t.Fatalf("expired claim left in the pending pool: %v", pending)
+	}
+	if stored, _ := memory.GetTransaction(claim.Hash); stored.Status != "failed" {
+		t.Errorf("expired claim status = %q, want failed", stored.Status)
+	}
+	if utxo, _ := memory.GetUTXOByID(escrow.ID); utxo.Spent {
+		t.Error("escrow output still spent by the dropped claim")
+	}
+	if got := mustBalance(t, bob.WalletID); got != 0 {
+		t.Errorf("receiver kept %s from the dropped claim", got)
+	}
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Fatalf("chain invalid after dropping the claim: %v", report.Violations)
+	}
+
+	// Once block 3 is reached the sender takes the escrow back
+	if err := ProcessTransaction(alice.transfer(t, carol, models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+	if err := ProcessTransaction(escrowSpend(t, alice, escrow.ID, true)); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Fatalf("chain invalid after refund: %v", report.Violations)
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"sort"
+)
+
+// GetMinimumFee returns the smallest fee accepted on a transfer (MIN_TRANSACTION_FEE)
+func GetMinimumFee() models.Amount {
+	return models.AmountFromFloat(getEnvFloat("MIN_TRANSACTION_FEE", 0.001))
+}
+
+// getMaxBlockSize returns the byte budget for transactions in one block (MAX_BLOCK_SIZE)
+func getMaxBlockSize() int {
+	return getEnvInt("MAX_BLOCK_SIZE", 1000000)
+}
+
+// transactionSize returns the encoded size of a transaction in bytes
+func transactionSize(tx models.Transaction) int {
+	encoded, err := json.Marshal(tx)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// feeRate returns the fee paid per byte of transaction, in base units
+func feeRate(tx models.Transaction) float64 {
+	size := transactionSize(tx)
+	if size == 0 {
+		return 0
+	}
+	return float64(tx.Fee) / float64(size)
+}
+
+// selectBlockTransactions picks pending transactions by fee rate, highest first, until
+// the block size limit is reached. A transaction spending the change of another pending
+// transaction is only taken once its parent is in the block, so parents always come first.
+func selectBlockTransactions(pool []models.Transaction, maxSize int) []models.Transaction {
+	inPool := make(map[string]bool, len(pool))
+	for _, tx := range pool {
+		inPool[tx.Hash] = true
+	}
+
+	// Find in-pool parents through the UTXOs each transaction spends
+	parents := make(map[string][]string)
+	for _, tx := range pool {
+		for _, utxoID := range tx.InputUTXOs {
+			utxo, err := GetUTXOByID(utxoID)
+			if err != nil {
+				continue
+			}
+			if inPool[utxo.TransactionHash] && utxo.TransactionHash != tx.Hash {
+				parents[tx.Hash] = append(parents[tx.Hash], utxo.TransactionHash)
+			}
+		}
+	}
+
+	candidates := append([]models.Transaction(nil), pool...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return feeRate(candidates[i]) > feeRate(candidates[j])
+	})
+
+	var selected []models.Transaction
+	included := make(map[string]bool)
+	size := 0
+
+	for progress := true; progress; {
+		progress = false
+		for _, tx := range candidates {
+			if included[tx.Hash] {
+				continue
+			}
+
+			ready := true
+			for _, parent := range parents[tx.Hash] {
+				if !included[parent] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			txSize := transactionSize(tx)
+			if size+txSize > maxSize {
+				continue
+			}
+
+			selected = append(selected, tx)
+			included[tx.Hash] = true
+			size += txSize
+			progress = true
+		}
+	}
+
+	return selected
+}
+
+// totalFees sums the fees paid by transactions
+func totalFees(transactions []models.Transaction) models.Amount {
+	total := models.Amount(0)
+	for _, tx := range transactions {
+		total += tx.Fee
+	}
+	return total
+}
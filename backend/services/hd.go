@@ -0,0 +1,278 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned by HD wallet operations
+var (
+	ErrHDWalletExists    = errors.New("user already has an HD wallet")
+	ErrNoHDWallet        = errors.New("user has no HD wallet")
+	ErrHDAccountNotFound = errors.New("HD account not found")
+	ErrWalletNotOwned    = errors.New("wallet does not belong to user")
+)
+
+// MaxHDAccounts limits how many sub-accounts an HD wallet is created with
+const MaxHDAccounts = 10
+
+// hdMutex serialises address derivation so two requests never hand out the same index
+var hdMutex sync.Mutex
+
+// UserAddress is one of a user's wallets with its balance
+type UserAddress struct {
+	WalletID        string        `json:"walletId"`
+	Address         string        `json:"address"`
+	Label           string        `json:"label,omitempty"`
+	DerivationPath  string        `json:"derivationPath,omitempty"`
+	Primary         bool          `json:"primary"`
+	Balance         models.Amount `json:"balance"`
+	ImmatureBalance models.Amount `json:"immatureBalance"`
+}
+
+// CreateHDWallet sets up a user's HD wallet from the extended public keys of its first
+// accounts, given in account order, and creates the first receiving address of account 0.
+// The keys are derived from the mnemonic on the user's side, so the mnemonic and every
+// private key stay off the server.
+func CreateHDWallet(userID string, accountKeys []string) (*models.Wallet, error) {
+	if len(accountKeys) < 1 || len(accountKeys) > MaxHDAccounts {
+		return nil, fmt.Errorf("accounts must be between 1 and %d", MaxHDAccounts)
+	}
+
+	if err := saveHDAccounts(userID, accountKeys); err != nil {
+		return nil, err
+	}
+
+	LogSystemEvent("hd_wallet", fmt.Sprintf("HD wallet created with %d accounts", len(accountKeys)), userID, "")
+
+	return NewHDAddress(userID, 0, "")
+}
+
+// saveHDAccounts checks a user's account extended public keys and stores them on the user
+func saveHDAccounts(userID string, accountKeys []string) error {
+	hdMutex.Lock()
+	defer hdMutex.Unlock()
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if len(user.HDAccounts) > 0 {
+		return ErrHDWalletExists
+	}
+
+	seen := make(map[string]bool, len(accountKeys))
+	for i, encoded := range accountKeys {
+		accountKey, err := crypto.ParseExtendedPublicKey(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid key for account %d: %v", i, err)
+		}
+		normalized := accountKey.String()
+		if seen[normalized] {
+			return fmt.Errorf("account %d repeats an earlier account key", i)
+		}
+		seen[normalized] = true
+
+		user.HDAccounts = append(user.HDAccounts, models.HDAccount{
+			Index:             uint32(i),
+			Name:              fmt.Sprintf("Account %d", i),
+			ExtendedPublicKey: normalized,
+		})
+	}
+
+	if err := UpdateUser(user); err != nil {
+		return fmt.Errorf("failed to save HD wallet: %v", err)
+	}
+	return nil
+}
+
+// NewHDAddress derives the next receiving address of one of a user's HD accounts and
+// registers it as a wallet owned by the user
+func NewHDAddress(userID string, account uint32, label string) (*models.Wallet, error) {
+	hdMutex.Lock()
+	defer hdMutex.Unlock()
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.HDAccounts) == 0 {
+		return nil, ErrNoHDWallet
+	}
+
+	var hdAccount *models.HDAccount
+	for i := range user.HDAccounts {
+		if user.HDAccounts[i].Index == account {
+			hdAccount = &user.HDAccounts[i]
+		}
+	}
+	if hdAccount == nil {
+		return nil, ErrHDAccountNotFound
+	}
+
+	accountKey, err := crypto.ParseExtendedPublicKey(hdAccount.ExtendedPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// An index whose key is invalid is skipped, as BIP-32 prescribes
+	var addressKey *crypto.ExtendedKey
+	index := hdAccount.NextAddressIndex
+	for {
+		addressKey, err = accountKey.DerivePath(fmt.Sprintf("0/%d", index))
+		if !errors.Is(err, crypto.ErrInvalidChild) {
+			break
+		}
+		index++
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive address: %v", err)
+	}
+
+	publicKey := addressKey.PublicKeyPEM()
+	walletID, err := crypto.WalletIDFromPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wallet ID: %v", err)
+	}
+
+	wallet := &models.Wallet{
+		WalletID:       walletID,
+		UserID:         user.ID,
+		PublicKey:      publicKey,
+		Balance:        0,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		IsActive:       true,
+		Label:          label,
+		DerivationPath: crypto.HDAddressPath(account, index),
+	}
+	if err := SaveWallet(wallet); err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %v", err)
+	}
+
+	hdAccount.NextAddressIndex = index + 1
+	if err := UpdateUser(user); err != nil {
+		return nil, err
+	}
+
+	LogSystemEvent("hd_address", fmt.Sprintf("Derived address %s at %s", walletID, wallet.DerivationPath), userID, "")
+
+	return wallet, nil
+}
+
+// GetOwnedWallet returns a wallet if it belongs to the user, or ErrWalletNotOwned
+func GetOwnedWallet(userID, walletID string) (*models.Wallet, error) {
+	wallet, err := GetWalletByID(walletID)
+	if err != nil || wallet.UserID != userID {
+		return nil, ErrWalletNotOwned
+	}
+	return wallet, nil
+}
+
+// GetUserWalletIDs lists the IDs of all of a user's wallets, registration wallet first
+func GetUserWalletIDs(user *models.User) ([]string, error) {
+	wallets, err := GetUserWallets(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	walletIDs := []string{user.WalletID}
+	for _, wallet := range wallets {
+		if wallet.WalletID != user.WalletID {
+			walletIDs = append(walletIDs, wallet.WalletID)
+		}
+	}
+	return walletIDs, nil
+}
+
+// GetUserAddresses returns each of a user's wallets with its balance, registration
+// wallet first
+func GetUserAddresses(userID string) ([]UserAddress, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := GetUserWallets(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(wallets, func(i, j int) bool {
+		return wallets[i].WalletID == user.WalletID && wallets[j].WalletID != user.WalletID
+	})
+
+	addresses := make([]UserAddress, 0, len(wallets))
+	for _, wallet := range wallets {
+		balance, err := CalculateBalance(wallet.WalletID)
+		if err != nil {
+			return nil, err
+		}
+		immature, err := CalculateImmatureBalance(wallet.WalletID)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, UserAddress{
+			WalletID:        wallet.WalletID,
+			Address:         WalletAddress(wallet.WalletID),
+			Label:           wallet.Label,
+			DerivationPath:  wallet.DerivationPath,
+			Primary:         wallet.WalletID == user.WalletID,
+			Balance:         balance,
+			ImmatureBalance: immature,
+		})
+	}
+	return addresses, nil
+}
+
+// GetUTXOsByUser retrieves the unspent UTXOs of all of a user's wallets
+func GetUTXOsByUser(user *models.User) ([]models.UTXO, error) {
+	walletIDs, err := GetUserWalletIDs(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var utxos []models.UTXO
+	for _, walletID := range walletIDs {
+		walletUTXOs, err := GetUTXOsByWallet(walletID)
+		if err != nil {
+			return nil, err
+		}
+		utxos = append(utxos, walletUTXOs...)
+	}
+	return utxos, nil
+}
+
+// GetTransactionsByUser retrieves the transactions of all of a user's wallets, newest
+// first. A transfer between two of the user's own addresses is listed once.
+func GetTransactionsByUser(user *models.User) ([]models.Transaction, error) {
+	walletIDs, err := GetUserWalletIDs(user)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var transactions []models.Transaction
+	for _, walletID := range walletIDs {
+		walletTransactions, err := GetTransactionsByWallet(walletID)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range walletTransactions {
+			if !seen[tx.Hash] {
+				seen[tx.Hash] = true
+				transactions = append(transactions, tx)
+			}
+		}
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Timestamp.After(transactions[j].Timestamp)
+	})
+	return transactions, nil
+}
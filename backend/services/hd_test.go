@@ -0,0 +1,99 @@
+package services
+
+import (
+	"backend/crypto"
+	"errors"
+	"testing"
+)
+
+// newTestAccountKeys derives the extended public keys of a new mnemonic's first accounts,
+// as a user does before creating an HD wallet, and returns them with the master key
+func newTestAccountKeys(t *testing.T, accounts int) ([]string, *crypto.ExtendedKey) {
+	t.Helper()
+
+	mnemonic, err := crypto.NewMnemonic()
+	if err != nil {
+		t.Fatalf("NewMnemonic: %v", err)
+	}
+	seed, err := crypto.MnemonicToSeed(mnemonic, "")
+	if err != nil {
+		t.Fatalf("MnemonicToSeed: %v", err)
+	}
+	master, err := crypto.NewMasterKey(seed)
+	if err != nil {
+		t.Fatalf("NewMasterKey: %v", err)
+	}
+
+	var keys []string
+	for i := uint32(0); i < uint32(accounts); i++ {
+		accountKey, err := master.DerivePath(crypto.HDAccountPath(i))
+		if err != nil {
+			t.Fatalf("DerivePath: %v", err)
+		}
+		keys = append(keys, accountKey.Public().String())
+	}
+	return keys, master
+}
+
+func TestCreateHDWalletFromAccountKeys(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 0)
+	keys, master := newTestAccountKeys(t, 2)
+
+	wallet, err := CreateHDWallet(alice.UserID, keys)
+	if err != nil {
+		t.Fatalf("CreateHDWallet: %v", err)
+	}
+
+	// The address the server derives is the one the user can sign for
+	addressKey, err := master.DerivePath(crypto.HDAddressPath(0, 0))
+	if err != nil {
+		t.Fatalf("DerivePath: %v", err)
+	}
+	if wallet.PublicKey != addressKey.PublicKeyPEM() || wallet.UserID != alice.UserID {
+		t.Errorf("first address %s is not m/%s of the user's mnemonic", wallet.WalletID, crypto.HDAddressPath(0, 0))
+	}
+
+	second, err := NewHDAddress(alice.UserID, 1, "savings")
+	if err != nil {
+		t.Fatalf("NewHDAddress: %v", err)
+	}
+	addressKey, _ = master.DerivePath(crypto.HDAddressPath(1, 0))
+	if second.PublicKey != addressKey.PublicKeyPEM() {
+		t.Errorf("account 1 address %s is not derived from the account 1 key", second.WalletID)
+	}
+
+	if _, err := CreateHDWallet(alice.UserID, keys); !errors.Is(err, ErrHDWalletExists) {
+		t.Errorf("second CreateHDWallet: err = %v, want ErrHDWalletExists", err)
+	}
+}
+
+func TestCreateHDWalletRejectsInvalidAccountKeys(t *testing.T) {
+	useTestStore(t)
+	keys, master := newTestAccountKeys(t, 1)
+	privateKey, _ := master.DerivePath(crypto.HDAccountPath(0))
+	privatePEM, _ := privateKey.PrivateKeyPEM()
+
+	tests := []struct {
+		name string
+		keys []string
+	}{
+		{"no accounts", nil},
+		{"too many accounts", make([]string, MaxHDAccounts+1)},
+		{"not a key", []string{"mnemonic words are not a key"}},
+		{"private key", []string{privatePEM}},
+		{"repeated account", []string{keys[0], keys[0]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alice := newTestWallet(t, 0)
+			if _, err := CreateHDWallet(alice.UserID, tt.keys); err == nil {
+				t.Fatal("CreateHDWallet accepted invalid account keys")
+			}
+			if user, _ := GetUserByID(alice.UserID); len(user.HDAccounts) != 0 {
+				t.Errorf("rejected keys were stored: %v", user.HDAccounts)
+			}
+		})
+	}
+}
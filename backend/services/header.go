@@ -0,0 +1,83 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// blockHeaderSize is the length of a serialized header:
+// version(4) + index(8) + previousHash(32) + merkleRoot(32) + timestamp(8) + difficulty(4) + nonce(8)
+const blockHeaderSize = 96
+
+// HeaderOf extracts the header fields of a block
+func HeaderOf(block models.Block) models.BlockHeader {
+	return models.BlockHeader{
+		Version:      block.Version,
+		Index:        block.Index,
+		PreviousHash: block.PreviousHash,
+		MerkleRoot:   block.MerkleRoot,
+		Timestamp:    block.Timestamp,
+		Difficulty:   block.Difficulty,
+		Nonce:        block.Nonce,
+	}
+}
+
+// SerializeBlockHeader encodes a header as fixed-width big-endian fields. Hashes are
+// written as raw 32-byte values; anything that is not a 64-character hex string
+// (such as the genesis previous hash "0") is written as zeros.
+func SerializeBlockHeader(header models.BlockHeader) []byte {
+	buf := make([]byte, 0, blockHeaderSize)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(header.Version))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(header.Index))
+	buf = append(buf, hashBytes32(header.PreviousHash)...)
+	buf = append(buf, hashBytes32(header.MerkleRoot)...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(header.Timestamp.UnixNano()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(header.Difficulty))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(header.Nonce))
+	return buf
+}
+
+// HashBlockHeader returns the hex SHA-256 of a serialized header
+func HashBlockHeader(header models.BlockHeader) string {
+	hash := sha256.Sum256(SerializeBlockHeader(header))
+	return hex.EncodeToString(hash[:])
+}
+
+// hashBytes32 decodes a hex hash into 32 bytes, or returns zeros if it is not one
+func hashBytes32(hash string) []byte {
+	decoded, err := hex.DecodeString(hash)
+	if err != nil || len(decoded) != 32 {
+		return make([]byte, 32)
+	}
+	return decoded
+}
+
+// storageTime returns the current time at the precision MongoDB stores, so block
+// headers and signing payloads serialize identically after a database round-trip
+func storageTime() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// legacyBlockHash is the string-based hash used before BlockVersionHeader. It depends on
+// Timestamp.String() and the JSON of every transaction, so it is only kept to validate
+// old blocks.
+func legacyBlockHash(block models.Block) string {
+	transactionsJSON, _ := json.Marshal(block.Transactions)
+
+	data := fmt.Sprintf("%d%s%s%s%d%s",
+		block.Index,
+		block.Timestamp.String(),
+		string(transactionsJSON),
+		block.PreviousHash,
+		block.Nonce,
+		block.MerkleRoot,
+	)
+
+	return crypto.HashSHA256(data)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LockTimeThreshold separates the two meanings of a transaction's lock time, as in
+// Bitcoin: below it the lock time is a block height, from it on a Unix timestamp
+const LockTimeThreshold = 500000000
+
+// ErrTransactionLocked is returned for a transaction whose lock time has not passed
+var ErrTransactionLocked = errors.New("transaction is time-locked")
+
+// checkLockTime reports whether a transaction may be included in the block at height
+// mined at blockTime. A transaction locked to a height can go into that block; one
+// locked to a time can go into the first block mined at or after it.
+func checkLockTime(tx models.Transaction, height int64, blockTime time.Time) error {
+	if tx.LockTime == 0 {
+		return nil
+	}
+	if tx.LockTime < 0 {
+		return fmt.Errorf("lock time cannot be negative")
+	}
+	if tx.Version < TransactionVersionLockTime {
+		return fmt.Errorf("lock time requires transaction version %d", TransactionVersionLockTime)
+	}
+
+	if tx.LockTime < LockTimeThreshold {
+		if height < tx.LockTime {
+			return fmt.Errorf("%w until block %d, next block is %d", ErrTransactionLocked, tx.LockTime, height)
+		}
+		return nil
+	}
+
+	if blockTime.Before(time.Unix(tx.LockTime, 0)) {
+		return fmt.Errorf("%w until %s", ErrTransactionLocked, describeLockTime(tx.LockTime))
+	}
+	return nil
+}
+
+// describeLockTime formats a lock time as "block N" or an RFC 3339 time
+func describeLockTime(lockTime int64) string {
+	if lockTime < LockTimeThreshold {
+		return fmt.Sprintf("block %d", lockTime)
+	}
+	return time.Unix(lockTime, 0).UTC().Format(time.RFC3339)
+}
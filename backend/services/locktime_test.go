@@ -0,0 +1,81 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckLockTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		version  int
+		lockTime int64
+		height   int64
+		wantErr  bool
+		locked   bool
+	}{
+		{"no lock time", TransactionVersionLegacy, 0, 5, false, false},
+		{"height reached", TransactionVersionLockTime, 5, 5, false, false},
+		{"height not reached", TransactionVersionLockTime, 6, 5, true, true},
+		{"time reached", TransactionVersionLockTime, now.Unix(), 5, false, false},
+		{"time not reached", TransactionVersionLockTime, now.Unix() + 1, 5, true, true},
+		{"negative", TransactionVersionLockTime, -1, 5, true, false},
+		{"version without lock times", TransactionVersionCanonical, 1, 5, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := models.Transaction{Version: tt.version, LockTime: tt.lockTime}
+			err := checkLockTime(tx, tt.height, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrTransactionLocked) != tt.locked {
+				t.Errorf("err = %v, want ErrTransactionLocked %v", err, tt.locked)
+			}
+		})
+	}
+}
+
+func TestProcessTransactionHoldsBackLockedTransaction(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	build := func(lockTime int64) models.Transaction {
+		tx, err := BuildTransactionWithLockTime(alice.WalletID, bob.WalletID, 10*models.BC, GetMinimumFee(), "", alice.PublicKey, lockTime)
+		if err != nil {
+			t.Fatalf("BuildTransactionWithLockTime: %v", err)
+		}
+		alice.sign(t, tx)
+		return *tx
+	}
+
+	tests := []struct {
+		name     string
+		lockTime int64
+	}{
+		{"future height", nextBlockHeight() + 1},
+		{"future time", time.Now().Add(time.Hour).Unix()},
+	}
+	for _, tt := range tests {
+		if err := ProcessTransaction(build(tt.lockTime)); !errors.Is(err, ErrTransactionLocked) {
+			t.Errorf("%s: err = %v, want ErrTransactionLocked", tt.name, err)
+		}
+	}
+	if got := mustBalance(t, alice.WalletID); got != 100*models.BC {
+		t.Fatalf("locked transactions spent inputs: balance %s", got)
+	}
+
+	if err := ProcessTransaction(build(nextBlockHeight())); err != nil {
+		t.Fatalf("transaction locked to the next block: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Errorf("chain invalid: %v", report.Violations)
+	}
+}
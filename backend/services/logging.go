@@ -42,7 +42,7 @@ func LogSystemEventWithMetadata(logType, message, userID, ipAddress string, meta
 }
 
 // LogTransactionEvent logs a transaction-specific event
-func LogTransactionEvent(txHash, action, userID, walletID, ipAddress string, amount float64, status string) {
+func LogTransactionEvent(txHash, action, userID, walletID, ipAddress string, amount models.Amount, status string) {
 	txLog := &models.TransactionLog{
 		ID:              uuid.New().String(),
 		TransactionHash: txHash,
@@ -0,0 +1,773 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MemoryStore is an in-process Store implementation used for tests and local development.
+// Lookups that find nothing return mongo.ErrNoDocuments so callers behave the same
+// regardless of the backend.
+type MemoryStore struct {
+	mu              sync.RWMutex
+	users           map[string]models.User
+	wallets         map[string]models.Wallet
+	utxos           map[string]models.UTXO
+	transactions    map[string]models.Transaction
+	pending         []models.PendingTransaction
+	blocks          map[string]models.Block
+	zakatDeductions map[string]models.ZakatDeduction
+	proposals       map[string]models.MultisigProposal
+	scheduled       map[string]models.ScheduledTransfer
+	systemLogs      []models.SystemLog
+	transactionLogs []models.TransactionLog
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:           make(map[string]models.User),
+		wallets:         make(map[string]models.Wallet),
+		utxos:           make(map[string]models.UTXO),
+		transactions:    make(map[string]models.Transaction),
+		blocks:          make(map[string]models.Block),
+		zakatDeductions: make(map[string]models.ZakatDeduction),
+		proposals:       make(map[string]models.MultisigProposal),
+		scheduled:       make(map[string]models.ScheduledTransfer),
+	}
+}
+
+// User operations
+
+// SaveUser saves a user, enforcing unique emails
+func (m *MemoryStore) SaveUser(user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, existing := range m.users {
+		if id != user.ID && existing.Email == user.Email {
+			return fmt.Errorf("duplicate email: %s", user.Email)
+		}
+	}
+
+	m.users[user.ID] = copyUser(*user)
+	return nil
+}
+
+// GetUserByID retrieves a user by ID
+func (m *MemoryStore) GetUserByID(userID string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	user = copyUser(user)
+	return &user, nil
+}
+
+// GetUserByEmail retrieves a user by email
+func (m *MemoryStore) GetUserByEmail(email string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Email == email {
+			user = copyUser(user)
+			return &user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+// GetAllUsers retrieves all users
+func (m *MemoryStore) GetAllUsers() ([]models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]models.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, copyUser(user))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+	return users, nil
+}
+
+// GetUsersAfter retrieves up to limit users with IDs after afterID, in ID order
+func (m *MemoryStore) GetUsersAfter(afterID string, limit int) ([]models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []models.User
+	for id, user := range m.users {
+		if id > afterID {
+			users = append(users, copyUser(user))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// Wallet operations
+
+// SaveWallet saves a wallet
+func (m *MemoryStore) SaveWallet(wallet *models.Wallet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.wallets[wallet.WalletID] = copyWallet(*wallet)
+	return nil
+}
+
+// GetWalletByID retrieves a wallet by ID
+func (m *MemoryStore) GetWalletByID(walletID string) (*models.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wallet, ok := m.wallets[walletID]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	wallet = copyWallet(wallet)
+	return &wallet, nil
+}
+
+// GetAllWallets retrieves all wallets
+func (m *MemoryStore) GetAllWallets() ([]models.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wallets := make([]models.Wallet, 0, len(m.wallets))
+	for _, wallet := range m.wallets {
+		wallets = append(wallets, wallet)
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].WalletID < wallets[j].WalletID
+	})
+	return wallets, nil
+}
+
+// GetUserWallets retrieves a user's wallets ordered by creation time
+func (m *MemoryStore) GetUserWallets(userID string) ([]models.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var wallets []models.Wallet
+	for _, wallet := range m.wallets {
+		if wallet.UserID == userID {
+			wallets = append(wallets, wallet)
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].CreatedAt.Before(wallets[j].CreatedAt)
+	})
+	return wallets, nil
+}
+
+// GetMultisigWallets retrieves the multisig wallets a public key co-signs
+func (m *MemoryStore) GetMultisigWallets(publicKey string) ([]models.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var wallets []models.Wallet
+	for _, wallet := range m.wallets {
+		if wallet.Multisig == nil {
+			continue
+		}
+		for _, key := range wallet.Multisig.PublicKeys {
+			if key == publicKey {
+				wallets = append(wallets, copyWallet(wallet))
+				break
+			}
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].CreatedAt.Before(wallets[j].CreatedAt)
+	})
+	return wallets, nil
+}
+
+// UTXO operations
+
+// SaveUTXO saves a UTXO
+func (m *MemoryStore) SaveUTXO(utxo *models.UTXO) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.utxos[utxo.ID] = *utxo
+	return nil
+}
+
+// GetUTXOByID retrieves a UTXO by ID
+func (m *MemoryStore) GetUTXOByID(utxoID string) (*models.UTXO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	utxo, ok := m.utxos[utxoID]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &utxo, nil
+}
+
+// GetUnspentUTXOs retrieves all unspent UTXOs for a wallet
+func (m *MemoryStore) GetUnspentUTXOs(walletID string) ([]models.UTXO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var utxos []models.UTXO
+	for _, utxo := range m.utxos {
+		if utxo.WalletID == walletID && !utxo.Spent {
+			utxos = append(utxos, utxo)
+		}
+	}
+	sortUTXOs(utxos)
+	return utxos, nil
+}
+
+// GetAllUTXOs retrieves all UTXOs
+func (m *MemoryStore) GetAllUTXOs() ([]models.UTXO, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	utxos := make([]models.UTXO, 0, len(m.utxos))
+	for _, utxo := range m.utxos {
+		utxos = append(utxos, utxo)
+	}
+	sortUTXOs(utxos)
+	return utxos, nil
+}
+
+// ReplaceUTXOs replaces every UTXO under a single lock
+func (m *MemoryStore) ReplaceUTXOs(utxos []models.UTXO) error {
+	replaced := make(map[string]models.UTXO, len(utxos))
+	for _, utxo := range utxos {
+		replaced[utxo.ID] = utxo
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.utxos = replaced
+	return nil
+}
+
+// Transaction operations
+
+// SaveTransaction saves a transaction
+func (m *MemoryStore) SaveTransaction(tx *models.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.transactions[tx.Hash] = copyTransaction(*tx)
+	return nil
+}
+
+// GetTransaction retrieves a transaction by hash
+func (m *MemoryStore) GetTransaction(hash string) (*models.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tx, ok := m.transactions[hash]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	tx = copyTransaction(tx)
+	return &tx, nil
+}
+
+// GetWalletTransactions retrieves all transactions for a wallet, newest first
+func (m *MemoryStore) GetWalletTransactions(walletID string) ([]models.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var transactions []models.Transaction
+	for _, tx := range m.transactions {
+		if tx.SenderWalletID == walletID || tx.ReceiverWalletID == walletID || paysWallet(tx, walletID) {
+			transactions = append(transactions, copyTransaction(tx))
+		}
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Timestamp.After(transactions[j].Timestamp)
+	})
+	return transactions, nil
+}
+
+// Pending Transaction operations
+
+// SavePendingTransaction saves a pending transaction
+func (m *MemoryStore) SavePendingTransaction(tx models.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pendingTx := models.PendingTransaction{
+		Transaction: copyTransaction(tx),
+		ReceivedAt:  time.Now(),
+	}
+
+	for i, pt := range m.pending {
+		if pt.Transaction.Hash == tx.Hash {
+			m.pending[i] = pendingTx
+			return nil
+		}
+	}
+	m.pending = append(m.pending, pendingTx)
+	return nil
+}
+
+// GetPendingTransactions retrieves all pending transactions in arrival order
+func (m *MemoryStore) GetPendingTransactions() ([]models.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	transactions := make([]models.Transaction, len(m.pending))
+	for i, pt := range m.pending {
+		transactions[i] = copyTransaction(pt.Transaction)
+	}
+	return transactions, nil
+}
+
+// ClearPendingTransactions removes all pending transactions
+func (m *MemoryStore) ClearPendingTransactions() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = nil
+	return nil
+}
+
+// RemovePendingTransaction removes a specific pending transaction
+func (m *MemoryStore) RemovePendingTransaction(txHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, pt := range m.pending {
+		if pt.Transaction.Hash == txHash {
+			m.pending = append(m.pending[:i], m.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// CommitTransaction applies a transaction's UTXO changes and queues it under a single lock
+func (m *MemoryStore) CommitTransaction(tx models.Transaction, outputs []models.UTXO) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, utxoID := range tx.InputUTXOs {
+		utxo, ok := m.utxos[utxoID]
+		if !ok || utxo.Spent || seen[utxoID] {
+			return fmt.Errorf("%w: %s", ErrUTXOAlreadySpent, utxoID)
+		}
+		seen[utxoID] = true
+	}
+
+	spentAt := time.Now()
+	for _, utxoID := range tx.InputUTXOs {
+		utxo := m.utxos[utxoID]
+		utxo.Spent = true
+		utxo.SpentInTxHash = tx.Hash
+		utxo.SpentAt = spentAt
+		m.utxos[utxoID] = utxo
+	}
+
+	for _, output := range outputs {
+		m.utxos[output.ID] = output
+	}
+
+	m.transactions[tx.Hash] = copyTransaction(tx)
+	m.pending = append(m.pending, models.PendingTransaction{
+		Transaction: copyTransaction(tx),
+		ReceivedAt:  time.Now(),
+	})
+
+	return nil
+}
+
+// Block operations
+
+// SaveBlock saves a block
+func (m *MemoryStore) SaveBlock(block models.Block) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blocks[block.Hash] = copyBlock(block)
+	return nil
+}
+
+// CommitBlock saves a mined block, its rewards and its confirmed transactions under a
+// single lock
+func (m *MemoryStore) CommitBlock(block models.Block, rewards []models.UTXO) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blocks[block.Hash] = copyBlock(block)
+	for _, utxo := range rewards {
+		m.utxos[utxo.ID] = utxo
+	}
+
+	mined := make(map[string]bool, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		tx.Status = "confirmed"
+		tx.BlockHash = block.Hash
+		m.transactions[tx.Hash] = copyTransaction(tx)
+		mined[tx.Hash] = true
+	}
+
+	remaining := m.pending[:0:0]
+	for _, pt := range m.pending {
+		if !mined[pt.Transaction.Hash] {
+			remaining = append(remaining, pt)
+		}
+	}
+	m.pending = remaining
+	return nil
+}
+
+// GetAllBlocks retrieves all blocks ordered by index
+func (m *MemoryStore) GetAllBlocks() ([]models.Block, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	blocks := make([]models.Block, 0, len(m.blocks))
+	for _, block := range m.blocks {
+		blocks = append(blocks, copyBlock(block))
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Index < blocks[j].Index
+	})
+	return blocks, nil
+}
+
+// GetBlockByHash retrieves a block by hash
+func (m *MemoryStore) GetBlockByHash(hash string) (*models.Block, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	block, ok := m.blocks[hash]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	block = copyBlock(block)
+	return &block, nil
+}
+
+// GetBlockByIndex retrieves a block by index
+func (m *MemoryStore) GetBlockByIndex(index int64) (*models.Block, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, block := range m.blocks {
+		if block.Index == index {
+			block = copyBlock(block)
+			return &block, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// Zakat operations
+
+// SaveZakatDeduction saves a zakat deduction
+func (m *MemoryStore) SaveZakatDeduction(deduction *models.ZakatDeduction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.zakatDeductions[deduction.ID] = *deduction
+	return nil
+}
+
+// GetUserZakatDeductions retrieves all zakat deductions for a user, newest first
+func (m *MemoryStore) GetUserZakatDeductions(userID string) ([]models.ZakatDeduction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var deductions []models.ZakatDeduction
+	for _, deduction := range m.zakatDeductions {
+		if deduction.UserID == userID {
+			deductions = append(deductions, deduction)
+		}
+	}
+	sortZakatDeductions(deductions)
+	return deductions, nil
+}
+
+// GetAllZakatDeductions retrieves all zakat deductions, newest first
+func (m *MemoryStore) GetAllZakatDeductions() ([]models.ZakatDeduction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deductions := make([]models.ZakatDeduction, 0, len(m.zakatDeductions))
+	for _, deduction := range m.zakatDeductions {
+		deductions = append(deductions, deduction)
+	}
+	sortZakatDeductions(deductions)
+	return deductions, nil
+}
+
+// Logging operations
+
+// SaveSystemLog saves a system log
+func (m *MemoryStore) SaveSystemLog(log *models.SystemLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.systemLogs {
+		if existing.ID == log.ID {
+			m.systemLogs[i] = *log
+			return nil
+		}
+	}
+	m.systemLogs = append(m.systemLogs, *log)
+	return nil
+}
+
+// GetSystemLogs retrieves system logs with optional filters, newest first
+func (m *MemoryStore) GetSystemLogs(logType string, limit int) ([]models.SystemLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var logs []models.SystemLog
+	for i := len(m.systemLogs) - 1; i >= 0; i-- {
+		if logType != "" && m.systemLogs[i].Type != logType {
+			continue
+		}
+		logs = append(logs, m.systemLogs[i])
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Timestamp.After(logs[j].Timestamp)
+	})
+	if limit > 0 && len(logs) > limit {
+		logs = logs[:limit]
+	}
+	return logs, nil
+}
+
+// SaveTransactionLog saves a transaction log
+func (m *MemoryStore) SaveTransactionLog(log *models.TransactionLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.transactionLogs {
+		if existing.ID == log.ID {
+			m.transactionLogs[i] = *log
+			return nil
+		}
+	}
+	m.transactionLogs = append(m.transactionLogs, *log)
+	return nil
+}
+
+// GetUserTransactionLogs retrieves transaction logs for a user, newest first
+func (m *MemoryStore) GetUserTransactionLogs(userID string, limit int) ([]models.TransactionLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var logs []models.TransactionLog
+	for i := len(m.transactionLogs) - 1; i >= 0; i-- {
+		if m.transactionLogs[i].UserID == userID {
+			logs = append(logs, m.transactionLogs[i])
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Timestamp.After(logs[j].Timestamp)
+	})
+	if limit > 0 && len(logs) > limit {
+		logs = logs[:limit]
+	}
+	return logs, nil
+}
+
+// Migrations
+
+// MigrateAmounts has nothing to do: memory data never held float amounts
+func (m *MemoryStore) MigrateAmounts() (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+// Multisig proposal operations
+
+// SaveMultisigProposal saves a multisig spend proposal
+func (m *MemoryStore) SaveMultisigProposal(proposal *models.MultisigProposal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.proposals[proposal.ID] = copyProposal(*proposal)
+	return nil
+}
+
+// GetMultisigProposal retrieves a multisig spend proposal by ID
+func (m *MemoryStore) GetMultisigProposal(id string) (*models.MultisigProposal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	proposal, ok := m.proposals[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	proposal = copyProposal(proposal)
+	return &proposal, nil
+}
+
+// GetWalletMultisigProposals retrieves a multisig wallet's proposals, newest first
+func (m *MemoryStore) GetWalletMultisigProposals(walletID string) ([]models.MultisigProposal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var proposals []models.MultisigProposal
+	for _, proposal := range m.proposals {
+		if proposal.WalletID == walletID {
+			proposals = append(proposals, copyProposal(proposal))
+		}
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		return proposals[i].CreatedAt.After(proposals[j].CreatedAt)
+	})
+	return proposals, nil
+}
+
+// Scheduled transfer operations
+
+// SaveScheduledTransfer saves a scheduled transfer
+func (m *MemoryStore) SaveScheduledTransfer(transfer *models.ScheduledTransfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.scheduled[transfer.ID] = copyScheduledTransfer(*transfer)
+	return nil
+}
+
+// GetScheduledTransfer retrieves a scheduled transfer by ID
+func (m *MemoryStore) GetScheduledTransfer(id string) (*models.ScheduledTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	transfer, ok := m.scheduled[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	transfer = copyScheduledTransfer(transfer)
+	return &transfer, nil
+}
+
+// GetUserScheduledTransfers retrieves a user's scheduled transfers, newest first
+func (m *MemoryStore) GetUserScheduledTransfers(userID string) ([]models.ScheduledTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var transfers []models.ScheduledTransfer
+	for _, transfer := range m.scheduled {
+		if transfer.UserID == userID {
+			transfers = append(transfers, copyScheduledTransfer(transfer))
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].CreatedAt.After(transfers[j].CreatedAt)
+	})
+	return transfers, nil
+}
+
+// GetDueScheduledTransfers retrieves active scheduled transfers due at or before now,
+// earliest first
+func (m *MemoryStore) GetDueScheduledTransfers(now time.Time) ([]models.ScheduledTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var transfers []models.ScheduledTransfer
+	for _, transfer := range m.scheduled {
+		if transfer.Status == "active" && !transfer.NextRunAt.After(now) {
+			transfers = append(transfers, copyScheduledTransfer(transfer))
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].NextRunAt.Before(transfers[j].NextRunAt)
+	})
+	return transfers, nil
+}
+
+// Copy helpers keep callers from mutating stored records through shared slices
+
+func copyUser(user models.User) models.User {
+	user.Beneficiaries = append([]string{}, user.Beneficiaries...)
+	user.HDAccounts = append([]models.HDAccount(nil), user.HDAccounts...)
+	return user
+}
+
+func copyTransaction(tx models.Transaction) models.Transaction {
+	tx.InputUTXOs = append([]string(nil), tx.InputUTXOs...)
+	tx.OutputUTXOs = append([]models.UTXOOutput(nil), tx.OutputUTXOs...)
+	tx.Signatures = append([]models.KeySignature(nil), tx.Signatures...)
+	return tx
+}
+
+func copyWallet(wallet models.Wallet) models.Wallet {
+	if wallet.Multisig != nil {
+		multisig := *wallet.Multisig
+		multisig.PublicKeys = append([]string(nil), multisig.PublicKeys...)
+		wallet.Multisig = &multisig
+	}
+	return wallet
+}
+
+func copyProposal(proposal models.MultisigProposal) models.MultisigProposal {
+	proposal.Transaction = copyTransaction(proposal.Transaction)
+	proposal.Signatures = append([]models.KeySignature(nil), proposal.Signatures...)
+	return proposal
+}
+
+func copyScheduledTransfer(transfer models.ScheduledTransfer) models.ScheduledTransfer {
+	if transfer.EndAt != nil {
+		endAt := *transfer.EndAt
+		transfer.EndAt = &endAt
+	}
+	transfer.Runs = append([]models.ScheduledTransferRun(nil), transfer.Runs...)
+	return transfer
+}
+
+func copyBlock(block models.Block) models.Block {
+	transactions := make([]models.Transaction, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		transactions[i] = copyTransaction(tx)
+	}
+	block.Transactions = transactions
+	return block
+}
+
+func sortUTXOs(utxos []models.UTXO) {
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].CreatedAt.Equal(utxos[j].CreatedAt) {
+			return utxos[i].ID < utxos[j].ID
+		}
+		return utxos[i].CreatedAt.Before(utxos[j].CreatedAt)
+	})
+}
+
+func sortZakatDeductions(deductions []models.ZakatDeduction) {
+	sort.Slice(deductions, func(i, j int) bool {
+		return deductions[i].DeductedAt.After(deductions[j].DeductedAt)
+	})
+}
+
+// paysWallet reports whether any output of a transaction, such as a batch payment line,
+// goes to walletID
+func paysWallet(tx models.Transaction, walletID string) bool {
+	for _, output := range tx.OutputUTXOs {
+		if output.WalletID == walletID {
+			return true
+		}
+	}
+	return false
+}
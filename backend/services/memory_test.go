@@ -0,0 +1,152 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMemoryStoreGetUserByEmail(t *testing.T) {
+	memory := NewMemoryStore()
+	user := &models.User{ID: "user-1", Email: "alice@example.com", CreatedAt: time.Now()}
+	if err := memory.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+
+	found, err := memory.GetUserByEmail("alice@example.com")
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("GetUserByEmail = %v, %v; want %s", found, err, user.ID)
+	}
+
+	missing, err := memory.GetUserByEmail("bob@example.com")
+	if missing != nil || err == nil || err.Error() != "user not found" {
+		t.Errorf("GetUserByEmail(unknown) = %v, %v; want nil, user not found", missing, err)
+	}
+	if _, err := memory.GetUserByID("user-2"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("GetUserByID(unknown) err = %v, want mongo.ErrNoDocuments", err)
+	}
+
+	duplicate := &models.User{ID: "user-2", Email: "alice@example.com"}
+	if err := memory.SaveUser(duplicate); err == nil || !strings.Contains(err.Error(), "duplicate email") {
+		t.Errorf("SaveUser(duplicate email) err = %v, want duplicate email", err)
+	}
+}
+
+func TestMemoryStoreReturnsCopies(t *testing.T) {
+	memory := NewMemoryStore()
+
+	t.Run("user", func(t *testing.T) {
+		user := &models.User{ID: "user-1", Email: "alice@example.com", Beneficiaries: []string{"a"}}
+		if err := memory.SaveUser(user); err != nil {
+			t.Fatalf("SaveUser: %v", err)
+		}
+		user.Beneficiaries[0] = "changed after save"
+
+		got, _ := memory.GetUserByID("user-1")
+		got.Beneficiaries[0] = "changed after load"
+
+		again, _ := memory.GetUserByID("user-1")
+		if again.Beneficiaries[0] != "a" {
+			t.Errorf("stored beneficiary = %q, want a", again.Beneficiaries[0])
+		}
+	})
+
+	t.Run("wallet", func(t *testing.T) {
+		wallet := &models.Wallet{WalletID: "wallet-1", Multisig: &models.Multisig{Threshold: 1, PublicKeys: []string{"key"}}}
+		if err := memory.SaveWallet(wallet); err != nil {
+			t.Fatalf("SaveWallet: %v", err)
+		}
+		wallet.Multisig.PublicKeys[0] = "changed after save"
+
+		got, _ := memory.GetWalletByID("wallet-1")
+		got.Multisig.PublicKeys[0] = "changed after load"
+		got.Multisig.Threshold = 2
+
+		again, _ := memory.GetWalletByID("wallet-1")
+		if again.Multisig.PublicKeys[0] != "key" || again.Multisig.Threshold != 1 {
+			t.Errorf("stored multisig = %+v, want threshold 1 of [key]", *again.Multisig)
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		tx := &models.Transaction{Hash: "tx-1", InputUTXOs: []string{"in"}, OutputUTXOs: []models.UTXOOutput{{WalletID: "w", Amount: models.BC}}}
+		if err := memory.SaveTransaction(tx); err != nil {
+			t.Fatalf("SaveTransaction: %v", err)
+		}
+		tx.InputUTXOs[0] = "changed after save"
+
+		got, _ := memory.GetTransaction("tx-1")
+		got.OutputUTXOs[0].Amount = 0
+
+		again, _ := memory.GetTransaction("tx-1")
+		if again.InputUTXOs[0] != "in" || again.OutputUTXOs[0].Amount != models.BC {
+			t.Errorf("stored transaction = %+v, want its original input and output", *again)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		block := models.Block{Index: 7, Hash: "block-7", Transactions: []models.Transaction{{Hash: "tx-2", InputUTXOs: []string{"in"}}}}
+		if err := memory.SaveBlock(block); err != nil {
+			t.Fatalf("SaveBlock: %v", err)
+		}
+		block.Transactions[0].InputUTXOs[0] = "changed after save"
+
+		got, _ := memory.GetBlockByIndex(7)
+		got.Transactions[0].Hash = "changed after load"
+
+		again, _ := memory.GetBlockByHash("block-7")
+		if again.Transactions[0].Hash != "tx-2" || again.Transactions[0].InputUTXOs[0] != "in" {
+			t.Errorf("stored block transaction = %+v, want tx-2 spending in", again.Transactions[0])
+		}
+	})
+}
+
+func TestGetWalletByIDResolvesAliases(t *testing.T) {
+	useTestStore(t)
+	address := newTestWallet(t, 0)
+
+	// A wallet registered before addresses is stored under its legacy hex ID
+	_, publicKey, err := crypto.GenerateKeys(crypto.AlgorithmEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKeys: %v", err)
+	}
+	legacyID, err := crypto.LegacyWalletIDFromPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("LegacyWalletIDFromPublicKey: %v", err)
+	}
+	if err := SaveWallet(&models.Wallet{WalletID: legacyID, PublicKey: publicKey, IsActive: true}); err != nil {
+		t.Fatalf("SaveWallet: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		lookup string
+		want   string
+	}{
+		{"stored address", address.WalletID, address.WalletID},
+		{"upper-case address", strings.ToUpper(address.WalletID), address.WalletID},
+		{"legacy form of an address", crypto.WalletIDAlias(address.WalletID), address.WalletID},
+		{"stored legacy ID", legacyID, legacyID},
+		{"address form of a legacy ID", crypto.WalletIDAlias(legacyID), legacyID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet, err := GetWalletByID(tt.lookup)
+			if err != nil {
+				t.Fatalf("GetWalletByID(%s): %v", tt.lookup, err)
+			}
+			if wallet.WalletID != tt.want {
+				t.Errorf("GetWalletByID(%s) = %s, want %s", tt.lookup, wallet.WalletID, tt.want)
+			}
+		})
+	}
+
+	if _, err := GetWalletByID(crypto.WalletIDAlias(address.WalletID) + "00"); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("GetWalletByID(unknown) err = %v, want mongo.ErrNoDocuments", err)
+	}
+}
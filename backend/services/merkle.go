@@ -0,0 +1,135 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+)
+
+// Errors returned when building a merkle proof
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrTransactionNotMined = errors.New("transaction is not in a block yet")
+)
+
+// Sibling positions in a merkle proof
+const (
+	MerkleLeft  = "left"
+	MerkleRight = "right"
+)
+
+// merkleLevels builds the merkle tree bottom-up, from the transaction hashes to the root.
+// Levels with an odd number of hashes are padded by duplicating the last hash.
+func merkleLevels(transactions []models.Transaction) [][]string {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	var hashes []string
+	for _, tx := range transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+
+	var levels [][]string
+	for len(hashes) > 1 {
+		if len(hashes)%2 != 0 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+		levels = append(levels, hashes)
+
+		var newHashes []string
+		for i := 0; i < len(hashes); i += 2 {
+			newHashes = append(newHashes, hashMerklePair(hashes[i], hashes[i+1]))
+		}
+		hashes = newHashes
+	}
+
+	return append(levels, hashes)
+}
+
+// hashMerklePair hashes two child hashes into their parent
+func hashMerklePair(left, right string) string {
+	return crypto.HashSHA256(left + right)
+}
+
+// calculateMerkleRoot calculates the merkle root of transactions
+func calculateMerkleRoot(transactions []models.Transaction) string {
+	levels := merkleLevels(transactions)
+	if len(levels) == 0 {
+		return ""
+	}
+	return levels[len(levels)-1][0]
+}
+
+// merklePath returns the sibling hashes from the leaf at index up to the root
+func merklePath(transactions []models.Transaction, index int) []models.MerkleProofStep {
+	levels := merkleLevels(transactions)
+	path := []models.MerkleProofStep{}
+
+	for _, level := range levels[:len(levels)-1] {
+		if index%2 == 0 {
+			path = append(path, models.MerkleProofStep{Hash: level[index+1], Position: MerkleRight})
+		} else {
+			path = append(path, models.MerkleProofStep{Hash: level[index-1], Position: MerkleLeft})
+		}
+		index /= 2
+	}
+
+	return path
+}
+
+// GetTransactionProof builds a merkle inclusion proof for a mined transaction
+func GetTransactionProof(hash string) (*models.MerkleProof, error) {
+	blockchainMutex.RLock()
+	defer blockchainMutex.RUnlock()
+
+	for _, block := range blockchain {
+		for i, tx := range block.Transactions {
+			if tx.Hash != hash {
+				continue
+			}
+			return &models.MerkleProof{
+				TransactionHash:  hash,
+				TransactionIndex: i,
+				BlockHash:        block.Hash,
+				Header:           HeaderOf(block),
+				Path:             merklePath(block.Transactions, i),
+			}, nil
+		}
+	}
+
+	if _, err := GetTransactionByHash(hash); err != nil {
+		return nil, ErrTransactionNotFound
+	}
+	return nil, ErrTransactionNotMined
+}
+
+// VerifyMerkleProof recomputes the root from a transaction hash and its sibling path
+// and reports whether it equals merkleRoot
+func VerifyMerkleProof(txHash string, path []models.MerkleProofStep, merkleRoot string) bool {
+	current := txHash
+	for _, step := range path {
+		switch step.Position {
+		case MerkleLeft:
+			current = hashMerklePair(step.Hash, current)
+		case MerkleRight:
+			current = hashMerklePair(current, step.Hash)
+		default:
+			return false
+		}
+	}
+	return current == merkleRoot
+}
+
+// VerifyTransactionProof checks a proof end to end: the path must lead to the header's
+// merkle root and, for blocks that hash their binary header, the header must hash to
+// the block hash
+func VerifyTransactionProof(proof models.MerkleProof) bool {
+	if !VerifyMerkleProof(proof.TransactionHash, proof.Path, proof.Header.MerkleRoot) {
+		return false
+	}
+	if proof.Header.Version >= BlockVersionHeader {
+		return HashBlockHeader(proof.Header) == proof.BlockHash
+	}
+	return true
+}
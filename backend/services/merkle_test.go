@@ -0,0 +1,162 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// baselineMerkleRoot is the merkle root calculation blocks were mined with before proofs
+// were added; existing merkle roots must keep matching it
+func baselineMerkleRoot(transactions []models.Transaction) string {
+	if len(transactions) == 0 {
+		return ""
+	}
+
+	var hashes []string
+	for _, tx := range transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+
+	for len(hashes) > 1 {
+		if len(hashes)%2 != 0 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+
+		var newHashes []string
+		for i := 0; i < len(hashes); i += 2 {
+			newHashes = append(newHashes, crypto.HashSHA256(hashes[i]+hashes[i+1]))
+		}
+		hashes = newHashes
+	}
+
+	return hashes[0]
+}
+
+// merkleTestTransactions returns n transactions with distinct hashes
+func merkleTestTransactions(n int) []models.Transaction {
+	transactions := make([]models.Transaction, n)
+	for i := range transactions {
+		transactions[i].Hash = crypto.HashSHA256(fmt.Sprintf("tx-%d", i))
+	}
+	return transactions
+}
+
+func TestMerkleProofsVerifyForEveryLeaf(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 7, 8, 13} {
+		t.Run(fmt.Sprintf("%d transactions", n), func(t *testing.T) {
+			transactions := merkleTestTransactions(n)
+			root := calculateMerkleRoot(transactions)
+			if want := baselineMerkleRoot(transactions); root != want {
+				t.Fatalf("merkle root = %s, baseline = %s", root, want)
+			}
+
+			for i, tx := range transactions {
+				path := merklePath(transactions, i)
+				if !VerifyMerkleProof(tx.Hash, path, root) {
+					t.Errorf("proof for leaf %d does not verify", i)
+				}
+			}
+		})
+	}
+}
+
+func TestMerklePathSingleTransaction(t *testing.T) {
+	transactions := merkleTestTransactions(1)
+
+	path := merklePath(transactions, 0)
+	if len(path) != 0 {
+		t.Fatalf("path = %v, want empty", path)
+	}
+	if root := calculateMerkleRoot(transactions); root != transactions[0].Hash {
+		t.Errorf("root = %s, want the transaction hash %s", root, transactions[0].Hash)
+	}
+	if !VerifyMerkleProof(transactions[0].Hash, path, transactions[0].Hash) {
+		t.Error("single transaction proof does not verify")
+	}
+}
+
+func TestMerklePathOddLeafPairsWithItself(t *testing.T) {
+	transactions := merkleTestTransactions(5)
+
+	// The fifth leaf has no sibling, so it is paired with a copy of itself
+	path := merklePath(transactions, 4)
+	if len(path) != 3 {
+		t.Fatalf("path has %d steps, want 3", len(path))
+	}
+	if path[0].Hash != transactions[4].Hash || path[0].Position != MerkleRight {
+		t.Errorf("first step = %+v, want the leaf itself on the right", path[0])
+	}
+}
+
+func TestVerifyMerkleProofRejectsTampering(t *testing.T) {
+	transactions := merkleTestTransactions(6)
+	root := calculateMerkleRoot(transactions)
+	path := merklePath(transactions, 2)
+
+	tamperedSibling := append([]models.MerkleProofStep(nil), path...)
+	tamperedSibling[1].Hash = crypto.HashSHA256("forged")
+
+	swappedPosition := append([]models.MerkleProofStep(nil), path...)
+	swappedPosition[0].Position = MerkleLeft
+
+	unknownPosition := append([]models.MerkleProofStep(nil), path...)
+	unknownPosition[0].Position = "middle"
+
+	tests := []struct {
+		name   string
+		txHash string
+		path   []models.MerkleProofStep
+		root   string
+	}{
+		{"tampered sibling", transactions[2].Hash, tamperedSibling, root},
+		{"swapped position", transactions[2].Hash, swappedPosition, root},
+		{"unknown position", transactions[2].Hash, unknownPosition, root},
+		{"wrong index", transactions[2].Hash, merklePath(transactions, 3), root},
+		{"other transaction", transactions[3].Hash, path, root},
+		{"truncated path", transactions[2].Hash, path[:len(path)-1], root},
+		{"wrong root", transactions[2].Hash, path, crypto.HashSHA256("other root")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if VerifyMerkleProof(tt.txHash, tt.path, tt.root) {
+				t.Error("tampered proof verified")
+			}
+		})
+	}
+}
+
+func TestGetTransactionProof(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	tx := alice.transfer(t, bob, 10*models.BC)
+	if err := ProcessTransaction(tx); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	if _, err := GetTransactionProof(tx.Hash); !errors.Is(err, ErrTransactionNotMined) {
+		t.Errorf("pending transaction err = %v, want ErrTransactionNotMined", err)
+	}
+
+	block := mustMine(t, GetMinerWallet())
+	proof, err := GetTransactionProof(tx.Hash)
+	if err != nil {
+		t.Fatalf("GetTransactionProof: %v", err)
+	}
+	if proof.BlockHash != block.Hash || !VerifyTransactionProof(*proof) {
+		t.Errorf("proof for block %s does not verify against block %s", proof.BlockHash, block.Hash)
+	}
+
+	proof.Header.Nonce++
+	if VerifyTransactionProof(*proof) {
+		t.Error("proof with a tampered header verified")
+	}
+
+	if _, err := GetTransactionProof(crypto.HashSHA256("unknown")); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("unknown transaction err = %v, want ErrTransactionNotFound", err)
+	}
+}
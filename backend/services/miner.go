@@ -0,0 +1,133 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	minerTrigger    = make(chan struct{}, 1)
+	blockMined      = make(chan struct{})
+	blockMinedMutex sync.Mutex
+)
+
+// StartMiner starts the background miner. A block is assembled from the pending pool
+// every MINING_INTERVAL seconds, or sooner once MINING_TX_THRESHOLD transactions are waiting.
+func StartMiner() {
+	interval := getMiningInterval()
+	minerWalletID := GetMinerWallet()
+
+	log.Printf("Background miner started (interval: %s, threshold: %d)", interval, getMiningThreshold())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-minerTrigger:
+		}
+
+		if len(GetPendingTransactionsFromMemory()) == 0 {
+			continue
+		}
+
+		if _, err := MineBlock(minerWalletID); err != nil {
+			log.Printf("Background mining failed: %v", err)
+			LogSystemEvent("mining_failure", fmt.Sprintf("Background mining failed: %v", err), "", "")
+		}
+	}
+}
+
+// notifyMiner wakes the miner early when the pending pool reaches the threshold
+func notifyMiner() {
+	threshold := getMiningThreshold()
+	if threshold <= 0 || len(GetPendingTransactionsFromMemory()) < threshold {
+		return
+	}
+
+	select {
+	case minerTrigger <- struct{}{}:
+	default:
+		// A wake-up is already queued
+	}
+}
+
+// notifyBlockMined wakes every client waiting on a confirmation
+func notifyBlockMined() {
+	blockMinedMutex.Lock()
+	defer blockMinedMutex.Unlock()
+
+	close(blockMined)
+	blockMined = make(chan struct{})
+}
+
+// blockMinedChannel returns a channel that is closed when the next block is mined
+func blockMinedChannel() <-chan struct{} {
+	blockMinedMutex.Lock()
+	defer blockMinedMutex.Unlock()
+	return blockMined
+}
+
+// WaitForTransaction blocks until the transaction leaves the pending state or the
+// timeout expires, and returns its latest stored version
+func WaitForTransaction(hash string, timeout time.Duration) (*models.Transaction, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		// Subscribe before reading so a block mined in between is not missed
+		mined := blockMinedChannel()
+
+		tx, err := GetTransactionByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		if tx.Status != "pending" {
+			return tx, nil
+		}
+
+		select {
+		case <-mined:
+		case <-deadline.C:
+			return tx, nil
+		}
+	}
+}
+
+// GetMinerWallet returns the wallet ID credited for blocks mined in the background
+func GetMinerWallet() string {
+	minerWalletID := os.Getenv("MINER_WALLET_ID")
+	if minerWalletID == "" {
+		return "SYSTEM_MINER"
+	}
+	return minerWalletID
+}
+
+// getMiningInterval returns the time between mining rounds from environment
+func getMiningInterval() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("MINING_INTERVAL"))
+	if err != nil || seconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// getMiningThreshold returns the pending pool size that triggers early mining
+func getMiningThreshold() int {
+	thresholdStr := os.Getenv("MINING_TX_THRESHOLD")
+	if thresholdStr == "" {
+		return 10
+	}
+
+	threshold, err := strconv.Atoi(thresholdStr)
+	if err != nil {
+		return 10
+	}
+	return threshold
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by multisig wallet operations
+var (
+	ErrNotCosigner          = errors.New("you are not a co-signer of this multisig wallet")
+	ErrNotMultisigWallet    = errors.New("wallet is not a multisig wallet")
+	ErrMultisigExists       = errors.New("a multisig wallet with these keys and threshold already exists")
+	ErrProposalNotOpen      = errors.New("proposal is no longer open for signatures")
+	ErrSignerKeyNotOwned    = errors.New("signing key does not belong to one of your wallets")
+	ErrSignerNotInPolicy    = errors.New("signing key is not a co-signer of this wallet")
+	ErrProposalSignature    = errors.New("signature does not verify against the proposal hash")
+	ErrMultisigProposalGone = errors.New("multisig proposal not found")
+)
+
+// Multisig proposal statuses
+const (
+	ProposalOpen      = "open"
+	ProposalSubmitted = "submitted"
+	ProposalFailed    = "failed"
+)
+
+// multisigMutex serialises changes to proposals so concurrent signatures are not lost
+var multisigMutex sync.Mutex
+
+// CreateMultisigWallet registers an M-of-N wallet for a set of public keys. The creator
+// must hold one of the keys. The wallet belongs to no single user; co-signers find it
+// through their keys.
+func CreateMultisigWallet(userID string, threshold int, publicKeys []string, label string) (*models.Wallet, error) {
+	policy, err := crypto.NewMultisigPolicy(threshold, publicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cosignerIndexes(userID, policy)) == 0 {
+		return nil, ErrNotCosigner
+	}
+
+	policyPEM := policy.PEM()
+	walletID, err := crypto.WalletIDFromPublicKey(policyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wallet ID: %v", err)
+	}
+	if _, err := GetWalletByID(walletID); err == nil {
+		return nil, ErrMultisigExists
+	}
+
+	wallet := &models.Wallet{
+		WalletID:  walletID,
+		PublicKey: policyPEM,
+		Balance:   0,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		IsActive:  true,
+		Label:     label,
+		Multisig: &models.Multisig{
+			Threshold:  policy.Threshold,
+			PublicKeys: policy.PublicKeys,
+		},
+	}
+	if err := SaveWallet(wallet); err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %v", err)
+	}
+
+	LogSystemEvent("multisig_wallet", fmt.Sprintf("Created %d-of-%d multisig wallet %s", policy.Threshold, len(policy.PublicKeys), walletID), userID, "")
+
+	return wallet, nil
+}
+
+// GetUserMultisigWallets retrieves the multisig wallets any of a user's keys co-signs
+func GetUserMultisigWallets(userID string) ([]models.Wallet, error) {
+	seen := make(map[string]bool)
+	var wallets []models.Wallet
+	for _, publicKey := range userPublicKeys(userID) {
+		cosigned, err := GetMultisigWallets(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, wallet := range cosigned {
+			if !seen[wallet.WalletID] {
+				seen[wallet.WalletID] = true
+				wallets = append(wallets, wallet)
+			}
+		}
+	}
+
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].CreatedAt.Before(wallets[j].CreatedAt)
+	})
+	return wallets, nil
+}
+
+// GetCosignedWallet returns a multisig wallet and its policy if the user co-signs it
+func GetCosignedWallet(userID, walletID string) (*models.Wallet, *crypto.MultisigPolicy, error) {
+	wallet, err := GetWalletByID(walletID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid wallet ID: %v", err)
+	}
+	if wallet.Multisig == nil {
+		return nil, nil, ErrNotMultisigWallet
+	}
+
+	policy, err := crypto.ParseMultisigPolicy(wallet.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cosignerIndexes(userID, policy)) == 0 {
+		return nil, nil, ErrNotCosigner
+	}
+	return wallet, policy, nil
+}
+
+// ProposeMultisigSpend builds an unsigned transfer from a multisig wallet for its
+// co-signers to sign
+func ProposeMultisigSpend(userID, walletID, receiverWalletID string, amount, fee models.Amount, note string) (*models.MultisigProposal, error) {
+	wallet, policy, err := GetCosignedWallet(userID, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := BuildTransaction(wallet.WalletID, receiverWalletID, amount, fee, note, wallet.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal := &models.MultisigProposal{
+		ID:          uuid.New().String(),
+		WalletID:    wallet.WalletID,
+		Transaction: *tx,
+		Signatures:  []models.KeySignature{},
+		Threshold:   policy.Threshold,
+		Status:      ProposalOpen,
+		CreatedBy:   userID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := SaveMultisigProposal(proposal); err != nil {
+		return nil, fmt.Errorf("failed to save proposal: %v", err)
+	}
+
+	LogSystemEvent("multisig_proposal", fmt.Sprintf("Proposed spend %s of %s from %s", tx.Hash, amount, wallet.WalletID), userID, "")
+
+	return proposal, nil
+}
+
+// GetCosignedProposal returns a proposal if the user co-signs its wallet
+func GetCosignedProposal(userID, proposalID string) (*models.MultisigProposal, error) {
+	proposal, err := GetMultisigProposal(proposalID)
+	if err != nil {
+		return nil, ErrMultisigProposalGone
+	}
+	if _, _, err := GetCosignedWallet(userID, proposal.WalletID); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// SignMultisigProposal adds a co-signer's signature over the proposal hash. The key must
+// be in the wallet's policy and belong to one of the user's wallets; signing again with
+// the same key replaces the earlier signature. Once the threshold is met the transaction
+// is submitted to the pending pool. If it is rejected the proposal is marked failed and
+// the rejection is returned.
+func SignMultisigProposal(userID, proposalID, publicKey, signature string) (*models.MultisigProposal, error) {
+	multisigMutex.Lock()
+	defer multisigMutex.Unlock()
+
+	proposal, err := GetCosignedProposal(userID, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != ProposalOpen {
+		return nil, ErrProposalNotOpen
+	}
+
+	policy, err := crypto.ParseMultisigPolicy(proposal.Transaction.SenderPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	keyIndex := policy.KeyIndex(publicKey)
+	if keyIndex < 0 {
+		return nil, ErrSignerNotInPolicy
+	}
+	owned := false
+	for _, index := range cosignerIndexes(userID, policy) {
+		owned = owned || index == keyIndex
+	}
+	if !owned {
+		return nil, ErrSignerKeyNotOwned
+	}
+
+	if err := crypto.VerifyHashSignature(proposal.Transaction.Hash, signature, policy.PublicKeys[keyIndex]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProposalSignature, err)
+	}
+
+	signatures := []models.KeySignature{{KeyIndex: keyIndex, Signature: signature}}
+	for _, existing := range proposal.Signatures {
+		if existing.KeyIndex != keyIndex {
+			signatures = append(signatures, existing)
+		}
+	}
+	sort.Slice(signatures, func(i, j int) bool { return signatures[i].KeyIndex < signatures[j].KeyIndex })
+	proposal.Signatures = signatures
+	proposal.UpdatedAt = time.Now()
+
+	var submitErr error
+	if len(proposal.Signatures) >= proposal.Threshold {
+		tx := proposal.Transaction
+		tx.Signatures = proposal.Signatures
+		if submitErr = ProcessTransaction(tx); submitErr != nil {
+			proposal.Status = ProposalFailed
+			proposal.Error = submitErr.Error()
+		} else {
+			proposal.Status = ProposalSubmitted
+			proposal.Transaction = tx
+		}
+	}
+
+	if err := SaveMultisigProposal(proposal); err != nil {
+		return nil, fmt.Errorf("failed to save proposal: %v", err)
+	}
+
+	LogSystemEvent("multisig_signature", fmt.Sprintf("Signature %d of %d on proposal %s (%s)",
+		len(proposal.Signatures), proposal.Threshold, proposal.ID, proposal.Status), userID, "")
+
+	if submitErr != nil {
+		return proposal, submitErr
+	}
+	return proposal, nil
+}
+
+// verifyMultisigSignatures checks that a multisig spend carries valid signatures from
+// at least the threshold of distinct co-signers in the policy it commits to
+func verifyMultisigSignatures(tx models.Transaction) error {
+	policy, err := crypto.ParseMultisigPolicy(tx.SenderPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid multisig policy: %v", err)
+	}
+
+	signatures := make(map[int]string, len(tx.Signatures))
+	for _, signature := range tx.Signatures {
+		if _, duplicate := signatures[signature.KeyIndex]; duplicate {
+			return fmt.Errorf("invalid signature: key %d signed more than once", signature.KeyIndex)
+		}
+		signatures[signature.KeyIndex] = signature.Signature
+	}
+
+	if err := policy.VerifyThreshold(tx.Hash, signatures); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+// userPublicKeys lists the public keys of a user's own wallets
+func userPublicKeys(userID string) []string {
+	wallets, err := GetUserWallets(userID)
+	if err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(wallets))
+	for _, wallet := range wallets {
+		if wallet.Multisig == nil {
+			keys = append(keys, wallet.PublicKey)
+		}
+	}
+	return keys
+}
+
+// cosignerIndexes returns the positions in a policy of the keys the user holds
+func cosignerIndexes(userID string, policy *crypto.MultisigPolicy) []int {
+	var indexes []int
+	for _, publicKey := range userPublicKeys(userID) {
+		if index := policy.KeyIndex(publicKey); index >= 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetUserMultisigWalletsMatchesReformattedKeys(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 0)
+	bob := newTestWallet(t, 0)
+
+	multisig, err := CreateMultisigWallet(alice.UserID, 2, []string{alice.PublicKey, bob.PublicKey}, "joint")
+	if err != nil {
+		t.Fatalf("CreateMultisigWallet: %v", err)
+	}
+
+	// Bob's stored key uses Windows line endings and trailing whitespace
+	wallet, _ := GetWalletByID(bob.WalletID)
+	wallet.PublicKey = strings.ReplaceAll(bob.PublicKey, "\n", "\r\n") + "  \r\n"
+	if err := UpdateWallet(wallet); err != nil {
+		t.Fatalf("UpdateWallet: %v", err)
+	}
+
+	for _, member := range []testWallet{alice, bob} {
+		wallets, err := GetUserMultisigWallets(member.UserID)
+		if err != nil {
+			t.Fatalf("GetUserMultisigWallets: %v", err)
+		}
+		if len(wallets) != 1 || wallets[0].WalletID != multisig.WalletID {
+			t.Errorf("co-signer %s sees %d multisig wallets, want %s", member.WalletID, len(wallets), multisig.WalletID)
+		}
+	}
+
+	if wallets, _ := GetMultisigWallets("not a key"); len(wallets) != 0 {
+		t.Errorf("invalid key matched %d wallets", len(wallets))
+	}
+}
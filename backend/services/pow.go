@@ -0,0 +1,86 @@
+package services
+
+import (
+	"backend/models"
+	"context"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// proofOfWork searches for a nonce that satisfies the block's difficulty. The nonce space
+// is interleaved across worker goroutines (worker i tries i, i+n, i+2n, ...) and the
+// search stops as soon as one worker succeeds or ctx is cancelled.
+func proofOfWork(ctx context.Context, block models.Block) (models.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := getMiningWorkers()
+	target := targetForBits(difficultyBits(block))
+	found := make(chan models.Block, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(candidate models.Block, start int64) {
+			defer wg.Done()
+
+			for nonce := start; ; nonce += int64(workers) {
+				// Check for cancellation periodically rather than on every hash
+				if (nonce-start)%(1024*int64(workers)) == 0 && ctx.Err() != nil {
+					return
+				}
+
+				candidate.Nonce = nonce
+				candidate.Hash = calculateBlockHash(candidate)
+				if hashMeetsTarget(candidate.Hash, target) {
+					found <- candidate
+					return
+				}
+			}
+		}(block, int64(w))
+	}
+
+	// Signal once every worker has stopped
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case mined := <-found:
+		cancel()
+		<-done
+		return mined, nil
+	case <-done:
+		// All workers stopped without a result; a late find may still be buffered
+		select {
+		case mined := <-found:
+			return mined, nil
+		default:
+			return block, ctx.Err()
+		}
+	}
+}
+
+// miningContext returns the context used for a mining round, bounded by MINING_TIMEOUT
+// seconds when configured
+func miningContext() (context.Context, context.CancelFunc) {
+	seconds, err := strconv.Atoi(os.Getenv("MINING_TIMEOUT"))
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(seconds)*time.Second)
+}
+
+// getMiningWorkers returns the number of proof-of-work goroutines from environment
+func getMiningWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("MINING_WORKERS"))
+	if err != nil || workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return workers
+}
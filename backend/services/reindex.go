@@ -0,0 +1,251 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// UTXODifference describes how one UTXO record changes when the set is rebuilt
+type UTXODifference struct {
+	UTXOID          string       `json:"utxoId"`
+	TransactionHash string       `json:"transactionHash"`
+	OutputIndex     int          `json:"outputIndex"`
+	Change          string       `json:"change"` // "added", "removed", "changed"
+	Before          *models.UTXO `json:"before,omitempty"`
+	After           *models.UTXO `json:"after,omitempty"`
+}
+
+// BalanceDifference describes a wallet whose cached balance disagrees with the rebuilt UTXO set
+type BalanceDifference struct {
+	WalletID string        `json:"walletId"`
+	Cached   models.Amount `json:"cached"`
+	Rebuilt  models.Amount `json:"rebuilt"`
+}
+
+// ReindexReport summarises a UTXO reindex
+type ReindexReport struct {
+	DryRun               bool                `json:"dryRun"`
+	TransactionsReplayed int                 `json:"transactionsReplayed"`
+	UTXOsBefore          int                 `json:"utxosBefore"`
+	UTXOsAfter           int                 `json:"utxosAfter"`
+	UTXODifferences      []UTXODifference    `json:"utxoDifferences"`
+	BalanceDifferences   []BalanceDifference `json:"balanceDifferences"`
+}
+
+// ReindexUTXOs rebuilds the UTXO set by replaying every confirmed transaction in block
+// order, followed by the pending pool (whose effects are already committed), and then
+// recomputes every wallet's cached balance. Registration allowances are not on-chain and
+// are carried over as unspent seeds. Existing UTXO IDs are kept for the same output so
+// transaction inputs still resolve. With dryRun only the differences are reported.
+//
+// Mining, new transfers and registrations wait while the reindex runs, and the rebuilt
+// set replaces the stored one atomically, so an interrupted reindex leaves it unchanged.
+func ReindexUTXOs(dryRun bool) (*ReindexReport, error) {
+	miningMutex.Lock()
+	defer miningMutex.Unlock()
+	utxoSetMutex.Lock()
+	defer utxoSetMutex.Unlock()
+
+	return rebuildUTXOSet(dryRun)
+}
+
+// rebuildUTXOSet does the work of ReindexUTXOs. The caller must hold miningMutex and
+// utxoSetMutex for writing.
+func rebuildUTXOSet(dryRun bool) (*ReindexReport, error) {
+	blockchainMutex.RLock()
+	chain := append([]models.Block(nil), blockchain...)
+	pending := append([]models.Transaction(nil), pendingTransactions...)
+	blockchainMutex.RUnlock()
+
+	current, err := GetAllUTXOs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load UTXOs: %v", err)
+	}
+
+	currentByOutpoint := make(map[outpoint]models.UTXO, len(current))
+	for _, utxo := range current {
+		currentByOutpoint[outpoint{utxo.TransactionHash, utxo.OutputIndex}] = utxo
+	}
+
+	// Replay into a scratch store using the same spend/create logic as live transactions
+	scratch := NewMemoryStore()
+	for _, utxo := range current {
+		if isAllocationUTXO(utxo) {
+			seed := utxo
+			seed.Spent = false
+			seed.SpentInTxHash = ""
+			seed.SpentAt = time.Time{}
+			if err := scratch.SaveUTXO(&seed); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	report := &ReindexReport{
+		DryRun:      dryRun,
+		UTXOsBefore: len(current),
+	}
+
+	replayed := make(map[string]bool)
+	replay := func(tx models.Transaction, blockIndex int64) error {
+		if tx.Type == "genesis" || replayed[tx.Hash] {
+			return nil
+		}
+		replayed[tx.Hash] = true
+
+		outputs := NewOutputUTXOs(tx)
+		if isRewardTransaction(tx) {
+			outputs = coinbaseOutputUTXOs(tx, blockIndex)
+		}
+		for i := range outputs {
+			if existing, ok := currentByOutpoint[outpoint{tx.Hash, outputs[i].OutputIndex}]; ok {
+				outputs[i].ID = existing.ID
+				outputs[i].CreatedAt = existing.CreatedAt
+			}
+		}
+
+		if err := applyTransactionUTXOs(scratch, tx, outputs); err != nil {
+			return fmt.Errorf("block %d transaction %s: %v", blockIndex, tx.Hash, err)
+		}
+		report.TransactionsReplayed++
+		return nil
+	}
+
+	for _, block := range chain {
+		for _, tx := range block.Transactions {
+			if err := replay(tx, block.Index); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, tx := range pending {
+		if err := replay(tx, -1); err != nil {
+			return nil, err
+		}
+	}
+
+	rebuilt, err := scratch.GetAllUTXOs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the original spend time when a UTXO was spent by the same transaction
+	currentByID := make(map[string]models.UTXO, len(current))
+	for _, utxo := range current {
+		currentByID[utxo.ID] = utxo
+	}
+	for i := range rebuilt {
+		if before, ok := currentByID[rebuilt[i].ID]; ok && before.Spent && before.SpentInTxHash == rebuilt[i].SpentInTxHash {
+			rebuilt[i].SpentAt = before.SpentAt
+		}
+	}
+
+	report.UTXOsAfter = len(rebuilt)
+	report.UTXODifferences = diffUTXOSets(currentByID, rebuilt)
+
+	balances := make(map[string]models.Amount)
+	for _, utxo := range rebuilt {
+		if !utxo.Spent {
+			balances[utxo.WalletID] += utxo.Amount
+		}
+	}
+
+	wallets, err := GetAllWallets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallets: %v", err)
+	}
+
+	report.BalanceDifferences = []BalanceDifference{}
+	for _, wallet := range wallets {
+		if wallet.Balance != balances[wallet.WalletID] {
+			report.BalanceDifferences = append(report.BalanceDifferences, BalanceDifference{
+				WalletID: wallet.WalletID,
+				Cached:   wallet.Balance,
+				Rebuilt:  balances[wallet.WalletID],
+			})
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	// Replace the UTXO set
+	if err := ReplaceUTXOs(rebuilt); err != nil {
+		return nil, fmt.Errorf("failed to replace UTXOs: %v", err)
+	}
+
+	// Recompute cached balances
+	for i := range wallets {
+		wallets[i].Balance = balances[wallets[i].WalletID]
+		if err := UpdateWallet(&wallets[i]); err != nil {
+			return nil, fmt.Errorf("failed to update wallet %s: %v", wallets[i].WalletID, err)
+		}
+	}
+
+	log.Printf("UTXO reindex complete: %d transactions replayed, %d UTXOs (was %d)",
+		report.TransactionsReplayed, report.UTXOsAfter, report.UTXOsBefore)
+	LogSystemEvent("reindex", fmt.Sprintf("UTXO set rebuilt from %d transactions: %d UTXO and %d balance differences corrected",
+		report.TransactionsReplayed, len(report.UTXODifferences), len(report.BalanceDifferences)), "", "")
+
+	return report, nil
+}
+
+// diffUTXOSets lists UTXO records that are added, removed or changed by a rebuild
+func diffUTXOSets(currentByID map[string]models.UTXO, rebuilt []models.UTXO) []UTXODifference {
+	differences := []UTXODifference{}
+	rebuiltIDs := make(map[string]bool, len(rebuilt))
+
+	for i := range rebuilt {
+		after := rebuilt[i]
+		rebuiltIDs[after.ID] = true
+
+		before, ok := currentByID[after.ID]
+		if !ok {
+			differences = append(differences, UTXODifference{
+				UTXOID:          after.ID,
+				TransactionHash: after.TransactionHash,
+				OutputIndex:     after.OutputIndex,
+				Change:          "added",
+				After:           &after,
+			})
+			continue
+		}
+
+		if before.WalletID != after.WalletID ||
+			before.Amount != after.Amount ||
+			before.Spent != after.Spent ||
+			before.SpentInTxHash != after.SpentInTxHash {
+			differences = append(differences, UTXODifference{
+				UTXOID:          after.ID,
+				TransactionHash: after.TransactionHash,
+				OutputIndex:     after.OutputIndex,
+				Change:          "changed",
+				Before:          &before,
+				After:           &after,
+			})
+		}
+	}
+
+	for id, before := range currentByID {
+		if rebuiltIDs[id] {
+			continue
+		}
+		before := before
+		differences = append(differences, UTXODifference{
+			UTXOID:          id,
+			TransactionHash: before.TransactionHash,
+			OutputIndex:     before.OutputIndex,
+			Change:          "removed",
+			Before:          &before,
+		})
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].UTXOID < differences[j].UTXOID
+	})
+	return differences
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// failingReplaceStore is a memory store whose UTXO set replacement always fails
+type failingReplaceStore struct {
+	*MemoryStore
+}
+
+func (s failingReplaceStore) ReplaceUTXOs(utxos []models.UTXO) error {
+	return errors.New("replace failed")
+}
+
+func TestReindexUTXOsRepairsInflatedUTXO(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+
+	utxos, _ := memory.GetUnspentUTXOs(bob.WalletID)
+	utxos[0].Amount = 1000 * models.BC
+	if err := memory.SaveUTXO(&utxos[0]); err != nil {
+		t.Fatalf("SaveUTXO: %v", err)
+	}
+
+	report, err := ReindexUTXOs(true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(report.UTXODifferences) != 1 || report.UTXODifferences[0].Change != "changed" {
+		t.Fatalf("dry run differences = %+v, want one changed UTXO", report.UTXODifferences)
+	}
+	if got := mustBalance(t, bob.WalletID); got != 1000*models.BC {
+		t.Fatalf("dry run changed the UTXO set: balance %s", got)
+	}
+
+	if _, err := ReindexUTXOs(false); err != nil {
+		t.Fatalf("ReindexUTXOs: %v", err)
+	}
+	if got := mustBalance(t, bob.WalletID); got != 10*models.BC {
+		t.Errorf("balance after reindex = %s, want 10", got)
+	}
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Errorf("chain invalid after reindex: %v", violationTypes(report))
+	}
+}
+
+func TestReindexUTXOsLeavesSetWhenReplaceFails(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	before, _ := memory.GetAllUTXOs()
+
+	SetStore(failingReplaceStore{memory})
+	if _, err := ReindexUTXOs(false); err == nil {
+		t.Fatal("ReindexUTXOs succeeded although the replacement failed")
+	}
+
+	after, _ := memory.GetAllUTXOs()
+	if len(after) != len(before) {
+		t.Fatalf("failed reindex left %d UTXOs, want %d", len(after), len(before))
+	}
+	if got := mustBalance(t, bob.WalletID); got != 10*models.BC {
+		t.Errorf("balance after failed reindex = %s, want 10", got)
+	}
+}
+
+func TestReindexUTXOsKeepsConcurrentTransfers(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 100*models.BC)
+	carol := newTestWallet(t, 0)
+
+	payments := []models.Transaction{
+		alice.transfer(t, carol, 10*models.BC),
+		bob.transfer(t, carol, 20*models.BC),
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(payments)+1)
+	for _, tx := range payments {
+		wg.Add(1)
+		go func(tx models.Transaction) {
+			defer wg.Done()
+			errs <- ProcessTransaction(tx)
+		}(tx)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := ReindexUTXOs(false)
+		errs <- err
+	}()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := mustBalance(t, carol.WalletID); got != 30*models.BC {
+		t.Errorf("receiver balance = %s, want 30", got)
+	}
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Errorf("chain invalid after concurrent reindex: %v", violationTypes(report))
+	}
+}
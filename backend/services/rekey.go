@@ -0,0 +1,170 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"fmt"
+	"log"
+)
+
+// DefaultReencryptBatchSize is how many users ReencryptPrivateKeys saves per batch
+const DefaultReencryptBatchSize = 100
+
+// ReencryptFailure records a user whose private key could not be re-encrypted
+type ReencryptFailure struct {
+	UserID string `json:"userId"`
+	Error  string `json:"error"`
+}
+
+// ReencryptReport summarises a private key re-encryption run
+type ReencryptReport struct {
+	DryRun           bool               `json:"dryRun"`
+	ActiveKeyVersion uint32             `json:"activeKeyVersion"`
+	Users            int                `json:"users"`
+	AlreadyCurrent   int                `json:"alreadyCurrent"`
+	Reencrypted      int                `json:"reencrypted"`
+	Batches          int                `json:"batches"`
+	KeyVersions      map[string]int     `json:"keyVersions"` // Users per key version before the run; "legacy" for unversioned ciphertexts
+	Failures         []ReencryptFailure `json:"failures"`
+}
+
+// ReencryptPrivateKeys re-encrypts every user's stored private key under the active
+// keyring version. Users are loaded and saved batchSize at a time in ID order, so only
+// one batch is held in memory, and progress is logged after each batch. Each key is
+// decrypted with the version it names, re-encrypted, and checked to decrypt back to the
+// same key before it is saved.
+//
+// Users already on the active version are skipped, so an interrupted run is resumed by
+// running it again: it continues with the users that were not saved yet. With dryRun
+// only the counts are reported.
+func ReencryptPrivateKeys(batchSize int, dryRun bool) (*ReencryptReport, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultReencryptBatchSize
+	}
+
+	keyring, err := crypto.LoadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReencryptReport{
+		DryRun:           dryRun,
+		ActiveKeyVersion: keyring.ActiveVersion(),
+		KeyVersions:      make(map[string]int),
+		Failures:         []ReencryptFailure{},
+	}
+
+	var batch []reencryptedKey
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		report.Batches++
+		for _, entry := range batch {
+			if err := saveReencryptedKey(entry, dryRun); err != nil {
+				report.Failures = append(report.Failures, ReencryptFailure{UserID: entry.userID, Error: err.Error()})
+				continue
+			}
+			report.Reencrypted++
+		}
+		batch = batch[:0]
+		log.Printf("Re-encryption batch %d done: %d re-encrypted, %d users checked", report.Batches, report.Reencrypted, report.Users)
+	}
+
+	for afterID := ""; ; {
+		users, err := GetUsersAfter(afterID, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load users after %q: %v", afterID, err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		afterID = users[len(users)-1].ID
+		report.Users += len(users)
+
+		for _, user := range users {
+			if entry, ok := reencryptUser(keyring, user, report); ok {
+				batch = append(batch, entry)
+			}
+		}
+		flush()
+	}
+
+	if !dryRun {
+		LogSystemEvent("key_rotation", fmt.Sprintf("Re-encrypted %d private keys to key version %d (%d failed)",
+			report.Reencrypted, report.ActiveKeyVersion, len(report.Failures)), "", "")
+	}
+
+	return report, nil
+}
+
+// reencryptUser counts a user's key version in the report and re-encrypts the key if it
+// is not on the active version yet, reporting whether there is a key to save
+func reencryptUser(keyring *crypto.Keyring, user models.User, report *ReencryptReport) (reencryptedKey, bool) {
+	if user.PrivateKey == "" {
+		return reencryptedKey{}, false
+	}
+
+	version, isEnvelope := crypto.EnvelopeKeyVersion(user.PrivateKey)
+	if isEnvelope {
+		report.KeyVersions[fmt.Sprintf("%d", version)]++
+	} else {
+		report.KeyVersions["legacy"]++
+	}
+	if isEnvelope && version == keyring.ActiveVersion() {
+		report.AlreadyCurrent++
+		return reencryptedKey{}, false
+	}
+
+	reencrypted, err := reencryptPrivateKey(keyring, user.PrivateKey)
+	if err != nil {
+		report.Failures = append(report.Failures, ReencryptFailure{UserID: user.ID, Error: err.Error()})
+		return reencryptedKey{}, false
+	}
+
+	return reencryptedKey{userID: user.ID, previous: user.PrivateKey, reencrypted: reencrypted}, true
+}
+
+// reencryptedKey is a user's private key ciphertext before and after re-encryption
+type reencryptedKey struct {
+	userID      string
+	previous    string
+	reencrypted string
+}
+
+// saveReencryptedKey stores a re-encrypted key on a freshly loaded copy of the user, so
+// profile changes made while the job runs are kept. A key that changed since it was read
+// is left alone.
+func saveReencryptedKey(entry reencryptedKey, dryRun bool) error {
+	user, err := GetUserByID(entry.userID)
+	if err != nil {
+		return err
+	}
+	if user.PrivateKey != entry.previous {
+		return fmt.Errorf("private key changed during re-encryption, run again")
+	}
+	if dryRun {
+		return nil
+	}
+
+	user.PrivateKey = entry.reencrypted
+	return SaveUser(user)
+}
+
+// reencryptPrivateKey moves one ciphertext to the active key and verifies the result
+func reencryptPrivateKey(keyring *crypto.Keyring, encrypted string) (string, error) {
+	plaintext, err := keyring.Decrypt(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	reencrypted, err := keyring.Encrypt(plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	if check, err := keyring.Decrypt(reencrypted); err != nil || check != plaintext {
+		return "", fmt.Errorf("re-encrypted key does not decrypt to the original")
+	}
+	return reencrypted, nil
+}
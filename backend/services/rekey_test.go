@@ -0,0 +1,178 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// failingUserSaveStore fails every user save after the first allowed
+type failingUserSaveStore struct {
+	*MemoryStore
+	allowed int
+}
+
+func (s *failingUserSaveStore) SaveUser(user *models.User) error {
+	if s.allowed == 0 {
+		return errors.New("store unavailable")
+	}
+	s.allowed--
+	return s.MemoryStore.SaveUser(user)
+}
+
+// rekeyTestKey returns a base64 AES-256 key filled with b
+func rekeyTestKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// saveUsersWithKeys stores n users whose private keys are sealed with the current
+// keyring, returning the plaintext keys by user ID
+func saveUsersWithKeys(t *testing.T, n int) map[string]string {
+	t.Helper()
+
+	keys := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("user-%02d", i)
+		plaintext := fmt.Sprintf("private key %d", i)
+		encrypted, err := crypto.EncryptPrivateKey(plaintext)
+		if err != nil {
+			t.Fatalf("EncryptPrivateKey: %v", err)
+		}
+		user := &models.User{ID: id, Email: id + "@example.com", PrivateKey: encrypted, CreatedAt: time.Now()}
+		if err := SaveUser(user); err != nil {
+			t.Fatalf("SaveUser: %v", err)
+		}
+		keys[id] = plaintext
+	}
+
+	// A user without a stored key is counted but left alone
+	if err := SaveUser(&models.User{ID: "user-nokey", Email: "nokey@example.com"}); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+	return keys
+}
+
+// keyVersions returns the key version each user's private key is sealed with
+func keyVersions(t *testing.T, keys map[string]string) map[string]uint32 {
+	t.Helper()
+
+	versions := make(map[string]uint32, len(keys))
+	for id, plaintext := range keys {
+		user, err := GetUserByID(id)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if got, err := crypto.DecryptPrivateKey(user.PrivateKey); err != nil || got != plaintext {
+			t.Fatalf("%s key decrypts to %q, %v; want %q", id, got, err, plaintext)
+		}
+		versions[id], _ = crypto.EnvelopeKeyVersion(user.PrivateKey)
+	}
+	return versions
+}
+
+func TestGetUsersAfterPagesInIDOrder(t *testing.T) {
+	memory := NewMemoryStore()
+	for _, id := range []string{"c", "a", "e", "b", "d"} {
+		if err := memory.SaveUser(&models.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatalf("SaveUser: %v", err)
+		}
+	}
+
+	var pages [][]string
+	for afterID := ""; ; {
+		users, err := memory.GetUsersAfter(afterID, 2)
+		if err != nil {
+			t.Fatalf("GetUsersAfter: %v", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		var page []string
+		for _, user := range users {
+			page = append(page, user.ID)
+		}
+		pages = append(pages, page)
+		afterID = users[len(users)-1].ID
+	}
+
+	if got, want := fmt.Sprint(pages), "[[a b] [c d] [e]]"; got != want {
+		t.Errorf("pages = %s, want %s", got, want)
+	}
+}
+
+func TestReencryptPrivateKeysMovesKeysToActiveVersion(t *testing.T) {
+	useTestStore(t)
+	t.Setenv("AES_ENCRYPTION_KEY", rekeyTestKey(1))
+	t.Setenv("AES_ENCRYPTION_KEYS", "")
+	keys := saveUsersWithKeys(t, 5)
+
+	t.Setenv("AES_ENCRYPTION_KEYS", "2:"+rekeyTestKey(2))
+
+	dryRun, err := ReencryptPrivateKeys(2, true)
+	if err != nil {
+		t.Fatalf("ReencryptPrivateKeys(dry run): %v", err)
+	}
+	if dryRun.Reencrypted != 5 || dryRun.KeyVersions["1"] != 5 {
+		t.Errorf("dry run = %+v, want 5 keys to re-encrypt from version 1", dryRun)
+	}
+	for id, version := range keyVersions(t, keys) {
+		if version != 1 {
+			t.Fatalf("dry run moved %s to version %d", id, version)
+		}
+	}
+
+	report, err := ReencryptPrivateKeys(2, false)
+	if err != nil {
+		t.Fatalf("ReencryptPrivateKeys: %v", err)
+	}
+	if report.ActiveKeyVersion != 2 || report.Users != 6 || report.Reencrypted != 5 || report.Batches != 3 || len(report.Failures) != 0 {
+		t.Errorf("report = %+v, want 6 users, 5 re-encrypted in 3 batches to version 2", report)
+	}
+	for id, version := range keyVersions(t, keys) {
+		if version != 2 {
+			t.Errorf("%s is on version %d, want 2", id, version)
+		}
+	}
+}
+
+func TestReencryptPrivateKeysResumesAfterInterruption(t *testing.T) {
+	memory := useTestStore(t)
+	t.Setenv("AES_ENCRYPTION_KEY", rekeyTestKey(1))
+	t.Setenv("AES_ENCRYPTION_KEYS", "")
+	keys := saveUsersWithKeys(t, 5)
+
+	t.Setenv("AES_ENCRYPTION_KEYS", "2:"+rekeyTestKey(2))
+
+	// The store goes away after three saves
+	SetStore(&failingUserSaveStore{MemoryStore: memory, allowed: 3})
+	interrupted, err := ReencryptPrivateKeys(2, false)
+	if err != nil {
+		t.Fatalf("ReencryptPrivateKeys: %v", err)
+	}
+	if interrupted.Reencrypted != 3 || len(interrupted.Failures) != 2 {
+		t.Fatalf("interrupted run = %+v, want 3 re-encrypted and 2 failures", interrupted)
+	}
+	SetStore(memory)
+
+	resumed, err := ReencryptPrivateKeys(2, false)
+	if err != nil {
+		t.Fatalf("ReencryptPrivateKeys: %v", err)
+	}
+	if resumed.AlreadyCurrent != 3 || resumed.Reencrypted != 2 || len(resumed.Failures) != 0 {
+		t.Errorf("resumed run = %+v, want 3 already current and 2 re-encrypted", resumed)
+	}
+	for id, version := range keyVersions(t, keys) {
+		if version != 2 {
+			t.Errorf("%s is on version %d, want 2", id, version)
+		}
+	}
+
+	// Once version 1 is removed every key still opens
+	t.Setenv("AES_ENCRYPTION_KEY", "")
+	keyVersions(t, keys)
+}
@@ -0,0 +1,67 @@
+package services
+
+import "backend/models"
+
+// UserReport totals a user's activity across all of their addresses. Payments between
+// two of the user's own addresses are neither sent nor received.
+type UserReport struct {
+	WalletID         string        `json:"walletId"`
+	WalletIDs        []string      `json:"walletIds"`
+	CurrentBalance   models.Amount `json:"currentBalance"`
+	TotalSent        models.Amount `json:"totalSent"`
+	TotalReceived    models.Amount `json:"totalReceived"`
+	TotalFees        models.Amount `json:"totalFees"`
+	TransactionCount int           `json:"transactionCount"`
+	PaymentLines     []PaymentLine `json:"paymentLines"`
+}
+
+// GetUserReport builds a user's report from the transactions and balances of every
+// wallet they own
+func GetUserReport(user *models.User) (*UserReport, error) {
+	walletIDs, err := GetUserWalletIDs(user)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[string]bool, len(walletIDs))
+	for _, walletID := range walletIDs {
+		owned[walletID] = true
+	}
+
+	transactions, err := GetTransactionsByUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UserReport{
+		WalletID:         user.WalletID,
+		WalletIDs:        walletIDs,
+		TransactionCount: len(transactions),
+		PaymentLines:     GetWalletPaymentLines(transactions, walletIDs),
+	}
+
+	for _, tx := range transactions {
+		if owned[tx.SenderWalletID] {
+			report.TotalFees += tx.Fee
+		}
+	}
+
+	// A batch pays each wallet only its own line, not the batch total
+	for _, line := range report.PaymentLines {
+		switch {
+		case owned[line.SenderWalletID] && !owned[line.ReceiverWalletID]:
+			report.TotalSent += line.Amount
+		case owned[line.ReceiverWalletID] && !owned[line.SenderWalletID]:
+			report.TotalReceived += line.Amount
+		}
+	}
+
+	for _, walletID := range walletIDs {
+		balance, err := CalculateBalance(walletID)
+		if err != nil {
+			return nil, err
+		}
+		report.CurrentBalance += balance
+	}
+
+	return report, nil
+}
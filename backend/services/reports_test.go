@@ -0,0 +1,114 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+)
+
+func TestGetUserReportCoversHDAddresses(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 100*models.BC)
+	keys, _ := newTestAccountKeys(t, 1)
+
+	hdAddress, err := CreateHDWallet(alice.UserID, keys)
+	if err != nil {
+		t.Fatalf("CreateHDWallet: %v", err)
+	}
+	hdWallet := testWallet{WalletID: hdAddress.WalletID, UserID: alice.UserID, PublicKey: hdAddress.PublicKey}
+
+	if err := ProcessTransaction(bob.transfer(t, hdWallet, 20*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	if err := ProcessTransaction(alice.transfer(t, bob, 5*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+
+	user, _ := GetUserByID(alice.UserID)
+	report, err := GetUserReport(user)
+	if err != nil {
+		t.Fatalf("GetUserReport: %v", err)
+	}
+
+	if len(report.WalletIDs) != 2 {
+		t.Errorf("report covers %v, want the registration and HD wallets", report.WalletIDs)
+	}
+	if report.TotalReceived != 20*models.BC {
+		t.Errorf("received = %s, want 20 paid to the HD address", report.TotalReceived)
+	}
+	if report.TotalSent != 5*models.BC || report.TotalFees != GetMinimumFee() {
+		t.Errorf("sent %s with fees %s, want 5 with %s", report.TotalSent, report.TotalFees, GetMinimumFee())
+	}
+	if want := 115*models.BC - GetMinimumFee(); report.CurrentBalance != want {
+		t.Errorf("balance = %s, want %s", report.CurrentBalance, want)
+	}
+	if report.TransactionCount != 2 {
+		t.Errorf("transaction count = %d, want 2", report.TransactionCount)
+	}
+}
+
+func TestGetUserReportBreaksOutBatchPayments(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 0)
+	bob := newTestWallet(t, 0)
+	carol := newTestWallet(t, 100*models.BC)
+	keys, _ := newTestAccountKeys(t, 1)
+
+	hdAddress, err := CreateHDWallet(alice.UserID, keys)
+	if err != nil {
+		t.Fatalf("CreateHDWallet: %v", err)
+	}
+
+	batch, err := BuildBatchTransaction(carol.WalletID, []BatchPayment{
+		{WalletID: alice.WalletID, Amount: 7 * models.BC, Note: "invoice 1"},
+		{WalletID: hdAddress.WalletID, Amount: 3 * models.BC, Note: "invoice 2"},
+		{WalletID: bob.WalletID, Amount: 5 * models.BC, Note: "invoice 3"},
+	}, GetMinimumFee(), "payroll", carol.PublicKey)
+	if err != nil {
+		t.Fatalf("BuildBatchTransaction: %v", err)
+	}
+	carol.sign(t, batch)
+	if err := ProcessTransaction(*batch); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+
+	report := func(w testWallet) *UserReport {
+		t.Helper()
+		user, _ := GetUserByID(w.UserID)
+		report, err := GetUserReport(user)
+		if err != nil {
+			t.Fatalf("GetUserReport: %v", err)
+		}
+		return report
+	}
+
+	tests := []struct {
+		name     string
+		wallet   testWallet
+		sent     models.Amount
+		received models.Amount
+		lines    int
+	}{
+		{"receiver with two addresses", alice, 0, 10 * models.BC, 2},
+		{"single receiver", bob, 0, 5 * models.BC, 1},
+		{"sender", carol, 15 * models.BC, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := report(tt.wallet)
+			if r.TotalSent != tt.sent || r.TotalReceived != tt.received {
+				t.Errorf("sent %s and received %s, want %s and %s", r.TotalSent, r.TotalReceived, tt.sent, tt.received)
+			}
+			if r.TransactionCount != 1 || len(r.PaymentLines) != tt.lines {
+				t.Fatalf("%d transactions with lines %+v, want 1 with %d lines", r.TransactionCount, r.PaymentLines, tt.lines)
+			}
+			for _, line := range r.PaymentLines {
+				if line.ReceiverWalletID == BatchReceiverID || line.Note == "payroll" {
+					t.Errorf("line %+v is the batch itself, not a receiver's line", line)
+				}
+			}
+		})
+	}
+}
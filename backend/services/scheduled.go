@@ -0,0 +1,345 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scheduled transfer frequencies
+const (
+	FrequencyOnce    = "once"
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+// Scheduled transfer statuses
+const (
+	ScheduleActive    = "active"
+	ScheduleCompleted = "completed"
+	ScheduleCancelled = "cancelled"
+	ScheduleFailed    = "failed"
+)
+
+// MaxScheduledRunHistory is how many past runs a scheduled transfer keeps
+const MaxScheduledRunHistory = 24
+
+// Errors returned by scheduled transfer operations
+var (
+	ErrScheduledTransferNotFound = errors.New("scheduled transfer not found")
+	ErrScheduledTransferInactive = errors.New("scheduled transfer is no longer active")
+	ErrServerSigningRequired     = errors.New("scheduled transfers are signed by the server with your stored key; set serverSigning to allow it, or submit a pre-signed transaction with a lock time instead")
+)
+
+// scheduleMutex keeps a run and a cancellation of the same transfer from interleaving
+var scheduleMutex sync.Mutex
+
+// StartTransferScheduler starts the scheduler that runs due future and recurring transfers
+func StartTransferScheduler() {
+	log.Println("Transfer scheduler started")
+
+	// Check for due transfers every TRANSFER_SCHEDULER_INTERVAL seconds
+	ticker := time.NewTicker(getSchedulerInterval())
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ProcessDueTransfers(time.Now()); err != nil {
+			log.Printf("Error processing scheduled transfers: %v", err)
+			LogSystemEvent("scheduled_transfer_error", fmt.Sprintf("Failed to process scheduled transfers: %v", err), "", "")
+		}
+	}
+}
+
+// getSchedulerInterval returns how often the transfer scheduler looks for due transfers
+func getSchedulerInterval() time.Duration {
+	seconds := getEnvInt("TRANSFER_SCHEDULER_INTERVAL", 60)
+	if seconds <= 0 {
+		seconds = 60
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ProcessDueTransfers runs every active scheduled transfer due at or before now
+func ProcessDueTransfers(now time.Time) error {
+	due, err := GetDueScheduledTransfers(now)
+	if err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	successCount := 0
+	failCount := 0
+
+	for _, transfer := range due {
+		if err := runScheduledTransfer(transfer.ID, now); err != nil {
+			log.Printf("Scheduled transfer %s failed: %v", transfer.ID, err)
+			failCount++
+		} else {
+			successCount++
+		}
+	}
+
+	log.Printf("Scheduled transfers completed: %d successful, %d failed", successCount, failCount)
+
+	LogSystemEvent("scheduled_transfers",
+		fmt.Sprintf("Scheduled transfers run: %d successful, %d failed", successCount, failCount),
+		"", "")
+
+	return nil
+}
+
+// CreateScheduledTransfer schedules a payment from the user's registration wallet. A
+// transfer with frequency "once" runs at startAt; recurring transfers run at startAt and
+// then every day, week or month until endAt, if given, or until cancelled. Each run is
+// signed on the server with the user's stored key, so serverSigning must be set to show
+// the user agreed to that.
+func CreateScheduledTransfer(userID, receiverWalletID string, amount, fee models.Amount, note, frequency string, serverSigning bool, startAt time.Time, endAt *time.Time) (*models.ScheduledTransfer, error) {
+	if !serverSigning {
+		return nil, ErrServerSigningRequired
+	}
+
+	switch frequency {
+	case FrequencyOnce, FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+	default:
+		return nil, fmt.Errorf("frequency must be once, daily, weekly or monthly")
+	}
+
+	if amount < models.BC/100 {
+		return nil, fmt.Errorf("minimum transaction amount is 0.01 BC")
+	}
+	if minFee := GetMinimumFee(); fee != 0 && fee < minFee {
+		return nil, fmt.Errorf("minimum transaction fee is %s BC", minFee)
+	}
+
+	now := time.Now()
+	if startAt.IsZero() {
+		startAt = now
+	}
+	if startAt.Before(now.Add(-time.Minute)) {
+		return nil, fmt.Errorf("start time is in the past")
+	}
+	if endAt != nil {
+		if frequency == FrequencyOnce {
+			return nil, fmt.Errorf("an end time only applies to recurring transfers")
+		}
+		if !endAt.After(startAt) {
+			return nil, fmt.Errorf("end time must be after the start time")
+		}
+	}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.PrivateKey == "" {
+		return nil, fmt.Errorf("no stored key to sign scheduled transfers with")
+	}
+
+	if err := crypto.ValidateWalletIDFormat(receiverWalletID); err != nil {
+		return nil, fmt.Errorf("invalid receiver wallet ID: %v", err)
+	}
+	receiver, err := GetWalletByID(receiverWalletID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receiver wallet ID: %v", err)
+	}
+	if receiver.WalletID == user.WalletID {
+		return nil, fmt.Errorf("cannot send money to yourself")
+	}
+
+	transfer := &models.ScheduledTransfer{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		ReceiverWalletID: receiver.WalletID,
+		Amount:           amount,
+		Fee:              fee,
+		Note:             note,
+		Frequency:        frequency,
+		ServerSigning:    serverSigning,
+		StartAt:          startAt,
+		EndAt:            endAt,
+		NextRunAt:        startAt,
+		Runs:             []models.ScheduledTransferRun{},
+		Status:           ScheduleActive,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := SaveScheduledTransfer(transfer); err != nil {
+		return nil, fmt.Errorf("failed to save scheduled transfer: %v", err)
+	}
+
+	LogSystemEvent("scheduled_transfer", fmt.Sprintf("Scheduled %s transfer of %s to %s from %s",
+		frequency, amount, receiver.WalletID, startAt.UTC().Format(time.RFC3339)), userID, "")
+
+	return transfer, nil
+}
+
+// GetOwnedScheduledTransfer returns a scheduled transfer if it belongs to the user
+func GetOwnedScheduledTransfer(userID, id string) (*models.ScheduledTransfer, error) {
+	transfer, err := GetScheduledTransfer(id)
+	if err != nil || transfer.UserID != userID {
+		return nil, ErrScheduledTransferNotFound
+	}
+	return transfer, nil
+}
+
+// CancelScheduledTransfer stops a scheduled transfer before its next run
+func CancelScheduledTransfer(userID, id string) (*models.ScheduledTransfer, error) {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+
+	transfer, err := GetOwnedScheduledTransfer(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != ScheduleActive {
+		return nil, ErrScheduledTransferInactive
+	}
+
+	transfer.Status = ScheduleCancelled
+	transfer.UpdatedAt = time.Now()
+	if err := SaveScheduledTransfer(transfer); err != nil {
+		return nil, fmt.Errorf("failed to save scheduled transfer: %v", err)
+	}
+
+	LogSystemEvent("scheduled_transfer", fmt.Sprintf("Cancelled scheduled transfer %s", transfer.ID), userID, "")
+
+	return transfer, nil
+}
+
+// runScheduledTransfer submits one due run of a scheduled transfer, records the outcome
+// and moves it to its next occurrence. Occurrences missed while the server was down are
+// skipped rather than paid all at once. A failed recurring run is retried at the next
+// occurrence; a failed one-off transfer is marked failed. The occurrence is claimed and
+// saved before it is paid, so a transfer whose outcome cannot be saved is left with a
+// "running" run rather than paid again on the next tick.
+func runScheduledTransfer(id string, now time.Time) error {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+
+	// Reload so a transfer cancelled since it was listed does not run
+	transfer, err := GetScheduledTransfer(id)
+	if err != nil {
+		return err
+	}
+	if transfer.Status != ScheduleActive || transfer.NextRunAt.After(now) {
+		return nil
+	}
+
+	transfer.RunCount++
+	transfer.Runs = append(transfer.Runs, models.ScheduledTransferRun{DueAt: transfer.NextRunAt, RanAt: time.Now(), Status: "running"})
+	if len(transfer.Runs) > MaxScheduledRunHistory {
+		transfer.Runs = transfer.Runs[len(transfer.Runs)-MaxScheduledRunHistory:]
+	}
+
+	if transfer.Frequency == FrequencyOnce {
+		transfer.Status = ScheduleCompleted
+	} else {
+		next := transfer.NextRunAt
+		for n := 1; !next.After(now); n++ {
+			next = nextOccurrence(transfer.StartAt, transfer.Frequency, n)
+		}
+		transfer.NextRunAt = next
+		if transfer.EndAt != nil && next.After(*transfer.EndAt) {
+			transfer.Status = ScheduleCompleted
+		}
+	}
+	transfer.UpdatedAt = time.Now()
+
+	if err := SaveScheduledTransfer(transfer); err != nil {
+		return fmt.Errorf("failed to save scheduled transfer: %v", err)
+	}
+
+	hash, runErr := executeScheduledTransfer(transfer)
+	run := &transfer.Runs[len(transfer.Runs)-1]
+	if runErr != nil {
+		run.Status = "failed"
+		run.Error = runErr.Error()
+		if transfer.Frequency == FrequencyOnce {
+			transfer.Status = ScheduleFailed
+		}
+	} else {
+		run.Status = "submitted"
+		run.TransactionHash = hash
+	}
+	transfer.UpdatedAt = time.Now()
+
+	if err := SaveScheduledTransfer(transfer); err != nil {
+		log.Printf("Scheduled transfer %s ran but its outcome was not saved: %v", transfer.ID, err)
+		LogSystemEvent("scheduled_transfer_error", fmt.Sprintf("Scheduled transfer %s ran (transaction %q, error %v) but its outcome was not saved: %v",
+			transfer.ID, hash, runErr, err), transfer.UserID, "")
+		return fmt.Errorf("failed to save scheduled transfer run: %v", err)
+	}
+
+	if runErr != nil {
+		LogSystemEvent("scheduled_transfer_failure", fmt.Sprintf("Scheduled transfer %s failed: %v", transfer.ID, runErr), transfer.UserID, "")
+		return runErr
+	}
+	LogSystemEvent("scheduled_transfer_success", fmt.Sprintf("Scheduled transfer %s submitted as %s", transfer.ID, hash), transfer.UserID, "")
+	return nil
+}
+
+// executeScheduledTransfer signs a run of a scheduled transfer with the user's stored key
+// and submits it to the pending pool, returning the transaction hash. Transfers saved
+// without the user's consent to server signing are not signed.
+func executeScheduledTransfer(transfer *models.ScheduledTransfer) (string, error) {
+	if !transfer.ServerSigning {
+		return "", ErrServerSigningRequired
+	}
+
+	user, err := GetUserByID(transfer.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	privateKey, err := crypto.DecryptPrivateKey(user.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt private key: %v", err)
+	}
+
+	fee := transfer.Fee
+	if fee == 0 {
+		fee = GetMinimumFee()
+	}
+	note := transfer.Note
+	if note == "" {
+		note = "Scheduled transfer"
+	}
+
+	tx, err := CreateTransaction(user.WalletID, transfer.ReceiverWalletID, transfer.Amount, fee, note, user.PublicKey, privateKey)
+	if err != nil {
+		return "", err
+	}
+	if err := ProcessTransaction(*tx); err != nil {
+		return "", err
+	}
+	return tx.Hash, nil
+}
+
+// nextOccurrence returns the nth occurrence after start. Monthly transfers keep the day
+// of the month, falling back to the last day in shorter months.
+func nextOccurrence(start time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case FrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		year, month, day := start.Date()
+		lastDay := time.Date(year, month+time.Month(n)+1, 0, 0, 0, 0, 0, start.Location()).Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		hour, minute, sec := start.Clock()
+		return time.Date(year, month+time.Month(n), day, hour, minute, sec, start.Nanosecond(), start.Location())
+	}
+	return start
+}
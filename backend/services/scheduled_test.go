@@ -0,0 +1,165 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingScheduleSaveStore fails every scheduled transfer save after the first allowed
+type failingScheduleSaveStore struct {
+	*MemoryStore
+	allowed int
+}
+
+func (s *failingScheduleSaveStore) SaveScheduledTransfer(transfer *models.ScheduledTransfer) error {
+	if s.allowed == 0 {
+		return errors.New("store unavailable")
+	}
+	s.allowed--
+	return s.MemoryStore.SaveScheduledTransfer(transfer)
+}
+
+// storeTestKey encrypts the wallet's private key onto its user, as registration does
+func storeTestKey(t *testing.T, w testWallet) {
+	t.Helper()
+
+	t.Setenv("AES_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	encrypted, err := crypto.EncryptPrivateKey(w.PrivateKey)
+	if err != nil {
+		t.Fatalf("EncryptPrivateKey: %v", err)
+	}
+	user, err := GetUserByID(w.UserID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	user.PrivateKey = encrypted
+	if err := SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+}
+
+func TestCreateScheduledTransferRequiresServerSigning(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	storeTestKey(t, alice)
+
+	_, err := CreateScheduledTransfer(alice.UserID, bob.WalletID, 10*models.BC, 0, "", FrequencyOnce, false, time.Now(), nil)
+	if !errors.Is(err, ErrServerSigningRequired) {
+		t.Fatalf("err = %v, want ErrServerSigningRequired", err)
+	}
+
+	transfer, err := CreateScheduledTransfer(alice.UserID, bob.WalletID, 10*models.BC, 0, "", FrequencyOnce, true, time.Now(), nil)
+	if err != nil {
+		t.Fatalf("CreateScheduledTransfer: %v", err)
+	}
+	if !transfer.ServerSigning {
+		t.Error("ServerSigning not recorded on the transfer")
+	}
+}
+
+func TestRunScheduledTransferWithoutConsentDoesNotSign(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	storeTestKey(t, alice)
+
+	// A schedule saved before consent was recorded
+	now := time.Now()
+	transfer := &models.ScheduledTransfer{
+		ID:               "legacy-schedule",
+		UserID:           alice.UserID,
+		ReceiverWalletID: bob.WalletID,
+		Amount:           10 * models.BC,
+		Frequency:        FrequencyOnce,
+		StartAt:          now,
+		NextRunAt:        now,
+		Status:           ScheduleActive,
+	}
+	if err := SaveScheduledTransfer(transfer); err != nil {
+		t.Fatalf("SaveScheduledTransfer: %v", err)
+	}
+
+	if err := runScheduledTransfer(transfer.ID, now); !errors.Is(err, ErrServerSigningRequired) {
+		t.Fatalf("err = %v, want ErrServerSigningRequired", err)
+	}
+	if pending := GetPendingTransactionsFromMemory(); len(pending) != 0 {
+		t.Errorf("%d transactions submitted without consent", len(pending))
+	}
+	saved, err := GetScheduledTransfer(transfer.ID)
+	if err != nil {
+		t.Fatalf("GetScheduledTransfer: %v", err)
+	}
+	if saved.Status != ScheduleFailed || saved.Runs[0].Status != "failed" {
+		t.Errorf("status %s, run %s; want failed, failed", saved.Status, saved.Runs[0].Status)
+	}
+}
+
+func TestRunScheduledTransferPaysOccurrenceOnceWhenOutcomeNotSaved(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	storeTestKey(t, alice)
+
+	start := time.Now()
+	transfer, err := CreateScheduledTransfer(alice.UserID, bob.WalletID, 10*models.BC, 0, "", FrequencyDaily, true, start, nil)
+	if err != nil {
+		t.Fatalf("CreateScheduledTransfer: %v", err)
+	}
+
+	// Let the run claim its occurrence, then fail saving the outcome
+	SetStore(&failingScheduleSaveStore{MemoryStore: memory, allowed: 1})
+	if err := runScheduledTransfer(transfer.ID, start); err == nil {
+		t.Fatal("run succeeded although its outcome was not saved")
+	}
+	if err := runScheduledTransfer(transfer.ID, start); err != nil {
+		t.Fatalf("second tick: %v", err)
+	}
+	SetStore(memory)
+
+	if pending := GetPendingTransactionsFromMemory(); len(pending) != 1 {
+		t.Fatalf("occurrence paid %d times, want once", len(pending))
+	}
+	saved, err := GetScheduledTransfer(transfer.ID)
+	if err != nil {
+		t.Fatalf("GetScheduledTransfer: %v", err)
+	}
+	if saved.RunCount != 1 || saved.Runs[0].Status != "running" {
+		t.Errorf("run count %d, run status %s; want 1, running", saved.RunCount, saved.Runs[0].Status)
+	}
+	if !saved.NextRunAt.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("next run %v, want %v", saved.NextRunAt, start.AddDate(0, 0, 1))
+	}
+}
+
+func TestRunScheduledTransferRecordsSubmittedRun(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	storeTestKey(t, alice)
+
+	start := time.Now()
+	transfer, err := CreateScheduledTransfer(alice.UserID, bob.WalletID, 10*models.BC, 0, "", FrequencyOnce, true, start, nil)
+	if err != nil {
+		t.Fatalf("CreateScheduledTransfer: %v", err)
+	}
+	if err := ProcessDueTransfers(start); err != nil {
+		t.Fatalf("ProcessDueTransfers: %v", err)
+	}
+
+	saved, err := GetScheduledTransfer(transfer.ID)
+	if err != nil {
+		t.Fatalf("GetScheduledTransfer: %v", err)
+	}
+	if saved.Status != ScheduleCompleted || len(saved.Runs) != 1 || saved.Runs[0].Status != "submitted" {
+		t.Fatalf("status %s, runs %+v; want completed with one submitted run", saved.Status, saved.Runs)
+	}
+	mustMine(t, GetMinerWallet())
+	if got := mustBalance(t, bob.WalletID); got != 10*models.BC {
+		t.Errorf("receiver balance %s, want 10", got)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"backend/script"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Outputs are locked by scripts in the language of package script. An output without a
+// locking script is locked to the key of the wallet holding it, as if by the standard
+// pay-to-pubkey-hash script for its address, and an input without an unlocking script
+// is unlocked by the transaction's own signature and public key. Plain transfers
+// therefore carry no scripts, yet every input of a signed transaction is spent by
+// evaluating one.
+
+// ErrScriptFailed is returned when an input does not meet the locking script of the
+// output it spends
+var ErrScriptFailed = errors.New("input does not meet its locking script")
+
+// validateScripts checks the form of a transaction's scripts: output scripts only from
+// version 4 and never on escrow outputs or in unsigned system transactions, at most one
+// unlocking script per input, and every script hex that parses within the size limits
+func validateScripts(tx models.Transaction) error {
+	if len(tx.UnlockingScripts) > 0 {
+		if len(tx.UnlockingScripts) != len(tx.InputUTXOs) {
+			return fmt.Errorf("%d unlocking scripts for %d inputs", len(tx.UnlockingScripts), len(tx.InputUTXOs))
+		}
+		if !requiresSignature(tx) || tx.Version < TransactionVersionCanonical {
+			return fmt.Errorf("only signed canonical transactions carry unlocking scripts")
+		}
+	}
+	for idx, unlocking := range tx.UnlockingScripts {
+		if unlocking == "" {
+			continue
+		}
+		if _, err := decodeScript(unlocking); err != nil {
+			return fmt.Errorf("unlocking script %d: %w", idx, err)
+		}
+	}
+
+	for idx, output := range tx.OutputUTXOs {
+		if output.Script == "" {
+			continue
+		}
+		switch {
+		case tx.Version < TransactionVersionScript:
+			return fmt.Errorf("only version %d transactions carry locking scripts", TransactionVersionScript)
+		case !requiresSignature(tx):
+			return fmt.Errorf("%s transactions cannot lock outputs with scripts", tx.Type)
+		case output.Lock != nil || output.WalletID == EscrowWalletID:
+			return fmt.Errorf("escrow output %d cannot also carry a locking script", idx)
+		}
+		if _, err := decodeScript(output.Script); err != nil {
+			return fmt.Errorf("locking script %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// checkInputUnlocked checks that input idx of a transaction may spend an output held by
+// walletID under lockingScript. Signed transactions must meet the script. Unsigned system
+// transactions and legacy transactions, whose signatures do not cover the hash, may only
+// spend script-less outputs of their sender.
+func checkInputUnlocked(tx models.Transaction, idx int, walletID, lockingScript string, checker *transactionChecker) error {
+	utxoID := tx.InputUTXOs[idx]
+
+	if !requiresSignature(tx) || tx.Version < TransactionVersionCanonical {
+		if lockingScript != "" {
+			return fmt.Errorf("%w: %s is locked by a script", ErrInputNotOwned, utxoID)
+		}
+		if walletID != tx.SenderWalletID {
+			return fmt.Errorf("%w: %s is locked to %s", ErrInputNotOwned, utxoID, walletID)
+		}
+		return nil
+	}
+
+	locking, err := lockingScriptFor(walletID, lockingScript)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrScriptFailed, utxoID, err)
+	}
+	unlocking, err := unlockingScriptFor(tx, idx)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrScriptFailed, utxoID, err)
+	}
+
+	if err := script.Execute(unlocking, locking, checker); err != nil {
+		if lockingScript == "" {
+			return fmt.Errorf("%w: %s is locked to the key of %s: %w", ErrScriptFailed, utxoID, walletID, err)
+		}
+		return fmt.Errorf("%w: %s: %w", ErrScriptFailed, utxoID, err)
+	}
+	return nil
+}
+
+// lockingScriptFor returns an output's locking script, or the pay-to-pubkey-hash script
+// of the wallet holding it if it has none
+func lockingScriptFor(walletID, lockingScript string) ([]byte, error) {
+	if lockingScript != "" {
+		return decodeScript(lockingScript)
+	}
+
+	hash, err := crypto.WalletIDKeyHash(walletID)
+	if err != nil {
+		return nil, fmt.Errorf("wallet %s has no key to lock to", walletID)
+	}
+	return script.PayToPubKeyHash(hash)
+}
+
+// unlockingScriptFor returns the unlocking script of input idx, or one pushing the
+// transaction's signature and sender public key if it has none
+func unlockingScriptFor(tx models.Transaction, idx int) ([]byte, error) {
+	if idx < len(tx.UnlockingScripts) && tx.UnlockingScripts[idx] != "" {
+		return decodeScript(tx.UnlockingScripts[idx])
+	}
+
+	// A multisig sender has no single signature; its policy key is checked against the
+	// co-signer signatures instead
+	signature, _ := base64.StdEncoding.DecodeString(tx.Signature)
+	return script.SignatureUnlock(signature, []byte(tx.SenderPublicKey))
+}
+
+// decodeScript decodes a hex script and checks that it parses
+func decodeScript(encoded string) ([]byte, error) {
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("script must be hex")
+	}
+	if _, err := script.Parse(decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// transactionChecker evaluates the signature and lock time checks of scripts against
+// one transaction, remembering signatures it has already verified
+type transactionChecker struct {
+	tx       models.Transaction
+	verified map[string]bool
+}
+
+// newTransactionChecker returns the script checker for a transaction
+func newTransactionChecker(tx models.Transaction) *transactionChecker {
+	return &transactionChecker{tx: tx, verified: make(map[string]bool)}
+}
+
+// CheckSignature verifies a signature over the transaction hash. A multisig policy key
+// is satisfied by the co-signer signatures on the transaction rather than by signature.
+func (c *transactionChecker) CheckSignature(signature, publicKey []byte) bool {
+	cacheKey := string(signature) + "\x00" + string(publicKey)
+	if valid, ok := c.verified[cacheKey]; ok {
+		return valid
+	}
+
+	valid := false
+	if algorithm, err := crypto.DetectKeyAlgorithm(string(publicKey)); err == nil {
+		if algorithm == crypto.AlgorithmMultisig {
+			cosigned := c.tx
+			cosigned.SenderPublicKey = string(publicKey)
+			valid = verifyMultisigSignatures(cosigned) == nil
+		} else {
+			valid = crypto.VerifyHashSignature(c.tx.Hash, base64.StdEncoding.EncodeToString(signature), string(publicKey)) == nil
+		}
+	}
+
+	c.verified[cacheKey] = valid
+	return valid
+}
+
+// CheckLockTime reports whether the transaction's lock time is at least lockTime and of
+// the same kind, block height or Unix time. The transaction's lock time itself is
+// enforced by checkLockTime.
+func (c *transactionChecker) CheckLockTime(lockTime int64) bool {
+	if c.tx.Version < TransactionVersionLockTime {
+		return false
+	}
+	if (lockTime < LockTimeThreshold) != (c.tx.LockTime < LockTimeThreshold) {
+		return false
+	}
+	return lockTime <= c.tx.LockTime
+}
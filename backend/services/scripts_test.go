@@ -0,0 +1,142 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"backend/script"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// TestSpendScriptLockedUTXO pays Bob an output that needs both a secret and his signature,
+// then spends it with and without a satisfying unlocking script
+func TestSpendScriptLockedUTXO(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	carol := newTestWallet(t, 0)
+
+	secret := []byte("open sesame")
+	secretHash := sha256.Sum256(secret)
+	bobKeyHash, err := crypto.WalletIDKeyHash(bob.WalletID)
+	if err != nil {
+		t.Fatalf("WalletIDKeyHash: %v", err)
+	}
+	locking, err := script.Assemble("OP_SHA256 0x" + hex.EncodeToString(secretHash[:]) + " OP_EQUALVERIFY" +
+		" OP_DUP OP_SHA256 0x" + hex.EncodeToString(bobKeyHash) + " OP_EQUALVERIFY OP_CHECKSIG")
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+
+	payment, err := BuildTransactionWithOptions(alice.WalletID, bob.WalletID, 10*models.BC, GetMinimumFee(), "", alice.PublicKey, TransferOptions{LockingScript: locking})
+	if err != nil {
+		t.Fatalf("BuildTransactionWithOptions: %v", err)
+	}
+	alice.sign(t, payment)
+	if err := ProcessTransaction(*payment); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+
+	utxos, _ := memory.GetUnspentUTXOs(bob.WalletID)
+	if len(utxos) != 1 || utxos[0].Script != hex.EncodeToString(locking) {
+		t.Fatalf("Bob holds %+v, want one script-locked UTXO", utxos)
+	}
+	locked := utxos[0]
+
+	spend, err := BuildTransactionWithOptions(bob.WalletID, carol.WalletID, 5*models.BC, GetMinimumFee(), "", bob.PublicKey, TransferOptions{Inputs: []string{locked.ID}})
+	if err != nil {
+		t.Fatalf("BuildTransactionWithOptions: %v", err)
+	}
+	bob.sign(t, spend)
+	signature, _ := base64.StdEncoding.DecodeString(spend.Signature)
+
+	unlockWith := func(secret []byte) []string {
+		unlocking, err := script.NewBuilder().AddData(signature).AddData([]byte(bob.PublicKey)).AddData(secret).Script()
+		if err != nil {
+			t.Fatalf("building unlocking script: %v", err)
+		}
+		return []string{hex.EncodeToString(unlocking)}
+	}
+
+	tests := []struct {
+		name      string
+		unlocking []string
+		wantErr   error
+	}{
+		{"secret and signature", unlockWith(secret), nil},
+		{"wrong secret", unlockWith([]byte("open barley")), ErrScriptFailed},
+		{"signature only", nil, ErrScriptFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := *spend
+			tx.UnlockingScripts = tt.unlocking
+			err := ValidateTransaction(tx)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("ValidateTransaction: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateTransaction: err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	spend.UnlockingScripts = unlockWith(secret)
+	if err := ProcessTransaction(*spend); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+	if got := mustBalance(t, carol.WalletID); got != 5*models.BC {
+		t.Errorf("receiver balance = %s, want 5", got)
+	}
+	if report := ValidateChainDeep(); !report.Valid {
+		t.Errorf("chain invalid after script spend: %v", violationTypes(report))
+	}
+}
+
+func TestCheckInputUnlocked(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	tx := alice.transfer(t, bob, 10*models.BC)
+	unsigned := tx
+	unsigned.Signature = ""
+	hashLocked := hex.EncodeToString([]byte{byte(script.OpSHA256), 0x01, 0xaa, byte(script.OpEqual)})
+
+	tests := []struct {
+		name          string
+		tx            models.Transaction
+		walletID      string
+		lockingScript string
+		wantErr       error
+	}{
+		{"sender's own output", tx, alice.WalletID, "", nil},
+		{"output of another wallet", tx, bob.WalletID, "", ErrScriptFailed},
+		{"missing signature", unsigned, alice.WalletID, "", ErrScriptFailed},
+		{"unmet locking script", tx, alice.WalletID, hashLocked, ErrScriptFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInputUnlocked(tt.tx, 0, tt.walletID, tt.lockingScript, newTransactionChecker(tt.tx))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("checkInputUnlocked: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkInputUnlocked: err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
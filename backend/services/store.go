@@ -0,0 +1,438 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUTXOAlreadySpent is returned when a transaction tries to spend an input
+// that is missing or has already been spent by another transaction
+var ErrUTXOAlreadySpent = errors.New("UTXO already spent")
+
+// Store abstracts the persistence layer used by the services package
+type Store interface {
+	// Users
+	SaveUser(user *models.User) error
+	GetUserByID(userID string) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	GetAllUsers() ([]models.User, error)
+	// GetUsersAfter pages through users in ID order: up to limit users with IDs after afterID
+	GetUsersAfter(afterID string, limit int) ([]models.User, error)
+
+	// Wallets
+	SaveWallet(wallet *models.Wallet) error
+	GetWalletByID(walletID string) (*models.Wallet, error)
+	GetAllWallets() ([]models.Wallet, error)
+	GetUserWallets(userID string) ([]models.Wallet, error)
+	// GetMultisigWallets matches publicKey exactly against the canonical keys policies store
+	GetMultisigWallets(publicKey string) ([]models.Wallet, error)
+
+	// UTXOs
+	SaveUTXO(utxo *models.UTXO) error
+	GetUTXOByID(utxoID string) (*models.UTXO, error)
+	GetUnspentUTXOs(walletID string) ([]models.UTXO, error)
+	GetAllUTXOs() ([]models.UTXO, error)
+
+	// ReplaceUTXOs atomically replaces every stored UTXO with utxos. If it fails the
+	// previous set is left in place.
+	ReplaceUTXOs(utxos []models.UTXO) error
+
+	// Transactions
+	SaveTransaction(tx *models.Transaction) error
+	GetTransaction(hash string) (*models.Transaction, error)
+	GetWalletTransactions(walletID string) ([]models.Transaction, error)
+
+	// Pending pool
+	SavePendingTransaction(tx models.Transaction) error
+	GetPendingTransactions() ([]models.Transaction, error)
+	ClearPendingTransactions() error
+	RemovePendingTransaction(txHash string) error
+
+	// CommitTransaction atomically spends the transaction's inputs, saves its
+	// output UTXOs, stores the transaction and adds it to the pending pool.
+	// It returns ErrUTXOAlreadySpent (wrapped) if any input is missing or spent.
+	CommitTransaction(tx models.Transaction, outputs []models.UTXO) error
+
+	// Blocks
+	SaveBlock(block models.Block) error
+	// CommitBlock atomically saves a mined block and its reward UTXOs, stores its
+	// transactions as confirmed in it and removes them from the pending pool
+	CommitBlock(block models.Block, rewards []models.UTXO) error
+	GetAllBlocks() ([]models.Block, error)
+	GetBlockByHash(hash string) (*models.Block, error)
+	GetBlockByIndex(index int64) (*models.Block, error)
+
+	// Zakat
+	SaveZakatDeduction(deduction *models.ZakatDeduction) error
+	GetUserZakatDeductions(userID string) ([]models.ZakatDeduction, error)
+	GetAllZakatDeductions() ([]models.ZakatDeduction, error)
+
+	// Multisig proposals
+	SaveMultisigProposal(proposal *models.MultisigProposal) error
+	GetMultisigProposal(id string) (*models.MultisigProposal, error)
+	GetWalletMultisigProposals(walletID string) ([]models.MultisigProposal, error)
+
+	// Scheduled transfers
+	SaveScheduledTransfer(transfer *models.ScheduledTransfer) error
+	GetScheduledTransfer(id string) (*models.ScheduledTransfer, error)
+	GetUserScheduledTransfers(userID string) ([]models.ScheduledTransfer, error)
+	GetDueScheduledTransfers(now time.Time) ([]models.ScheduledTransfer, error)
+
+	// Logs
+	SaveSystemLog(log *models.SystemLog) error
+	GetSystemLogs(logType string, limit int) ([]models.SystemLog, error)
+	SaveTransactionLog(log *models.TransactionLog) error
+	GetUserTransactionLogs(userID string, limit int) ([]models.TransactionLog, error)
+
+	// Migrations
+	MigrateAmounts() (map[string]int, error)
+}
+
+// Storage backend names accepted by InitStore
+const (
+	StorageMongo  = "mongo"
+	StorageMemory = "memory"
+)
+
+var store Store = NewMongoStore()
+
+// InitStore selects the storage backend by name ("mongo" or "memory")
+func InitStore(backend string) error {
+	switch backend {
+	case "", StorageMongo:
+		SetStore(NewMongoStore())
+	case StorageMemory:
+		SetStore(NewMemoryStore())
+	default:
+		return fmt.Errorf("unknown storage backend: %s", backend)
+	}
+	return nil
+}
+
+// SetStore replaces the active storage backend
+func SetStore(s Store) {
+	store = s
+}
+
+// GetStore returns the active storage backend
+func GetStore() Store {
+	return store
+}
+
+// User operations
+
+// SaveUser saves a user
+func SaveUser(user *models.User) error {
+	return store.SaveUser(user)
+}
+
+// GetUserByID retrieves a user by ID
+func GetUserByID(userID string) (*models.User, error) {
+	return store.GetUserByID(userID)
+}
+
+// GetUserByEmail retrieves a user by email
+func GetUserByEmail(email string) (*models.User, error) {
+	return store.GetUserByEmail(email)
+}
+
+// UpdateUser updates a user
+func UpdateUser(user *models.User) error {
+	user.UpdatedAt = time.Now()
+	return SaveUser(user)
+}
+
+// GetAllUsers retrieves all users
+func GetAllUsers() ([]models.User, error) {
+	return store.GetAllUsers()
+}
+
+// GetUsersAfter retrieves up to limit users with IDs after afterID, in ID order
+func GetUsersAfter(afterID string, limit int) ([]models.User, error) {
+	return store.GetUsersAfter(afterID, limit)
+}
+
+// Wallet operations
+
+// SaveWallet saves a wallet
+func SaveWallet(wallet *models.Wallet) error {
+	return store.SaveWallet(wallet)
+}
+
+// GetWalletByID retrieves a wallet by ID. A legacy hex wallet ID and its address are
+// aliases and find the same wallet, and addresses may be given in either case.
+func GetWalletByID(walletID string) (*models.Wallet, error) {
+	wallet, err := store.GetWalletByID(walletID)
+	if err == nil {
+		return wallet, nil
+	}
+
+	for _, alias := range walletIDAliases(walletID) {
+		if aliased, aliasErr := store.GetWalletByID(alias); aliasErr == nil {
+			return aliased, nil
+		}
+	}
+	return nil, err
+}
+
+// walletIDAliases lists the other IDs a wallet may be stored under: the lower-case form
+// of an address and the address or legacy hex form of the same key
+func walletIDAliases(walletID string) []string {
+	var aliases []string
+	if lower := strings.ToLower(walletID); lower != walletID && crypto.ValidateWalletIDFormat(lower) == nil {
+		aliases = append(aliases, lower)
+	}
+	if alias := crypto.WalletIDAlias(walletID); alias != "" {
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+// WalletAddress returns the checksummed address of a wallet ID. Legacy hex IDs are
+// converted; addresses and system wallet IDs are returned unchanged.
+func WalletAddress(walletID string) string {
+	if crypto.IsLegacyWalletID(walletID) {
+		if address := crypto.WalletIDAlias(walletID); address != "" {
+			return address
+		}
+	}
+	return walletID
+}
+
+// GetAllWallets retrieves all wallets
+func GetAllWallets() ([]models.Wallet, error) {
+	return store.GetAllWallets()
+}
+
+// GetUserWallets retrieves every wallet a user owns: the wallet created at registration
+// and any HD addresses
+func GetUserWallets(userID string) ([]models.Wallet, error) {
+	return store.GetUserWallets(userID)
+}
+
+// GetMultisigWallets retrieves the multisig wallets a public key co-signs. The key is
+// canonicalized first, so PEM whitespace or line wrapping does not hide a policy.
+func GetMultisigWallets(publicKey string) ([]models.Wallet, error) {
+	canonical, err := crypto.CanonicalPublicKeyPEM(publicKey)
+	if err != nil {
+		// Only single public keys can be in a policy
+		return nil, nil
+	}
+	return store.GetMultisigWallets(canonical)
+}
+
+// UpdateWallet updates a wallet
+func UpdateWallet(wallet *models.Wallet) error {
+	wallet.UpdatedAt = time.Now()
+	return SaveWallet(wallet)
+}
+
+// UTXO operations
+
+// SaveUTXO saves a UTXO
+func SaveUTXO(utxo *models.UTXO) error {
+	return store.SaveUTXO(utxo)
+}
+
+// GetUTXOByID retrieves a UTXO by ID
+func GetUTXOByID(utxoID string) (*models.UTXO, error) {
+	return store.GetUTXOByID(utxoID)
+}
+
+// GetUnspentUTXOs retrieves all unspent UTXOs for a wallet
+func GetUnspentUTXOs(walletID string) ([]models.UTXO, error) {
+	return store.GetUnspentUTXOs(walletID)
+}
+
+// GetAllUTXOs retrieves all UTXOs
+func GetAllUTXOs() ([]models.UTXO, error) {
+	return store.GetAllUTXOs()
+}
+
+// ReplaceUTXOs atomically replaces the whole UTXO set
+func ReplaceUTXOs(utxos []models.UTXO) error {
+	return store.ReplaceUTXOs(utxos)
+}
+
+// UpdateUTXO updates a UTXO
+func UpdateUTXO(utxo *models.UTXO) error {
+	return SaveUTXO(utxo)
+}
+
+// Transaction operations
+
+// SaveTransaction saves a transaction
+func SaveTransaction(tx *models.Transaction) error {
+	return store.SaveTransaction(tx)
+}
+
+// GetTransactionFromDB retrieves a transaction by hash
+func GetTransactionFromDB(hash string) (*models.Transaction, error) {
+	return store.GetTransaction(hash)
+}
+
+// UpdateTransaction updates a transaction
+func UpdateTransaction(tx models.Transaction) error {
+	return SaveTransaction(&tx)
+}
+
+// GetWalletTransactions retrieves all transactions for a wallet
+func GetWalletTransactions(walletID string) ([]models.Transaction, error) {
+	return store.GetWalletTransactions(walletID)
+}
+
+// Pending Transaction operations
+
+// SavePendingTransaction saves a pending transaction
+func SavePendingTransaction(tx models.Transaction) error {
+	return store.SavePendingTransaction(tx)
+}
+
+// GetPendingTransactions retrieves all pending transactions
+func GetPendingTransactions() ([]models.Transaction, error) {
+	return store.GetPendingTransactions()
+}
+
+// ClearPendingTransactions removes all pending transactions
+func ClearPendingTransactions() error {
+	return store.ClearPendingTransactions()
+}
+
+// RemovePendingTransaction removes a specific pending transaction
+func RemovePendingTransaction(txHash string) error {
+	return store.RemovePendingTransaction(txHash)
+}
+
+// CommitTransaction atomically applies a transaction's UTXO changes and queues it
+func CommitTransaction(tx models.Transaction, outputs []models.UTXO) error {
+	return store.CommitTransaction(tx, outputs)
+}
+
+// Block operations
+
+// SaveBlock saves a block
+func SaveBlock(block models.Block) error {
+	return store.SaveBlock(block)
+}
+
+// CommitBlock atomically saves a mined block, its rewards and its confirmed transactions
+func CommitBlock(block models.Block, rewards []models.UTXO) error {
+	return store.CommitBlock(block, rewards)
+}
+
+// GetAllBlocks retrieves all blocks ordered by index
+func GetAllBlocks() ([]models.Block, error) {
+	return store.GetAllBlocks()
+}
+
+// GetBlockByHash retrieves a block by hash
+func GetBlockByHash(hash string) (*models.Block, error) {
+	return store.GetBlockByHash(hash)
+}
+
+// GetBlockByIndex retrieves a block by index
+func GetBlockByIndex(index int64) (*models.Block, error) {
+	return store.GetBlockByIndex(index)
+}
+
+// Zakat operations
+
+// SaveZakatDeduction saves a zakat deduction
+func SaveZakatDeduction(deduction *models.ZakatDeduction) error {
+	return store.SaveZakatDeduction(deduction)
+}
+
+// GetUserZakatDeductions retrieves all zakat deductions for a user
+func GetUserZakatDeductions(userID string) ([]models.ZakatDeduction, error) {
+	return store.GetUserZakatDeductions(userID)
+}
+
+// GetAllZakatDeductions retrieves all zakat deductions
+func GetAllZakatDeductions() ([]models.ZakatDeduction, error) {
+	return store.GetAllZakatDeductions()
+}
+
+// Multisig proposal operations
+
+// SaveMultisigProposal saves a multisig spend proposal
+func SaveMultisigProposal(proposal *models.MultisigProposal) error {
+	return store.SaveMultisigProposal(proposal)
+}
+
+// GetMultisigProposal retrieves a multisig spend proposal by ID
+func GetMultisigProposal(id string) (*models.MultisigProposal, error) {
+	return store.GetMultisigProposal(id)
+}
+
+// GetWalletMultisigProposals retrieves a multisig wallet's proposals, newest first
+func GetWalletMultisigProposals(walletID string) ([]models.MultisigProposal, error) {
+	return store.GetWalletMultisigProposals(walletID)
+}
+
+// Scheduled transfer operations
+
+// SaveScheduledTransfer saves a scheduled transfer
+func SaveScheduledTransfer(transfer *models.ScheduledTransfer) error {
+	return store.SaveScheduledTransfer(transfer)
+}
+
+// GetScheduledTransfer retrieves a scheduled transfer by ID
+func GetScheduledTransfer(id string) (*models.ScheduledTransfer, error) {
+	return store.GetScheduledTransfer(id)
+}
+
+// GetUserScheduledTransfers retrieves a user's scheduled transfers, newest first
+func GetUserScheduledTransfers(userID string) ([]models.ScheduledTransfer, error) {
+	return store.GetUserScheduledTransfers(userID)
+}
+
+// GetDueScheduledTransfers retrieves active scheduled transfers due at or before now,
+// earliest first
+func GetDueScheduledTransfers(now time.Time) ([]models.ScheduledTransfer, error) {
+	return store.GetDueScheduledTransfers(now)
+}
+
+// Logging operations
+
+// SaveSystemLog saves a system log
+func SaveSystemLog(log *models.SystemLog) error {
+	return store.SaveSystemLog(log)
+}
+
+// GetSystemLogs retrieves system logs with optional filters
+func GetSystemLogs(logType string, limit int) ([]models.SystemLog, error) {
+	return store.GetSystemLogs(logType, limit)
+}
+
+// SaveTransactionLog saves a transaction log
+func SaveTransactionLog(log *models.TransactionLog) error {
+	return store.SaveTransactionLog(log)
+}
+
+// GetUserTransactionLogs retrieves transaction logs for a user
+func GetUserTransactionLogs(userID string, limit int) ([]models.TransactionLog, error) {
+	return store.GetUserTransactionLogs(userID, limit)
+}
+
+// Migrations
+
+// MigrateAmounts converts money fields stored as BC floats to integer base units and
+// returns the number of documents rewritten per collection
+func MigrateAmounts() (map[string]int, error) {
+	migrated, err := store.MigrateAmounts()
+	if err != nil {
+		return migrated, err
+	}
+
+	total := 0
+	for _, count := range migrated {
+		total += count
+	}
+	LogSystemEvent("migration", fmt.Sprintf("Migrated %d documents to integer amounts", total), "", "")
+
+	return migrated, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testWallet is a stored wallet together with its private key
+type testWallet struct {
+	WalletID   string
+	UserID     string
+	PublicKey  string
+	PrivateKey string
+}
+
+// useTestStore switches the services to a fresh in-memory store holding only the genesis
+// block, with an easy proof-of-work and short coinbase maturity, and restores the
+// previous store when the test ends
+func useTestStore(t *testing.T) *MemoryStore {
+	t.Helper()
+
+	t.Setenv("MINING_DIFFICULTY_BITS", "1")
+	t.Setenv("MIN_DIFFICULTY_BITS", "1")
+	t.Setenv("MINING_WORKERS", "1")
+	t.Setenv("MIN_TRANSACTION_FEE", "0.001")
+	t.Setenv("MINING_REWARD", "50")
+	t.Setenv("COINBASE_MATURITY", "2")
+	t.Setenv("MINING_TX_THRESHOLD", "0")
+
+	previous := GetStore()
+	memory := NewMemoryStore()
+	SetStore(memory)
+	resetChain()
+	InitBlockchain()
+
+	t.Cleanup(func() {
+		SetStore(previous)
+		resetChain()
+	})
+	return memory
+}
+
+// resetChain empties the in-memory chain and pending pool
+func resetChain() {
+	blockchainMutex.Lock()
+	defer blockchainMutex.Unlock()
+
+	blockchain = nil
+	pendingTransactions = nil
+}
+
+// newTestWallet creates a user with an Ed25519 wallet funded like a new registration
+func newTestWallet(t *testing.T, balance models.Amount) testWallet {
+	t.Helper()
+
+	privateKey, publicKey, err := crypto.GenerateKeys(crypto.AlgorithmEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKeys: %v", err)
+	}
+	walletID, err := crypto.WalletIDFromPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("WalletIDFromPublicKey: %v", err)
+	}
+
+	user := &models.User{
+		ID:        uuid.New().String(),
+		Email:     walletID + "@example.com",
+		WalletID:  walletID,
+		PublicKey: publicKey,
+		CreatedAt: time.Now(),
+	}
+	if err := SaveUser(user); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+	wallet := &models.Wallet{
+		WalletID:  walletID,
+		UserID:    user.ID,
+		PublicKey: publicKey,
+		CreatedAt: time.Now(),
+		IsActive:  true,
+	}
+	if err := SaveWallet(wallet); err != nil {
+		t.Fatalf("SaveWallet: %v", err)
+	}
+
+	if balance > 0 {
+		if _, err := CreateUTXO(walletID, balance, fmt.Sprintf("genesis-%s", user.ID), 0); err != nil {
+			t.Fatalf("CreateUTXO: %v", err)
+		}
+	}
+
+	return testWallet{WalletID: walletID, UserID: user.ID, PublicKey: publicKey, PrivateKey: privateKey}
+}
+
+// sign signs a built transaction with the wallet's key
+func (w testWallet) sign(t *testing.T, tx *models.Transaction) {
+	t.Helper()
+
+	signature, err := crypto.SignHash(tx.Hash, w.PrivateKey)
+	if err != nil {
+		t.Fatalf("SignHash: %v", err)
+	}
+	tx.Signature = signature
+}
+
+// transfer builds and signs a payment from one wallet to another
+func (w testWallet) transfer(t *testing.T, to testWallet, amount models.Amount) models.Transaction {
+	t.Helper()
+
+	tx, err := BuildTransaction(w.WalletID, to.WalletID, amount, GetMinimumFee(), "", w.PublicKey)
+	if err != nil {
+		t.Fatalf("BuildTransaction: %v", err)
+	}
+	w.sign(t, tx)
+	return *tx
+}
+
+// mustMine mines the pending pool into a block
+func mustMine(t *testing.T, minerWalletID string) models.Block {
+	t.Helper()
+
+	block, err := MineBlock(minerWalletID)
+	if err != nil {
+		t.Fatalf("MineBlock: %v", err)
+	}
+	return block
+}
+
+// mustBalance returns a wallet's balance from its unspent UTXOs
+func mustBalance(t *testing.T, walletID string) models.Amount {
+	t.Helper()
+
+	balance, err := CalculateBalance(walletID)
+	if err != nil {
+		t.Fatalf("CalculateBalance: %v", err)
+	}
+	return balance
+}
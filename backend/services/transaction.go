@@ -3,89 +3,159 @@ package services
 import (
 	"backend/crypto"
 	"backend/models"
+	"backend/script"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"time"
+)
 
-	"github.com/google/uuid"
+// Errors returned when a transaction spends inputs it is not entitled to
+var (
+	ErrSenderKeyMismatch  = errors.New("sender public key does not match the sender wallet")
+	ErrInputNotOwned      = errors.New("input UTXO does not belong to the sender")
+	ErrDuplicateInput     = errors.New("input UTXO is spent more than once in the transaction")
+	ErrInputAlreadyQueued = errors.New("input UTXO is already spent by a pending transaction")
 )
 
-// CreateTransaction creates a new transaction
-func CreateTransaction(senderWalletID, receiverWalletID string, amount float64, note, senderPublicKey, privateKeyStr string) (*models.Transaction, error) {
-	// Prevent self-transfer
-	if senderWalletID == receiverWalletID {
-		return nil, fmt.Errorf("cannot send money to yourself")
+// MaxTransferAmount is the most one transfer, or one line of a batch, can pay
+const MaxTransferAmount = 1000000 * models.BC
+
+// CreateTransaction builds a transaction and signs it with the sender's private key
+func CreateTransaction(senderWalletID, receiverWalletID string, amount, fee models.Amount, note, senderPublicKey, privateKeyStr string) (*models.Transaction, error) {
+	tx, err := BuildTransaction(senderWalletID, receiverWalletID, amount, fee, note, senderPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := crypto.DetectKeyAlgorithm(privateKeyStr); err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+
+	signature, err := crypto.SignHash(tx.Hash, privateKeyStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %v", err)
+	}
+
+	tx.Signature = signature
+
+	return tx, nil
+}
+
+// BuildTransaction selects inputs and builds an unsigned transfer whose hash is ready to
+// be signed by the holder of senderPublicKey. The fee is left out of the outputs and goes
+// to the miner of the block that includes the transaction.
+func BuildTransaction(senderWalletID, receiverWalletID string, amount, fee models.Amount, note, senderPublicKey string) (*models.Transaction, error) {
+	return BuildTransactionWithLockTime(senderWalletID, receiverWalletID, amount, fee, note, senderPublicKey, 0)
+}
+
+// BuildTransactionWithLockTime builds an unsigned transfer that cannot be mined before
+// lockTime, a block height or a Unix timestamp (see LockTimeThreshold). The signed
+// transaction is rejected until then, so it is kept by the client and submitted later.
+func BuildTransactionWithLockTime(senderWalletID, receiverWalletID string, amount, fee models.Amount, note, senderPublicKey string, lockTime int64) (*models.Transaction, error) {
+	return BuildTransactionWithOptions(senderWalletID, receiverWalletID, amount, fee, note, senderPublicKey, TransferOptions{LockTime: lockTime})
+}
+
+// TransferOptions are the optional spending conditions of a built transfer
+type TransferOptions struct {
+	LockTime      int64    // Block height or Unix time before which it cannot be mined (see LockTimeThreshold)
+	LockingScript []byte   // Locks the payment to the receiver; nil locks it to the receiver's key
+	Inputs        []string // UTXOs to spend, such as script-locked ones; nil selects the sender's plain UTXOs
+}
+
+// BuildTransactionWithOptions builds an unsigned transfer with a lock time, a locking
+// script on the payment, or chosen inputs. Inputs locked by a script need an unlocking
+// script in UnlockingScripts when the signed transaction is submitted.
+func BuildTransactionWithOptions(senderWalletID, receiverWalletID string, amount, fee models.Amount, note, senderPublicKey string, opts TransferOptions) (*models.Transaction, error) {
+	if opts.LockTime < 0 {
+		return nil, fmt.Errorf("lock time cannot be negative")
+	}
+	if opts.LockingScript != nil {
+		if _, err := script.Parse(opts.LockingScript); err != nil {
+			return nil, fmt.Errorf("invalid locking script: %v", err)
+		}
 	}
 
 	// Validate minimum amount
-	if amount < 0.01 {
+	if amount < models.BC/100 {
 		return nil, fmt.Errorf("minimum transaction amount is 0.01 BC")
 	}
 
+	// Validate fee
+	if minFee := GetMinimumFee(); fee < minFee {
+		return nil, fmt.Errorf("minimum transaction fee is %s BC", minFee)
+	}
+
 	// Validate sender wallet exists
 	senderWallet, err := GetWalletByID(senderWalletID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid sender wallet ID: %v", err)
 	}
 
-	// Validate receiver wallet exists
-	_, err = GetWalletByID(receiverWalletID)
-	if err != nil {
+	// Catch mistyped addresses before looking the receiver up
+	if err := crypto.ValidateWalletIDFormat(receiverWalletID); err != nil {
 		return nil, fmt.Errorf("invalid receiver wallet ID: %v", err)
 	}
 
-	// Check balance
-	balance, err := CalculateBalance(senderWalletID)
+	// Validate receiver wallet exists
+	receiverWallet, err := GetWalletByID(receiverWalletID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid receiver wallet ID: %v", err)
 	}
 
-	if balance < amount {
-		return nil, fmt.Errorf("insufficient balance: have %.2f, need %.2f", balance, amount)
+	// Outputs are locked to the stored wallet IDs, whichever alias was given
+	senderWalletID = senderWallet.WalletID
+	receiverWalletID = receiverWallet.WalletID
+
+	// Prevent self-transfer
+	if senderWalletID == receiverWalletID {
+		return nil, fmt.Errorf("cannot send money to yourself")
 	}
 
-	// Select UTXOs to spend
-	selectedUTXOs, total, err := SelectUTXOs(senderWalletID, amount)
-	if err != nil {
-		return nil, err
+	// Select UTXOs to spend, or take the ones given
+	var selectedUTXOs []models.UTXO
+	var total models.Amount
+	if len(opts.Inputs) > 0 {
+		selectedUTXOs, total, err = LoadInputUTXOs(opts.Inputs)
+		if err != nil {
+			return nil, err
+		}
+		if total < amount+fee {
+			return nil, fmt.Errorf("insufficient inputs: have %s, need %s", total, amount+fee)
+		}
+	} else {
+		// Check balance
+		balance, err := CalculateBalance(senderWalletID)
+		if err != nil {
+			return nil, err
+		}
+
+		if balance < amount+fee {
+			return nil, fmt.Errorf("insufficient balance: have %s, need %s", balance, amount+fee)
+		}
+
+		selectedUTXOs, total, err = SelectUTXOs(senderWalletID, amount+fee)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Create transaction
-	timestamp := time.Now()
 	tx := &models.Transaction{
-		Hash:             uuid.New().String(),
+		Version:          currentTransactionVersion,
 		SenderWalletID:   senderWalletID,
 		ReceiverWalletID: receiverWalletID,
 		Amount:           amount,
+		Fee:              fee,
 		Note:             note,
-		Timestamp:        timestamp,
+		Timestamp:        storageTime(),
+		LockTime:         opts.LockTime,
 		SenderPublicKey:  senderPublicKey,
 		Type:             "transfer",
 		Status:           "pending",
 	}
 
-	// Create signature payload
-	payload := crypto.CreateTransactionPayload(
-		senderWalletID,
-		receiverWalletID,
-		amount,
-		timestamp.String(),
-		note,
-	)
-
-	// Sign transaction
-	privateKey, err := crypto.StringToPrivateKey(privateKeyStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid private key: %v", err)
-	}
-
-	signature, err := crypto.SignData(payload, privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %v", err)
-	}
-
-	tx.Signature = signature
-
 	// Add input UTXOs
 	for _, utxo := range selectedUTXOs {
 		tx.InputUTXOs = append(tx.InputUTXOs, utxo.ID)
@@ -96,10 +166,11 @@ func CreateTransaction(senderWalletID, receiverWalletID string, amount float64,
 	tx.OutputUTXOs = append(tx.OutputUTXOs, models.UTXOOutput{
 		WalletID: receiverWalletID,
 		Amount:   amount,
+		Script:   hex.EncodeToString(opts.LockingScript),
 	})
 
-	// 2. Change back to sender (if any)
-	change := total - amount
+	// 2. Change back to sender (if any), less the fee
+	change := total - amount - fee
 	if change > 0 {
 		tx.OutputUTXOs = append(tx.OutputUTXOs, models.UTXOOutput{
 			WalletID: senderWalletID,
@@ -107,102 +178,302 @@ func CreateTransaction(senderWalletID, receiverWalletID string, amount float64,
 		})
 	}
 
-	// Calculate transaction hash
-	tx.Hash = crypto.HashSHA256(fmt.Sprintf("%s%s%.8f%d", senderWalletID, receiverWalletID, amount, timestamp.Unix()))
-
-	// Update wallet balance (cached)
-	senderWallet.Balance -= amount
-	senderWallet.UpdatedAt = time.Now()
-	if err := UpdateWallet(senderWallet); err != nil {
-		log.Printf("Warning: failed to update sender wallet balance: %v", err)
-	}
+	// The signature covers this hash of the complete transaction
+	tx.Hash = CalculateTransactionHash(*tx)
 
 	return tx, nil
 }
 
 // ValidateTransaction validates a transaction
 func ValidateTransaction(tx models.Transaction) error {
-	// 1. Validate sender wallet ID exists
-	_, err := GetWalletByID(tx.SenderWalletID)
+	// 1. Validate sender wallet ID exists under the ID its UTXOs are locked to
+	sender, err := GetWalletByID(tx.SenderWalletID)
 	if err != nil {
 		return fmt.Errorf("invalid sender wallet ID")
 	}
+	if sender.WalletID != tx.SenderWalletID {
+		return fmt.Errorf("sender must be given as wallet ID %s, not an alias", sender.WalletID)
+	}
 
 	// 2. For zakat transactions, receiver is system wallet (may not exist in DB)
+	// Batches pay the wallets of their outputs, which must all exist
 	// For regular transactions, validate receiver exists
-	if tx.Type != "zakat_deduction" {
-		_, err = GetWalletByID(tx.ReceiverWalletID)
+	switch tx.Type {
+	case "zakat_deduction":
+	case "batch":
+		if err := validateBatchReceivers(tx); err != nil {
+			return err
+		}
+	default:
+		receiver, err := GetWalletByID(tx.ReceiverWalletID)
 		if err != nil {
 			return fmt.Errorf("invalid receiver wallet ID")
 		}
+		if receiver.WalletID != tx.ReceiverWalletID {
+			return fmt.Errorf("receiver must be given as wallet ID %s, not an alias", receiver.WalletID)
+		}
 	}
 
-	// 3. Skip signature verification for system transactions (zakat)
-	if tx.Type != "zakat_deduction" {
-		// Verify digital signature
-		payload := crypto.CreateTransactionPayload(
-			tx.SenderWalletID,
-			tx.ReceiverWalletID,
-			tx.Amount,
-			tx.Timestamp.String(),
-			tx.Note,
-		)
+	// 3. New transactions must commit to their full contents
+	if tx.Version < TransactionVersionCanonical {
+		return fmt.Errorf("unsupported transaction version %d", tx.Version)
+	}
+	if err := verifyTransactionHash(tx); err != nil {
+		return err
+	}
 
-		publicKey, err := crypto.StringToPublicKey(tx.SenderPublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %v", err)
-		}
+	// 4. Immature transactions wait outside the pool until their lock time has passed
+	if err := checkLockTime(tx, nextBlockHeight(), time.Now()); err != nil {
+		return err
+	}
 
-		if err := crypto.VerifySignature(payload, tx.Signature, publicKey); err != nil {
-			return fmt.Errorf("invalid signature: %v", err)
-		}
+	// 5. Escrow outputs must be well formed, and escrows created, claimed and refunded
+	// on the right side of their timeout
+	if err := validateEscrowOutputs(tx); err != nil {
+		return err
+	}
+	if err := validateEscrowDeadlines(tx); err != nil {
+		return err
+	}
+
+	// 6. Batches must pay each receiver once, and only batches carry output notes
+	if err := validateBatchOutputs(tx); err != nil {
+		return err
+	}
+
+	// 7. Locking and unlocking scripts must be well formed
+	if err := validateScripts(tx); err != nil {
+		return err
+	}
+
+	// 8. Verify digital signature (system transactions such as zakat are unsigned)
+	if err := verifyTransactionSignature(tx); err != nil {
+		return err
+	}
+
+	// 9. Every input must meet the script locking it and be spent only once
+	if err := validateInputOwnership(tx); err != nil {
+		return err
 	}
 
-	// 4. Validate UTXOs
+	// 10. Validate UTXOs
 	if err := ValidateUTXOs(tx.InputUTXOs); err != nil {
-		return fmt.Errorf("invalid UTXOs: %v", err)
+		return fmt.Errorf("invalid UTXOs: %w", err)
 	}
 
-	// 5. Check if inputs cover outputs
-	inputTotal := 0.0
+	// 11. Check if inputs cover outputs, which must all be positive
+	inputTotal := models.Amount(0)
 	for _, utxoID := range tx.InputUTXOs {
 		utxo, err := GetUTXOByID(utxoID)
 		if err != nil {
 			return fmt.Errorf("UTXO not found: %v", err)
 		}
-		inputTotal += utxo.Amount
+		if inputTotal, err = inputTotal.Add(utxo.Amount); err != nil {
+			return fmt.Errorf("input total: %v", err)
+		}
 	}
 
-	outputTotal := 0.0
-	for _, output := range tx.OutputUTXOs {
-		outputTotal += output.Amount
+	outputTotal, err := validateOutputAmounts(tx)
+	if err != nil {
+		return err
 	}
 
 	if inputTotal < outputTotal {
-		return fmt.Errorf("insufficient inputs: have %.2f, need %.2f", inputTotal, outputTotal)
+		return fmt.Errorf("insufficient inputs: have %s, need %s", inputTotal, outputTotal)
+	}
+
+	// 12. Whatever the outputs do not spend is the fee, and it must be declared
+	if err := validateFee(tx, inputTotal, outputTotal); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// ProcessTransaction processes and adds transaction to pending pool
-func ProcessTransaction(tx models.Transaction) error {
-	// Validate transaction
-	if err := ValidateTransaction(tx); err != nil {
-		// Log failed transaction
-		LogSystemEvent("validation_failure", fmt.Sprintf("Transaction validation failed: %v", err), "", "")
-		return err
+// validateInputOwnership checks that a signed transaction's public key derives its
+// sender wallet, that every input meets the script locking it, and that no input is
+// listed twice or already spent by another transaction in the pending pool. Escrow
+// outputs are instead spent by a claim or refund meeting their lock. Unsigned system
+// transactions such as zakat must still spend only the sender's script-less UTXOs.
+func validateInputOwnership(tx models.Transaction) error {
+	if requiresSignature(tx) {
+		// Wallets created before addresses are keyed by the legacy hex form
+		walletIDFromPublicKey := crypto.WalletIDFromPublicKey
+		if crypto.IsLegacyWalletID(tx.SenderWalletID) {
+			walletIDFromPublicKey = crypto.LegacyWalletIDFromPublicKey
+		}
+		signer, err := walletIDFromPublicKey(tx.SenderPublicKey)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %v", err)
+		}
+		if signer != tx.SenderWalletID {
+			return fmt.Errorf("%w: key belongs to %s, sender is %s", ErrSenderKeyMismatch, signer, tx.SenderWalletID)
+		}
 	}
 
-	// Save transaction to database
-	if err := SaveTransaction(&tx); err != nil {
-		return err
+	queued := make(map[string]string)
+	for _, pending := range GetPendingTransactionsFromMemory() {
+		if pending.Hash == tx.Hash {
+			continue
+		}
+		for _, utxoID := range pending.InputUTXOs {
+			queued[utxoID] = pending.Hash
+		}
+	}
+
+	checker := newTransactionChecker(tx)
+	seen := make(map[string]bool, len(tx.InputUTXOs))
+	for idx, utxoID := range tx.InputUTXOs {
+		if seen[utxoID] {
+			return fmt.Errorf("%w: %s", ErrDuplicateInput, utxoID)
+		}
+		seen[utxoID] = true
+
+		if spender, ok := queued[utxoID]; ok {
+			return fmt.Errorf("%w: %s in %s", ErrInputAlreadyQueued, utxoID, spender)
+		}
+
+		utxo, err := GetUTXOByID(utxoID)
+		if err != nil {
+			return fmt.Errorf("UTXO %s not found", utxoID)
+		}
+		if utxo.Lock != nil {
+			if err := checkEscrowSpend(tx, utxo.Lock); err != nil {
+				return fmt.Errorf("escrow %s: %w", utxoID, err)
+			}
+			continue
+		}
+		if tx.Type == "escrow_claim" || tx.Type == "escrow_refund" {
+			return fmt.Errorf("%w: %s is not an escrow output", ErrEscrowSpendShape, utxoID)
+		}
+		if err := checkInputUnlocked(tx, idx, utxo.WalletID, utxo.Script, checker); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateOutputAmounts checks that every output pays a positive amount and returns their
+// total, failing if it overflows. A transfer or zakat deduction must pay its receiver
+// its amount in one output, with at most one change output back to the sender.
+func validateOutputAmounts(tx models.Transaction) (models.Amount, error) {
+	total := models.Amount(0)
+	for idx, output := range tx.OutputUTXOs {
+		if output.Amount <= 0 {
+			return 0, fmt.Errorf("output %d amount %s must be positive", idx, output.Amount)
+		}
+		var err error
+		if total, err = total.Add(output.Amount); err != nil {
+			return 0, fmt.Errorf("output total: %v", err)
+		}
+	}
+
+	if tx.Type != "transfer" && tx.Type != "zakat_deduction" {
+		return total, nil
+	}
+	payments, changes := 0, 0
+	for idx, output := range tx.OutputUTXOs {
+		switch output.WalletID {
+		case tx.ReceiverWalletID:
+			if output.Amount != tx.Amount {
+				return 0, fmt.Errorf("output %d pays the receiver %s, not the amount %s", idx, output.Amount, tx.Amount)
+			}
+			payments++
+		case tx.SenderWalletID:
+			changes++
+		default:
+			return 0, fmt.Errorf("output %d pays %s, who is neither the receiver nor the sender", idx, output.WalletID)
+		}
+	}
+	if payments != 1 || changes > 1 {
+		return 0, fmt.Errorf("a %s pays its receiver once and returns at most one change output", tx.Type)
+	}
+	return total, nil
+}
+
+// validateFee checks the declared fee against inputs minus outputs and the minimum fee
+// policy, which applies to every transaction a user signs. System transactions such as
+// zakat pay no fee.
+func validateFee(tx models.Transaction, inputTotal, outputTotal models.Amount) error {
+	if tx.Fee < 0 {
+		return fmt.Errorf("fee cannot be negative")
+	}
+
+	if implied := inputTotal - outputTotal; implied != tx.Fee {
+		return fmt.Errorf("fee %s does not match inputs minus outputs %s", tx.Fee, implied)
+	}
+
+	if requiresSignature(tx) {
+		if minFee := GetMinimumFee(); tx.Fee < minFee {
+			return fmt.Errorf("fee %s is below the minimum of %s", tx.Fee, minFee)
+		}
+	}
+
+	return nil
+}
+
+// requiresSignature reports whether a transaction type must carry a sender signature
+func requiresSignature(tx models.Transaction) bool {
+	return tx.Type != "zakat_deduction" && tx.Type != "genesis" && !isRewardTransaction(tx)
+}
+
+// legacySigningPayload builds the string a legacy transaction's signature covers. It
+// holds the timestamp's String form as it was signed, which includes the location and
+// monotonic clock reading, so a signature stops verifying once the timestamp has been
+// through a database round-trip.
+func legacySigningPayload(tx models.Transaction) string {
+	return crypto.CreateTransactionPayload(
+		tx.SenderWalletID,
+		tx.ReceiverWalletID,
+		tx.Amount.Fixed(),
+		tx.Timestamp.String(),
+		tx.Note,
+	)
+}
+
+// verifyTransactionSignature checks the sender's signature. Canonical transactions are
+// signed over their hash, which must already have been checked against the contents, with
+// any supported key algorithm, or by enough co-signers of a multisig policy. Legacy
+// transactions are always RSA.
+func verifyTransactionSignature(tx models.Transaction) error {
+	if !requiresSignature(tx) {
+		return nil
+	}
+
+	if tx.Version >= TransactionVersionCanonical {
+		if algorithm, _ := crypto.DetectKeyAlgorithm(tx.SenderPublicKey); algorithm == crypto.AlgorithmMultisig {
+			return verifyMultisigSignatures(tx)
+		}
+		if err := crypto.VerifyHashSignature(tx.Hash, tx.Signature, tx.SenderPublicKey); err != nil {
+			return fmt.Errorf("invalid signature: %v", err)
+		}
+		return nil
 	}
 
-	// Add to pending pool
-	if err := AddPendingTransaction(tx); err != nil {
+	publicKey, err := crypto.StringToPublicKey(tx.SenderPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %v", err)
+	}
+	if err := crypto.VerifySignature(legacySigningPayload(tx), tx.Signature, publicKey); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	return nil
+}
+
+// ProcessTransaction validates a transaction and atomically commits its UTXO changes
+// and pending pool entry
+func ProcessTransaction(tx models.Transaction) error {
+	if err := commitValidTransaction(tx); err != nil {
 		return err
 	}
+	notifyMiner()
+
+	// Refresh the sender's cached balance now that the inputs are spent
+	if err := RecalculateWalletBalance(tx.SenderWalletID); err != nil {
+		log.Printf("Warning: failed to update sender wallet balance: %v", err)
+	}
 
 	// Log transaction
 	LogTransactionEvent(tx.Hash, "pending", "", tx.SenderWalletID, "", tx.Amount, "pending")
@@ -211,6 +482,29 @@ func ProcessTransaction(tx models.Transaction) error {
 	return nil
 }
 
+// commitValidTransaction validates, commits and queues a transaction while holding the
+// UTXO set for reading, so a reindex cannot replace the set between validation and commit
+// or drop the transaction's changes
+func commitValidTransaction(tx models.Transaction) error {
+	utxoSetMutex.RLock()
+	defer utxoSetMutex.RUnlock()
+
+	// Validate transaction
+	if err := ValidateTransaction(tx); err != nil {
+		// Log failed transaction
+		LogSystemEvent("validation_failure", fmt.Sprintf("Transaction validation failed: %v", err), "", "")
+		return err
+	}
+
+	// Spend inputs, create outputs, save and queue the transaction atomically
+	if err := CommitTransaction(tx, NewOutputUTXOs(tx)); err != nil {
+		LogSystemEvent("validation_failure", fmt.Sprintf("Transaction commit failed: %v", err), "", "")
+		return err
+	}
+	queuePendingTransaction(tx)
+	return nil
+}
+
 // GetTransactionsByWallet retrieves all transactions for a wallet
 func GetTransactionsByWallet(walletID string) ([]models.Transaction, error) {
 	return GetWalletTransactions(walletID)
@@ -222,7 +516,7 @@ func GetTransactionByHash(hash string) (*models.Transaction, error) {
 }
 
 // CreateZakatTransaction creates a zakat deduction transaction
-func CreateZakatTransaction(walletID string, amount float64, month string) (*models.Transaction, error) {
+func CreateZakatTransaction(walletID string, amount models.Amount, month string) (*models.Transaction, error) {
 	zakatPoolWallet := GetZakatPoolWallet()
 
 	// Select UTXOs
@@ -232,12 +526,12 @@ func CreateZakatTransaction(walletID string, amount float64, month string) (*mod
 	}
 
 	tx := &models.Transaction{
-		Hash:             uuid.New().String(),
+		Version:          currentTransactionVersion,
 		SenderWalletID:   walletID,
 		ReceiverWalletID: zakatPoolWallet,
 		Amount:           amount,
 		Note:             fmt.Sprintf("Zakat deduction for %s", month),
-		Timestamp:        time.Now(),
+		Timestamp:        storageTime(),
 		Type:             "zakat_deduction",
 		Status:           "pending",
 	}
@@ -262,5 +556,7 @@ func CreateZakatTransaction(walletID string, amount float64, month string) (*mod
 		})
 	}
 
+	tx.Hash = CalculateTransactionHash(*tx)
+
 	return tx, nil
 }
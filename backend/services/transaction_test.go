@@ -0,0 +1,308 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProcessTransactionCommitsInputsAndOutputs(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	tx := alice.transfer(t, bob, 30*models.BC)
+	if err := ProcessTransaction(tx); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+
+	for _, utxoID := range tx.InputUTXOs {
+		utxo, err := memory.GetUTXOByID(utxoID)
+		if err != nil {
+			t.Fatalf("GetUTXOByID: %v", err)
+		}
+		if !utxo.Spent || utxo.SpentInTxHash != tx.Hash {
+			t.Errorf("input %s: spent=%v by %q, want spent by %s", utxoID, utxo.Spent, utxo.SpentInTxHash, tx.Hash)
+		}
+	}
+
+	if got := mustBalance(t, bob.WalletID); got != 30*models.BC {
+		t.Errorf("receiver balance = %s, want 30", got)
+	}
+	if got, want := mustBalance(t, alice.WalletID), 70*models.BC-GetMinimumFee(); got != want {
+		t.Errorf("sender balance = %s, want %s", got, want)
+	}
+
+	if _, err := memory.GetTransaction(tx.Hash); err != nil {
+		t.Errorf("transaction not stored: %v", err)
+	}
+	pending, _ := memory.GetPendingTransactions()
+	if len(pending) != 1 || pending[0].Hash != tx.Hash {
+		t.Errorf("pending pool = %v, want only %s", pending, tx.Hash)
+	}
+}
+
+func TestProcessTransactionRejectsDoubleSpend(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	carol := newTestWallet(t, 0)
+
+	// Both payments are built before either is submitted, so they select the same input
+	first := alice.transfer(t, bob, 10*models.BC)
+	second := alice.transfer(t, carol, 10*models.BC)
+
+	if err := ProcessTransaction(first); err != nil {
+		t.Fatalf("first ProcessTransaction: %v", err)
+	}
+
+	err := ProcessTransaction(second)
+	if !errors.Is(err, ErrInputAlreadyQueued) {
+		t.Fatalf("second spend while first is pending: err = %v, want ErrInputAlreadyQueued", err)
+	}
+
+	mustMine(t, GetMinerWallet())
+
+	err = ProcessTransaction(second)
+	if !errors.Is(err, ErrUTXOAlreadySpent) {
+		t.Fatalf("second spend after first is mined: err = %v, want ErrUTXOAlreadySpent", err)
+	}
+	if got := mustBalance(t, carol.WalletID); got != 0 {
+		t.Errorf("double-spent receiver balance = %s, want 0", got)
+	}
+}
+
+func TestCommitTransactionIsAtomic(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	unspent, _ := memory.GetUnspentUTXOs(alice.WalletID)
+	spent := models.UTXO{ID: "spent-utxo", WalletID: alice.WalletID, Amount: models.BC, Spent: true, SpentInTxHash: "earlier"}
+	if err := memory.SaveUTXO(&spent); err != nil {
+		t.Fatalf("SaveUTXO: %v", err)
+	}
+
+	tx := models.Transaction{
+		Hash:             "atomic",
+		SenderWalletID:   alice.WalletID,
+		ReceiverWalletID: bob.WalletID,
+		InputUTXOs:       []string{unspent[0].ID, spent.ID},
+		OutputUTXOs:      []models.UTXOOutput{{WalletID: bob.WalletID, Amount: 50 * models.BC}},
+	}
+
+	err := CommitTransaction(tx, NewOutputUTXOs(tx))
+	if !errors.Is(err, ErrUTXOAlreadySpent) {
+		t.Fatalf("CommitTransaction: err = %v, want ErrUTXOAlreadySpent", err)
+	}
+
+	if utxo, _ := memory.GetUTXOByID(unspent[0].ID); utxo.Spent {
+		t.Error("valid input was spent by a failed commit")
+	}
+	if got := mustBalance(t, bob.WalletID); got != 0 {
+		t.Errorf("outputs of a failed commit were saved: receiver balance %s", got)
+	}
+	if _, err := memory.GetTransaction(tx.Hash); err == nil {
+		t.Error("failed commit stored its transaction")
+	}
+	if pending, _ := memory.GetPendingTransactions(); len(pending) != 0 {
+		t.Errorf("failed commit queued %d transactions", len(pending))
+	}
+}
+
+func TestCommitTransactionConcurrentSpendsCommitOnce(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	unspent, _ := memory.GetUnspentUTXOs(alice.WalletID)
+	input := unspent[0].ID
+
+	const spenders = 8
+	errs := make(chan error, spenders)
+	var wg sync.WaitGroup
+	for i := 0; i < spenders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tx := models.Transaction{
+				Hash:             string(rune('a' + i)),
+				SenderWalletID:   alice.WalletID,
+				ReceiverWalletID: bob.WalletID,
+				InputUTXOs:       []string{input},
+				OutputUTXOs:      []models.UTXOOutput{{WalletID: bob.WalletID, Amount: 100 * models.BC}},
+			}
+			errs <- CommitTransaction(tx, NewOutputUTXOs(tx))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	committed := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			committed++
+		case !errors.Is(err, ErrUTXOAlreadySpent):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if committed != 1 {
+		t.Fatalf("%d commits spent the same input, want 1", committed)
+	}
+	if got := mustBalance(t, bob.WalletID); got != 100*models.BC {
+		t.Errorf("receiver balance = %s, want 100", got)
+	}
+}
+
+func TestValidateTransactionChecksOutputAmounts(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	carol := newTestWallet(t, 0)
+
+	// forge re-signs a transfer of 10 BC from Alice to Bob after changing it
+	forge := func(change func(tx *models.Transaction)) models.Transaction {
+		tx := alice.transfer(t, bob, 10*models.BC)
+		change(&tx)
+		tx.Hash = CalculateTransactionHash(tx)
+		alice.sign(t, &tx)
+		return tx
+	}
+	const huge = models.Amount(1) << 62
+
+	tests := []struct {
+		name    string
+		tx      models.Transaction
+		wantErr string
+	}{
+		{"negative change", forge(func(tx *models.Transaction) {
+			// Inputs minus outputs still equals the fee
+			tx.Amount = 1000000 * models.BC
+			tx.OutputUTXOs[0].Amount = tx.Amount
+			tx.OutputUTXOs[1].Amount = 100*models.BC - tx.Fee - tx.Amount
+		}), "must be positive"},
+		{"zero change", forge(func(tx *models.Transaction) {
+			tx.OutputUTXOs[1].Amount = 0
+		}), "must be positive"},
+		{"output total overflow", forge(func(tx *models.Transaction) {
+			tx.Amount = huge
+			tx.OutputUTXOs = []models.UTXOOutput{
+				{WalletID: bob.WalletID, Amount: huge},
+				{WalletID: alice.WalletID, Amount: huge},
+				{WalletID: alice.WalletID, Amount: huge},
+				{WalletID: alice.WalletID, Amount: huge},
+			}
+		}), "overflows"},
+		{"receiver paid more than the amount", forge(func(tx *models.Transaction) {
+			tx.OutputUTXOs[0].Amount += models.BC
+			tx.OutputUTXOs[1].Amount -= models.BC
+		}), "not the amount"},
+		{"receiver paid twice", forge(func(tx *models.Transaction) {
+			tx.OutputUTXOs[1].Amount -= tx.Amount
+			tx.OutputUTXOs = append(tx.OutputUTXOs, tx.OutputUTXOs[0])
+		}), "pays its receiver once"},
+		{"two change outputs", forge(func(tx *models.Transaction) {
+			half := tx.OutputUTXOs[1].Amount / 2
+			tx.OutputUTXOs[1].Amount -= half
+			tx.OutputUTXOs = append(tx.OutputUTXOs, models.UTXOOutput{WalletID: alice.WalletID, Amount: half})
+		}), "at most one change output"},
+		{"output to a third wallet", forge(func(tx *models.Transaction) {
+			tx.OutputUTXOs[1].WalletID = carol.WalletID
+		}), "neither the receiver nor the sender"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ProcessTransaction(tt.tx)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ProcessTransaction: err = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := mustBalance(t, alice.WalletID); got != 100*models.BC {
+		t.Errorf("sender balance = %s, want 100", got)
+	}
+	if got := mustBalance(t, bob.WalletID); got != 0 {
+		t.Errorf("receiver balance = %s, want 0", got)
+	}
+}
+
+func TestValidateTransactionRejectsBatchOutputOverflow(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+	carol := newTestWallet(t, 0)
+
+	tx, err := BuildBatchTransaction(alice.WalletID, []BatchPayment{
+		{WalletID: bob.WalletID, Amount: 5 * models.BC},
+		{WalletID: carol.WalletID, Amount: 5 * models.BC},
+	}, GetMinimumFee(), "", alice.PublicKey)
+	if err != nil {
+		t.Fatalf("BuildBatchTransaction: %v", err)
+	}
+
+	// Two lines of 2^62 base units wrap to a negative total the batch declares as its amount
+	huge := models.Amount(1) << 62
+	tx.OutputUTXOs[0].Amount = huge
+	tx.OutputUTXOs[1].Amount = huge
+	tx.Amount = huge + huge
+	tx.Hash = CalculateTransactionHash(*tx)
+	alice.sign(t, tx)
+
+	if err := ProcessTransaction(*tx); err == nil || !strings.Contains(err.Error(), "overflows") {
+		t.Fatalf("ProcessTransaction: err = %v, want an overflow", err)
+	}
+	if got := mustBalance(t, bob.WalletID); got != 0 {
+		t.Errorf("receiver balance = %s, want 0", got)
+	}
+}
+
+func TestValidateInputOwnershipChecksSenderKey(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 0)
+	bob := newTestWallet(t, 0)
+
+	legacyAlice, err := crypto.LegacyWalletIDFromPublicKey(alice.PublicKey)
+	if err != nil {
+		t.Fatalf("LegacyWalletIDFromPublicKey: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sender    string
+		publicKey string
+		wantErr   error
+		wantText  string
+	}{
+		{"address with its key", alice.WalletID, alice.PublicKey, nil, ""},
+		{"legacy ID with its key", legacyAlice, alice.PublicKey, nil, ""},
+		{"address with another key", alice.WalletID, bob.PublicKey, ErrSenderKeyMismatch, ""},
+		{"legacy ID with another key", legacyAlice, bob.PublicKey, ErrSenderKeyMismatch, ""},
+		{"address with an invalid key", alice.WalletID, "not a key", nil, "invalid public key"},
+		{"legacy ID with an invalid key", legacyAlice, "not a key", nil, "invalid public key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := models.Transaction{Type: "transfer", SenderWalletID: tt.sender, SenderPublicKey: tt.publicKey}
+			err := validateInputOwnership(tx)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantText) {
+					t.Errorf("err = %v, want %q", err, tt.wantText)
+				}
+			case err != nil:
+				t.Errorf("err = %v, want none", err)
+			}
+		})
+	}
+}
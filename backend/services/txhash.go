@@ -0,0 +1,116 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Transaction versions
+const (
+	// TransactionVersionLegacy transactions sign only sender, receiver, amount, timestamp
+	// and note, and their hash is not derived from their contents
+	TransactionVersionLegacy = 0
+	// TransactionVersionCanonical transactions are hashed from their canonical
+	// serialization and the signature covers that hash
+	TransactionVersionCanonical = 1
+	// TransactionVersionLockTime transactions also commit to their lock time
+	TransactionVersionLockTime = 2
+	// TransactionVersionEscrow transactions also commit to escrow output locks and the
+	// preimage revealed by a claim
+	TransactionVersionEscrow = 3
+	// TransactionVersionScript transactions also commit to each output's locking script
+	TransactionVersionScript = 4
+	// TransactionVersionBatch transactions also commit to each output's note, which
+	// describes a receiver's line of a batch payment
+	TransactionVersionBatch = 5
+)
+
+// currentTransactionVersion is the version assigned to newly created transactions
+const currentTransactionVersion = TransactionVersionBatch
+
+// SerializeTransaction encodes every field a transaction commits to: version, type,
+// sender, receiver, sender public key, amount, fee, timestamp, note, inputs, outputs and,
+// from version 2, the lock time. Version 3 adds each output's escrow lock, written after
+// its amount as a presence byte and the lock fields, and the claim preimage; version 4
+// adds each output's locking script after its lock, and version 5 its note after the
+// script. Integers are big-endian, strings and lists are prefixed with their length, and
+// amounts are written in base units. Status, block hash, hash, signatures and unlocking
+// scripts are not part of the encoding.
+func SerializeTransaction(tx models.Transaction) []byte {
+	var buf []byte
+	appendString := func(s string) {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+		buf = append(buf, s...)
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(tx.Version))
+	appendString(tx.Type)
+	appendString(tx.SenderWalletID)
+	appendString(tx.ReceiverWalletID)
+	appendString(tx.SenderPublicKey)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(tx.Amount))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(tx.Fee))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(tx.Timestamp.UnixNano()))
+	appendString(tx.Note)
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(tx.InputUTXOs)))
+	for _, utxoID := range tx.InputUTXOs {
+		appendString(utxoID)
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(tx.OutputUTXOs)))
+	for _, output := range tx.OutputUTXOs {
+		appendString(output.WalletID)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(output.Amount))
+		if tx.Version >= TransactionVersionEscrow {
+			if output.Lock == nil {
+				buf = append(buf, 0)
+			} else {
+				buf = append(buf, 1)
+				appendString(output.Lock.HashLock)
+				appendString(output.Lock.ClaimWalletID)
+				appendString(output.Lock.RefundWalletID)
+				buf = binary.BigEndian.AppendUint64(buf, uint64(output.Lock.Timeout))
+			}
+		}
+		if tx.Version >= TransactionVersionScript {
+			appendString(output.Script)
+		}
+		if tx.Version >= TransactionVersionBatch {
+			appendString(output.Note)
+		}
+	}
+
+	if tx.Version >= TransactionVersionLockTime {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(tx.LockTime))
+	}
+
+	if tx.Version >= TransactionVersionEscrow {
+		appendString(tx.Preimage)
+	}
+
+	return buf
+}
+
+// CalculateTransactionHash returns the hex SHA-256 of a transaction's canonical
+// serialization. This is the hash a canonical transaction's signature covers.
+func CalculateTransactionHash(tx models.Transaction) string {
+	hash := sha256.Sum256(SerializeTransaction(tx))
+	return hex.EncodeToString(hash[:])
+}
+
+// verifyTransactionHash checks that a canonical transaction's hash matches its
+// contents. Legacy hashes cannot be recomputed and are accepted as stored.
+func verifyTransactionHash(tx models.Transaction) error {
+	if tx.Version < TransactionVersionCanonical {
+		return nil
+	}
+
+	if calculated := CalculateTransactionHash(tx); tx.Hash != calculated {
+		return fmt.Errorf("hash %s does not match contents, calculated %s", tx.Hash, calculated)
+	}
+	return nil
+}
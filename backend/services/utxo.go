@@ -4,13 +4,22 @@ import (
 	"backend/models"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// utxoSetMutex guards the UTXO set as a whole. Transfers and registration allowances
+// hold it for reading while they validate and write UTXOs; a reindex, which replaces the
+// whole set, holds it for writing. Block rewards are kept out of a reindex by miningMutex.
+var utxoSetMutex sync.RWMutex
+
 // CreateUTXO creates a new UTXO
-func CreateUTXO(walletID string, amount float64, txHash string, outputIndex int) (*models.UTXO, error) {
+func CreateUTXO(walletID string, amount models.Amount, txHash string, outputIndex int) (*models.UTXO, error) {
+	utxoSetMutex.RLock()
+	defer utxoSetMutex.RUnlock()
+
 	utxo := &models.UTXO{
 		ID:              uuid.New().String(),
 		TransactionHash: txHash,
@@ -34,13 +43,13 @@ func GetUTXOsByWallet(walletID string) ([]models.UTXO, error) {
 }
 
 // CalculateBalance calculates the balance from UTXOs
-func CalculateBalance(walletID string) (float64, error) {
+func CalculateBalance(walletID string) (models.Amount, error) {
 	utxos, err := GetUTXOsByWallet(walletID)
 	if err != nil {
 		return 0, err
 	}
 
-	balance := 0.0
+	balance := models.Amount(0)
 	for _, utxo := range utxos {
 		if !utxo.Spent {
 			balance += utxo.Amount
@@ -50,18 +59,39 @@ func CalculateBalance(walletID string) (float64, error) {
 	return balance, nil
 }
 
-// SelectUTXOs selects UTXOs to spend for a given amount
-func SelectUTXOs(walletID string, amount float64) ([]models.UTXO, float64, error) {
+// CalculateImmatureBalance returns the part of a wallet's balance held in coinbase
+// outputs that cannot be spent yet
+func CalculateImmatureBalance(walletID string) (models.Amount, error) {
+	utxos, err := GetUTXOsByWallet(walletID)
+	if err != nil {
+		return 0, err
+	}
+
+	height := nextBlockHeight()
+	immature := models.Amount(0)
+	for _, utxo := range utxos {
+		if !utxo.Spent && !isMatureUTXO(utxo, height) {
+			immature += utxo.Amount
+		}
+	}
+
+	return immature, nil
+}
+
+// SelectUTXOs selects UTXOs to spend for a given amount. Outputs locked by a script are
+// left for the spender to unlock explicitly.
+func SelectUTXOs(walletID string, amount models.Amount) ([]models.UTXO, models.Amount, error) {
 	utxos, err := GetUTXOsByWallet(walletID)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	var selectedUTXOs []models.UTXO
-	total := 0.0
+	total := models.Amount(0)
+	height := nextBlockHeight()
 
 	for _, utxo := range utxos {
-		if !utxo.Spent {
+		if !utxo.Spent && utxo.Script == "" && isMatureUTXO(utxo, height) {
 			selectedUTXOs = append(selectedUTXOs, utxo)
 			total += utxo.Amount
 
@@ -72,15 +102,54 @@ func SelectUTXOs(walletID string, amount float64) ([]models.UTXO, float64, error
 	}
 
 	if total < amount {
-		return nil, 0, fmt.Errorf("insufficient balance: need %.2f, have %.2f", amount, total)
+		return nil, 0, fmt.Errorf("insufficient balance: need %s, have %s", amount, total)
 	}
 
 	return selectedUTXOs, total, nil
 }
 
+// LoadInputUTXOs loads UTXOs chosen by the spender, checking that each is listed once,
+// unspent, mature and not held in escrow. Whether the transaction may unlock them is
+// checked when it is validated.
+func LoadInputUTXOs(utxoIDs []string) ([]models.UTXO, models.Amount, error) {
+	height := nextBlockHeight()
+	seen := make(map[string]bool, len(utxoIDs))
+	utxos := make([]models.UTXO, 0, len(utxoIDs))
+	total := models.Amount(0)
+
+	for _, utxoID := range utxoIDs {
+		if seen[utxoID] {
+			return nil, 0, fmt.Errorf("%w: %s", ErrDuplicateInput, utxoID)
+		}
+		seen[utxoID] = true
+
+		utxo, err := GetUTXOByID(utxoID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("UTXO %s not found", utxoID)
+		}
+		switch {
+		case utxo.Spent:
+			return nil, 0, fmt.Errorf("%w: %s in transaction %s", ErrUTXOAlreadySpent, utxoID, utxo.SpentInTxHash)
+		case utxo.Lock != nil:
+			return nil, 0, fmt.Errorf("UTXO %s is held in escrow; claim or refund it instead", utxoID)
+		case !isMatureUTXO(*utxo, height):
+			return nil, 0, fmt.Errorf("UTXO %s is an immature coinbase output", utxoID)
+		}
+
+		utxos = append(utxos, *utxo)
+		total += utxo.Amount
+	}
+	return utxos, total, nil
+}
+
 // SpendUTXO marks a UTXO as spent
 func SpendUTXO(utxoID string, txHash string) error {
-	utxo, err := GetUTXOByID(utxoID)
+	return spendUTXO(store, utxoID, txHash)
+}
+
+// spendUTXO marks a UTXO in the given store as spent
+func spendUTXO(s Store, utxoID string, txHash string) error {
+	utxo, err := s.GetUTXOByID(utxoID)
 	if err != nil {
 		return err
 	}
@@ -93,11 +162,12 @@ func SpendUTXO(utxoID string, txHash string) error {
 	utxo.SpentInTxHash = txHash
 	utxo.SpentAt = time.Now()
 
-	return UpdateUTXO(utxo)
+	return s.SaveUTXO(utxo)
 }
 
-// ValidateUTXOs checks if UTXOs are valid and unspent
+// ValidateUTXOs checks if UTXOs are valid, unspent and, for coinbase outputs, mature
 func ValidateUTXOs(utxoIDs []string) error {
+	height := nextBlockHeight()
 	for _, utxoID := range utxoIDs {
 		utxo, err := GetUTXOByID(utxoID)
 		if err != nil {
@@ -105,42 +175,76 @@ func ValidateUTXOs(utxoIDs []string) error {
 		}
 
 		if utxo.Spent {
-			return fmt.Errorf("UTXO %s already spent in transaction %s", utxoID, utxo.SpentInTxHash)
+			return fmt.Errorf("%w: %s in transaction %s", ErrUTXOAlreadySpent, utxoID, utxo.SpentInTxHash)
+		}
+
+		if !isMatureUTXO(*utxo, height) {
+			return fmt.Errorf("UTXO %s is a coinbase output from block %d and cannot be spent for %d blocks",
+				utxoID, utxo.CoinbaseHeight, utxo.CoinbaseHeight+getCoinbaseMaturity()-height)
 		}
 	}
 
 	return nil
 }
 
-// ProcessTransactionUTXOs handles UTXO creation and spending for a transaction
+// NewOutputUTXOs builds the UTXO records for a transaction's outputs without saving them
+func NewOutputUTXOs(tx models.Transaction) []models.UTXO {
+	utxos := make([]models.UTXO, 0, len(tx.OutputUTXOs))
+	for idx, output := range tx.OutputUTXOs {
+		utxos = append(utxos, models.UTXO{
+			ID:              uuid.New().String(),
+			TransactionHash: tx.Hash,
+			OutputIndex:     idx,
+			WalletID:        output.WalletID,
+			Amount:          output.Amount,
+			Spent:           false,
+			CreatedAt:       time.Now(),
+			Lock:            output.Lock,
+			Script:          output.Script,
+		})
+	}
+	return utxos
+}
+
+// ProcessTransactionUTXOs handles UTXO creation and spending for a transaction.
+// Unlike CommitTransaction the writes are not atomic; new transfers should go through
+// ProcessTransaction instead.
 func ProcessTransactionUTXOs(tx models.Transaction) error {
+	if err := applyTransactionUTXOs(store, tx, NewOutputUTXOs(tx)); err != nil {
+		return err
+	}
+
+	log.Printf("Processed UTXOs for transaction %s", tx.Hash)
+	return nil
+}
+
+// applyTransactionUTXOs spends a transaction's inputs and saves the given outputs in s
+func applyTransactionUTXOs(s Store, tx models.Transaction, outputs []models.UTXO) error {
 	// Mark input UTXOs as spent
 	for _, utxoID := range tx.InputUTXOs {
-		if err := SpendUTXO(utxoID, tx.Hash); err != nil {
+		if err := spendUTXO(s, utxoID, tx.Hash); err != nil {
 			return fmt.Errorf("failed to spend UTXO %s: %v", utxoID, err)
 		}
 	}
 
 	// Create output UTXOs
-	for idx, output := range tx.OutputUTXOs {
-		_, err := CreateUTXO(output.WalletID, output.Amount, tx.Hash, idx)
-		if err != nil {
+	for i := range outputs {
+		if err := s.SaveUTXO(&outputs[i]); err != nil {
 			return fmt.Errorf("failed to create output UTXO: %v", err)
 		}
 	}
 
-	log.Printf("Processed UTXOs for transaction %s", tx.Hash)
 	return nil
 }
 
 // GetTotalSupply calculates the total supply of cryptocurrency
-func GetTotalSupply() (float64, error) {
+func GetTotalSupply() (models.Amount, error) {
 	allUTXOs, err := GetAllUTXOs()
 	if err != nil {
 		return 0, err
 	}
 
-	total := 0.0
+	total := models.Amount(0)
 	for _, utxo := range allUTXOs {
 		if !utxo.Spent {
 			total += utxo.Amount
@@ -0,0 +1,491 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Violation types reported by chain validation
+const (
+	ViolationPreviousHash    = "previous_hash"
+	ViolationBlockHash       = "block_hash"
+	ViolationDifficulty      = "difficulty"
+	ViolationProofOfWork     = "proof_of_work"
+	ViolationMerkleRoot      = "merkle_root"
+	ViolationSignature       = "signature"
+	ViolationLegacySignature = "unverifiable_legacy_signature"
+	ViolationTransactionHash = "transaction_hash"
+	ViolationDuplicateTx     = "duplicate_transaction"
+	ViolationMissingInput    = "missing_input"
+	ViolationDoubleSpend     = "double_spend"
+	ViolationInputOwner      = "input_owner"
+	ViolationOverspend       = "overspend"
+	ViolationOutputAmount    = "output_amount"
+	ViolationImmatureSpend   = "immature_coinbase"
+	ViolationLockTime        = "lock_time"
+	ViolationEscrow          = "escrow"
+	ViolationScript          = "script"
+	ViolationBatch           = "batch"
+	ViolationFee             = "fee"
+	ViolationReward          = "reward"
+	ViolationUTXONotInStore  = "utxo_missing_from_store"
+	ViolationUTXONotInChain  = "utxo_not_in_chain"
+	ViolationUTXOMismatch    = "utxo_mismatch"
+	ViolationStoreUnreadable = "store_unreadable"
+)
+
+// ChainViolation describes one problem found while validating the chain.
+// BlockIndex is -1 for problems in the stored UTXO set rather than in a block.
+type ChainViolation struct {
+	Type            string `json:"type"`
+	BlockIndex      int64  `json:"blockIndex"`
+	TransactionHash string `json:"transactionHash,omitempty"`
+	UTXOID          string `json:"utxoId,omitempty"`
+	Message         string `json:"message"`
+}
+
+// ChainValidationReport is the result of a deep chain validation
+type ChainValidationReport struct {
+	Valid               bool             `json:"valid"`
+	BlocksChecked       int              `json:"blocksChecked"`
+	TransactionsChecked int              `json:"transactionsChecked"`
+	Violations          []ChainViolation `json:"violations"`
+	// Unverifiable lists version 0 signatures that no longer verify. They cover the
+	// timestamp as it was before storage, so they are reported without failing the chain.
+	Unverifiable []ChainViolation `json:"unverifiable"`
+}
+
+// outpoint identifies a transaction output independently of the UTXO record ID
+type outpoint struct {
+	txHash string
+	index  int
+}
+
+// replayedOutput is an output in the UTXO set rebuilt from the chain
+type replayedOutput struct {
+	walletID       string
+	amount         models.Amount
+	spent          bool
+	coinbaseHeight int64
+	lock           *models.HashTimeLock
+	script         string
+}
+
+// blockViolations runs the header-level checks: previous-hash links, recomputed hash,
+// difficulty retarget rule and proof-of-work
+func blockViolations(chain []models.Block) []ChainViolation {
+	var violations []ChainViolation
+
+	for i := 1; i < len(chain); i++ {
+		currentBlock := chain[i]
+		previousBlock := chain[i-1]
+
+		// Check if previous hash matches
+		if currentBlock.PreviousHash != previousBlock.Hash {
+			violations = append(violations, ChainViolation{
+				Type:       ViolationPreviousHash,
+				BlockIndex: currentBlock.Index,
+				Message:    fmt.Sprintf("previous hash %s does not match block %d hash %s", currentBlock.PreviousHash, previousBlock.Index, previousBlock.Hash),
+			})
+		}
+
+		// Recalculate hash
+		if calculatedHash := calculateBlockHash(currentBlock); currentBlock.Hash != calculatedHash {
+			violations = append(violations, ChainViolation{
+				Type:       ViolationBlockHash,
+				BlockIndex: currentBlock.Index,
+				Message:    fmt.Sprintf("stored hash %s, calculated %s", currentBlock.Hash, calculatedHash),
+			})
+		}
+
+		// Check difficulty follows the retarget rule. Legacy hex-difficulty blocks
+		// predate retargeting and are only held to their recorded difficulty.
+		if currentBlock.Version >= BlockVersionBits {
+			if expected := nextDifficulty(chain[:i]); currentBlock.Difficulty != expected {
+				violations = append(violations, ChainViolation{
+					Type:       ViolationDifficulty,
+					BlockIndex: currentBlock.Index,
+					Message:    fmt.Sprintf("difficulty %d, retarget rule requires %d", currentBlock.Difficulty, expected),
+				})
+			}
+		}
+
+		// Check PoW
+		if !blockMeetsDifficulty(currentBlock) {
+			violations = append(violations, ChainViolation{
+				Type:       ViolationProofOfWork,
+				BlockIndex: currentBlock.Index,
+				Message:    fmt.Sprintf("hash %s does not meet difficulty %d", currentBlock.Hash, currentBlock.Difficulty),
+			})
+		}
+
+		// Check the miner is paid exactly the subsidy plus fees
+		violations = append(violations, coinbaseViolations(currentBlock)...)
+	}
+
+	return violations
+}
+
+// ValidateChainDeep validates the chain structure, then re-checks every transaction:
+// merkle roots, transaction hashes, signatures, lock times, escrow conditions and
+// scripts, and a full replay of all transactions from genesis that detects missing
+// inputs, double spends and overspends. The replayed UTXO set is finally compared with
+// the stored UTXOs.
+func ValidateChainDeep() ChainValidationReport {
+	blockchainMutex.RLock()
+	chain := append([]models.Block(nil), blockchain...)
+	pending := append([]models.Transaction(nil), pendingTransactions...)
+	blockchainMutex.RUnlock()
+
+	report := ChainValidationReport{
+		BlocksChecked: len(chain),
+		Violations:    blockViolations(chain),
+	}
+
+	storedUTXOs, err := GetAllUTXOs()
+	if err != nil {
+		report.Violations = append(report.Violations, ChainViolation{
+			Type:       ViolationStoreUnreadable,
+			BlockIndex: -1,
+			Message:    fmt.Sprintf("failed to load UTXOs: %v", err),
+		})
+		report.Valid = false
+		return report
+	}
+
+	utxosByID := make(map[string]models.UTXO, len(storedUTXOs))
+	for _, utxo := range storedUTXOs {
+		utxosByID[utxo.ID] = utxo
+	}
+
+	// Registration allowances are created directly as UTXOs rather than on-chain,
+	// so they seed the replayed set
+	replayed := make(map[outpoint]*replayedOutput)
+	for _, utxo := range storedUTXOs {
+		if isAllocationUTXO(utxo) {
+			replayed[outpoint{utxo.TransactionHash, utxo.OutputIndex}] = &replayedOutput{
+				walletID: utxo.WalletID,
+				amount:   utxo.Amount,
+			}
+		}
+	}
+
+	seenTx := make(map[string]int64)
+	for _, block := range chain {
+		if root := calculateMerkleRoot(block.Transactions); root != block.MerkleRoot {
+			report.Violations = append(report.Violations, ChainViolation{
+				Type:       ViolationMerkleRoot,
+				BlockIndex: block.Index,
+				Message:    fmt.Sprintf("stored merkle root %s, calculated %s", block.MerkleRoot, root),
+			})
+		}
+
+		for _, tx := range block.Transactions {
+			report.TransactionsChecked++
+
+			if previous, ok := seenTx[tx.Hash]; ok {
+				report.Violations = append(report.Violations, ChainViolation{
+					Type:            ViolationDuplicateTx,
+					BlockIndex:      block.Index,
+					TransactionHash: tx.Hash,
+					Message:         fmt.Sprintf("transaction already included in block %d", previous),
+				})
+				continue
+			}
+			seenTx[tx.Hash] = block.Index
+
+			if err := checkLockTime(tx, block.Index, block.Timestamp); err != nil {
+				report.Violations = append(report.Violations, ChainViolation{
+					Type:            ViolationLockTime,
+					BlockIndex:      block.Index,
+					TransactionHash: tx.Hash,
+					Message:         err.Error(),
+				})
+			}
+
+			report.Violations = append(report.Violations, replayTransaction(tx, block.Index, block.Index, block.Timestamp, utxosByID, replayed)...)
+		}
+	}
+
+	// Pending transactions have already been committed to the UTXO store and will
+	// be mined in the next block
+	nextHeight := int64(0)
+	if len(chain) > 0 {
+		nextHeight = chain[len(chain)-1].Index + 1
+	}
+	for _, tx := range pending {
+		if _, ok := seenTx[tx.Hash]; ok {
+			continue
+		}
+		report.Violations = append(report.Violations, replayTransaction(tx, -1, nextHeight, time.Time{}, utxosByID, replayed)...)
+	}
+
+	report.Violations = append(report.Violations, compareUTXOSet(replayed, storedUTXOs)...)
+
+	violations := []ChainViolation{}
+	report.Unverifiable = []ChainViolation{}
+	for _, v := range report.Violations {
+		if v.Type == ViolationLegacySignature {
+			report.Unverifiable = append(report.Unverifiable, v)
+		} else {
+			violations = append(violations, v)
+		}
+	}
+	report.Violations = violations
+	report.Valid = len(report.Violations) == 0
+	return report
+}
+
+// replayTransaction checks a transaction's hash and signature and applies it to the replayed
+// UTXO set, reporting any input that is missing, double spent, not unlocked by the
+// transaction, immature or overspent, escrow outputs that are malformed or spent without
+// meeting their lock, and malformed scripts.
+// height is the block the transaction is (or will be) mined in and blockTime the time it
+// was mined; blockIndex is -1 for pending transactions. Escrow deadlines are checked
+// against the mined block; pending escrow transactions were checked on admission, and
+// the miner drops those whose deadline passes while they wait.
+func replayTransaction(tx models.Transaction, blockIndex, height int64, blockTime time.Time, utxosByID map[string]models.UTXO, replayed map[outpoint]*replayedOutput) []ChainViolation {
+	if tx.Type == "genesis" {
+		return nil
+	}
+
+	var violations []ChainViolation
+	report := func(violationType, utxoID, message string) {
+		violations = append(violations, ChainViolation{
+			Type:            violationType,
+			BlockIndex:      blockIndex,
+			TransactionHash: tx.Hash,
+			UTXOID:          utxoID,
+			Message:         message,
+		})
+	}
+
+	if err := verifyTransactionHash(tx); err != nil {
+		report(ViolationTransactionHash, "", err.Error())
+	}
+	if err := verifyTransactionSignature(tx); err != nil {
+		if tx.Version == TransactionVersionLegacy {
+			report(ViolationLegacySignature, "", err.Error())
+		} else {
+			report(ViolationSignature, "", err.Error())
+		}
+	}
+	if err := validateEscrowOutputs(tx); err != nil {
+		report(ViolationEscrow, "", err.Error())
+	}
+	if blockIndex >= 0 {
+		for _, output := range tx.OutputUTXOs {
+			if output.Lock == nil {
+				continue
+			}
+			if err := checkEscrowDeadline(tx, output.Lock, height, blockTime); err != nil {
+				report(ViolationEscrow, "", err.Error())
+			}
+		}
+	}
+	if err := validateScripts(tx); err != nil {
+		report(ViolationScript, "", err.Error())
+	}
+	if err := validateBatchOutputs(tx); err != nil {
+		report(ViolationBatch, "", err.Error())
+	}
+	outputsValid := true
+	if !isRewardTransaction(tx) {
+		if _, err := validateOutputAmounts(tx); err != nil {
+			report(ViolationOutputAmount, "", err.Error())
+			outputsValid = false
+		}
+	}
+
+	checker := newTransactionChecker(tx)
+	inputTotal := models.Amount(0)
+	for idx, utxoID := range tx.InputUTXOs {
+		stored, ok := utxosByID[utxoID]
+		if !ok {
+			report(ViolationMissingInput, utxoID, "input UTXO does not exist")
+			continue
+		}
+
+		output, ok := replayed[outpoint{stored.TransactionHash, stored.OutputIndex}]
+		if !ok {
+			report(ViolationMissingInput, utxoID, fmt.Sprintf("input %s:%d was never created on-chain", stored.TransactionHash, stored.OutputIndex))
+			continue
+		}
+		if output.spent {
+			report(ViolationDoubleSpend, utxoID, fmt.Sprintf("input %s:%d already spent", stored.TransactionHash, stored.OutputIndex))
+			continue
+		}
+		if output.lock != nil {
+			if err := checkEscrowSpend(tx, output.lock); err != nil {
+				report(ViolationEscrow, utxoID, fmt.Sprintf("input %s:%d: %v", stored.TransactionHash, stored.OutputIndex, err))
+			}
+			if blockIndex >= 0 {
+				if err := checkEscrowDeadline(tx, output.lock, height, blockTime); err != nil {
+					report(ViolationEscrow, utxoID, fmt.Sprintf("input %s:%d: %v", stored.TransactionHash, stored.OutputIndex, err))
+				}
+			}
+		} else if tx.Type == "escrow_claim" || tx.Type == "escrow_refund" {
+			report(ViolationEscrow, utxoID, fmt.Sprintf("input %s:%d is not an escrow output", stored.TransactionHash, stored.OutputIndex))
+		} else if err := checkInputUnlocked(tx, idx, output.walletID, output.script, checker); err != nil {
+			report(ViolationInputOwner, utxoID, fmt.Sprintf("input %s:%d: %v", stored.TransactionHash, stored.OutputIndex, err))
+		}
+		if output.coinbaseHeight > 0 && height-output.coinbaseHeight < getCoinbaseMaturity() {
+			report(ViolationImmatureSpend, utxoID, fmt.Sprintf("coinbase from block %d spent at height %d", output.coinbaseHeight, height))
+		}
+
+		output.spent = true
+		if total, err := inputTotal.Add(output.amount); err != nil {
+			report(ViolationOverspend, utxoID, fmt.Sprintf("input total: %v", err))
+			outputsValid = false
+		} else {
+			inputTotal = total
+		}
+	}
+
+	coinbaseHeight := int64(0)
+	if isRewardTransaction(tx) {
+		coinbaseHeight = height
+	}
+
+	outputTotal := models.Amount(0)
+	for idx, output := range tx.OutputUTXOs {
+		if total, err := outputTotal.Add(output.Amount); err == nil {
+			outputTotal = total
+		}
+		replayed[outpoint{tx.Hash, idx}] = &replayedOutput{
+			walletID:       output.WalletID,
+			amount:         output.Amount,
+			coinbaseHeight: coinbaseHeight,
+			lock:           output.Lock,
+			script:         output.Script,
+		}
+	}
+
+	// Coinbases have no inputs; their amount is checked with the block header
+	if isRewardTransaction(tx) {
+		if blockIndex < 0 {
+			report(ViolationReward, "", "coinbase in the pending pool")
+		}
+		return violations
+	}
+
+	// Totals of negative or overflowing amounts mean nothing; those are reported above
+	if !outputsValid {
+		return violations
+	}
+	if outputTotal > inputTotal {
+		report(ViolationOverspend, "", fmt.Sprintf("outputs %s exceed inputs %s", outputTotal, inputTotal))
+	} else if len(violations) == 0 && inputTotal-outputTotal != tx.Fee {
+		report(ViolationFee, "", fmt.Sprintf("declared fee %s, inputs minus outputs %s", tx.Fee, inputTotal-outputTotal))
+	}
+
+	return violations
+}
+
+// compareUTXOSet reports differences between the replayed unspent outputs and the store
+func compareUTXOSet(replayed map[outpoint]*replayedOutput, storedUTXOs []models.UTXO) []ChainViolation {
+	var violations []ChainViolation
+	accounted := make(map[outpoint]bool)
+
+	for _, utxo := range storedUTXOs {
+		point := outpoint{utxo.TransactionHash, utxo.OutputIndex}
+		output, inChain := replayed[point]
+
+		accounted[point] = true
+		if utxo.Spent {
+			if inChain && !output.spent {
+				violations = append(violations, ChainViolation{
+					Type:            ViolationUTXONotInStore,
+					BlockIndex:      -1,
+					TransactionHash: utxo.TransactionHash,
+					UTXOID:          utxo.ID,
+					Message:         "output is unspent on-chain but marked spent in store",
+				})
+			}
+			continue
+		}
+
+		switch {
+		case !inChain || output.spent:
+			violations = append(violations, ChainViolation{
+				Type:            ViolationUTXONotInChain,
+				BlockIndex:      -1,
+				TransactionHash: utxo.TransactionHash,
+				UTXOID:          utxo.ID,
+				Message:         fmt.Sprintf("stored unspent UTXO of %s for %s has no unspent on-chain output", utxo.Amount, utxo.WalletID),
+			})
+		case output.walletID != utxo.WalletID || output.amount != utxo.Amount:
+			violations = append(violations, ChainViolation{
+				Type:            ViolationUTXOMismatch,
+				BlockIndex:      -1,
+				TransactionHash: utxo.TransactionHash,
+				UTXOID:          utxo.ID,
+				Message:         fmt.Sprintf("store has %s for %s, chain has %s for %s", utxo.Amount, utxo.WalletID, output.amount, output.walletID),
+			})
+		}
+	}
+
+	// Unspent on-chain outputs with no stored record at all
+	var missing []outpoint
+	for point, output := range replayed {
+		if output.spent {
+			continue
+		}
+		if accounted[point] {
+			continue
+		}
+		missing = append(missing, point)
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].txHash == missing[j].txHash {
+			return missing[i].index < missing[j].index
+		}
+		return missing[i].txHash < missing[j].txHash
+	})
+
+	for _, point := range missing {
+		output := replayed[point]
+		violations = append(violations, ChainViolation{
+			Type:            ViolationUTXONotInStore,
+			BlockIndex:      -1,
+			TransactionHash: point.txHash,
+			Message:         fmt.Sprintf("on-chain output %d of %s for %s is not in the UTXO store", point.index, output.amount, output.walletID),
+		})
+	}
+
+	return violations
+}
+
+// isAllocationUTXO reports whether a UTXO is an off-chain registration allowance
+func isAllocationUTXO(utxo models.UTXO) bool {
+	return strings.HasPrefix(utxo.TransactionHash, "genesis-")
+}
+
+// logValidationReport writes a summary of a deep validation to the log and system logs
+func logValidationReport(report ChainValidationReport) {
+	if len(report.Unverifiable) > 0 {
+		log.Printf("Deep chain validation could not verify %d legacy signatures", len(report.Unverifiable))
+	}
+	if report.Valid {
+		log.Printf("Deep chain validation passed: %d blocks, %d transactions", report.BlocksChecked, report.TransactionsChecked)
+		return
+	}
+
+	log.Printf("Deep chain validation found %d violations", len(report.Violations))
+	for i, v := range report.Violations {
+		if i == 20 {
+			log.Printf("... %d more", len(report.Violations)-i)
+			break
+		}
+		log.Printf("  [%s] block %d tx %s: %s", v.Type, v.BlockIndex, v.TransactionHash, v.Message)
+	}
+
+	LogSystemEventWithMetadata("validation_failure",
+		fmt.Sprintf("Deep chain validation found %d violations", len(report.Violations)),
+		"", "",
+		map[string]interface{}{"violations": len(report.Violations)})
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"testing"
+	"time"
+)
+
+// violationTypes lists the types of a report's violations
+func violationTypes(report ChainValidationReport) []string {
+	types := make([]string, 0, len(report.Violations))
+	for _, v := range report.Violations {
+		types = append(types, v.Type)
+	}
+	return types
+}
+
+// hasViolation reports whether a report contains a violation of the given type
+func hasViolation(report ChainValidationReport, violationType string) bool {
+	for _, v := range report.Violations {
+		if v.Type == violationType {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateChainDeepAcceptsReplayedChain(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+	if err := ProcessTransaction(bob.transfer(t, alice, 5*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+
+	report := ValidateChainDeep()
+	if !report.Valid {
+		t.Fatalf("valid chain reported violations: %v", report.Violations)
+	}
+	if report.BlocksChecked != 2 || report.TransactionsChecked != 3 {
+		t.Errorf("checked %d blocks and %d transactions, want 2 and 3", report.BlocksChecked, report.TransactionsChecked)
+	}
+}
+
+func TestValidateChainDeepReportsStoreMismatch(t *testing.T) {
+	memory := useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	mustMine(t, GetMinerWallet())
+
+	utxos, _ := memory.GetUnspentUTXOs(bob.WalletID)
+	utxos[0].Amount = 1000 * models.BC
+	if err := memory.SaveUTXO(&utxos[0]); err != nil {
+		t.Fatalf("SaveUTXO: %v", err)
+	}
+
+	report := ValidateChainDeep()
+	if report.Valid || !hasViolation(report, ViolationUTXOMismatch) {
+		t.Fatalf("inflated UTXO not reported: %v", violationTypes(report))
+	}
+}
+
+func TestValidateChainDeepReportsTamperedTransaction(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	block := mustMine(t, GetMinerWallet())
+
+	// Rewrite the payment in the mined block without re-signing it
+	blockchainMutex.Lock()
+	tampered := &blockchain[block.Index].Transactions[1]
+	tampered.OutputUTXOs = append([]models.UTXOOutput(nil), tampered.OutputUTXOs...)
+	tampered.OutputUTXOs[0].Amount = 90 * models.BC
+	blockchainMutex.Unlock()
+
+	report := ValidateChainDeep()
+	if report.Valid || !hasViolation(report, ViolationTransactionHash) {
+		t.Fatalf("tampered transaction not reported: %v", violationTypes(report))
+	}
+}
+
+func TestValidateChainDeepReportsForgedOutputAmounts(t *testing.T) {
+	huge := models.Amount(1) << 62
+
+	tests := []struct {
+		name    string
+		outputs func(tx models.Transaction) []models.UTXOOutput
+	}{
+		{"negative change", func(tx models.Transaction) []models.UTXOOutput {
+			return []models.UTXOOutput{
+				{WalletID: tx.ReceiverWalletID, Amount: 1000000 * models.BC},
+				{WalletID: tx.SenderWalletID, Amount: 100*models.BC - tx.Fee - 1000000*models.BC},
+			}
+		}},
+		{"output total overflow", func(tx models.Transaction) []models.UTXOOutput {
+			return []models.UTXOOutput{
+				{WalletID: tx.ReceiverWalletID, Amount: huge},
+				{WalletID: tx.SenderWalletID, Amount: huge},
+				{WalletID: tx.SenderWalletID, Amount: huge},
+				{WalletID: tx.SenderWalletID, Amount: huge},
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestStore(t)
+			alice := newTestWallet(t, 100*models.BC)
+			bob := newTestWallet(t, 0)
+
+			if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+				t.Fatalf("ProcessTransaction: %v", err)
+			}
+			block := mustMine(t, GetMinerWallet())
+
+			// Replace the payment with a forged one that is correctly hashed and signed
+			blockchainMutex.Lock()
+			forged := blockchain[block.Index].Transactions[1]
+			forged.OutputUTXOs = tt.outputs(forged)
+			forged.Amount = forged.OutputUTXOs[0].Amount
+			forged.Hash = CalculateTransactionHash(forged)
+			alice.sign(t, &forged)
+			blockchain[block.Index].Transactions[1] = forged
+			blockchainMutex.Unlock()
+
+			report := ValidateChainDeep()
+			if report.Valid || !hasViolation(report, ViolationOutputAmount) {
+				t.Fatalf("forged outputs not reported: %v", violationTypes(report))
+			}
+			if hasViolation(report, ViolationSignature) || hasViolation(report, ViolationTransactionHash) {
+				t.Errorf("forged transaction should be correctly signed: %v", violationTypes(report))
+			}
+		})
+	}
+}
+
+func TestVerifyLegacySignatureUsesSignedTimestamp(t *testing.T) {
+	privateKey, publicKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+
+	// Legacy signatures cover the timestamp's String form, monotonic reading included
+	tx := models.Transaction{
+		Version:          TransactionVersionLegacy,
+		SenderWalletID:   "sender",
+		ReceiverWalletID: "receiver",
+		Amount:           12 * models.BC,
+		Timestamp:        time.Now(),
+		Note:             "rent",
+		SenderPublicKey:  crypto.PublicKeyToString(publicKey),
+		Type:             "transfer",
+	}
+	payload := crypto.CreateTransactionPayload(tx.SenderWalletID, tx.ReceiverWalletID, "12.00000000", tx.Timestamp.String(), tx.Note)
+	if tx.Signature, err = crypto.SignData(payload, privateKey); err != nil {
+		t.Fatalf("SignData: %v", err)
+	}
+
+	if err := verifyTransactionSignature(tx); err != nil {
+		t.Fatalf("signature over the original timestamp: %v", err)
+	}
+
+	// A database round-trip drops the monotonic reading
+	stored := tx
+	stored.Timestamp = tx.Timestamp.Round(0)
+	if err := verifyTransactionSignature(stored); err == nil {
+		t.Fatal("signature verified against a different timestamp string")
+	}
+}
+
+func TestValidateChainDeepSeparatesUnverifiableLegacySignatures(t *testing.T) {
+	useTestStore(t)
+	alice := newTestWallet(t, 100*models.BC)
+	bob := newTestWallet(t, 0)
+
+	if err := ProcessTransaction(alice.transfer(t, bob, 10*models.BC)); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	block := mustMine(t, GetMinerWallet())
+
+	// As a version 0 transaction the payment's signature no longer verifies
+	blockchainMutex.Lock()
+	blockchain[block.Index].Transactions[1].Version = TransactionVersionLegacy
+	blockchainMutex.Unlock()
+
+	report := ValidateChainDeep()
+	if !report.Valid {
+		t.Fatalf("legacy signature failed validation: %v", violationTypes(report))
+	}
+	if len(report.Unverifiable) != 1 || report.Unverifiable[0].Type != ViolationLegacySignature {
+		t.Fatalf("unverifiable = %+v, want one legacy signature", report.Unverifiable)
+	}
+}
@@ -85,7 +85,8 @@ func ProcessMonthlyZakat() error {
 	return nil
 }
 
-// deductZakat deducts zakat from a single user
+// deductZakat deducts zakat from a single user. Each of the user's wallets pays its
+// own share, so HD addresses are charged the same percentage as the main wallet.
 func deductZakat(user models.User, percentage float64, month string) error {
 	// Check if already deducted this month
 	if user.ZakatTracking.LastDeduction.Format("2006-01") == month {
@@ -93,47 +94,81 @@ func deductZakat(user models.User, percentage float64, month string) error {
 		return nil
 	}
 
-	// Calculate balance
-	balance, err := CalculateBalance(user.WalletID)
+	walletIDs, err := GetUserWalletIDs(&user)
 	if err != nil {
 		return err
 	}
 
+	var deducted models.Amount
+	var deductErr error
+	for _, walletID := range walletIDs {
+		amount, err := deductWalletZakat(user, walletID, percentage, month)
+		if err != nil {
+			// Keep what was already deducted so a retry does not charge those wallets twice
+			deductErr = fmt.Errorf("wallet %s: %w", walletID, err)
+			break
+		}
+		deducted += amount
+	}
+
+	if deducted == 0 {
+		return deductErr
+	}
+
+	// Update user's zakat tracking
+	user.ZakatTracking.LastDeduction = time.Now()
+	user.ZakatTracking.TotalDeducted += deducted
+	user.ZakatTracking.MonthlyDeducted = deducted
+	user.UpdatedAt = time.Now()
+
+	if err := UpdateUser(&user); err != nil {
+		return err
+	}
+
+	log.Printf("Zakat deducted for user %s: %s (%.2f%%)", user.ID, deducted, percentage)
+
+	return deductErr
+}
+
+// deductWalletZakat deducts zakat from one of a user's wallets and returns the amount
+// deducted, which is zero when the balance is too small
+func deductWalletZakat(user models.User, walletID string, percentage float64, month string) (models.Amount, error) {
+	// Calculate balance
+	balance, err := CalculateBalance(walletID)
+	if err != nil {
+		return 0, err
+	}
+
 	// Skip if balance is zero or negative
 	if balance <= 0 {
-		log.Printf("User %s has zero balance, skipping zakat", user.ID)
-		return nil
+		log.Printf("Wallet %s of user %s has zero balance, skipping zakat", walletID, user.ID)
+		return 0, nil
 	}
 
 	// Calculate zakat amount
-	zakatAmount := balance * (percentage / 100)
+	zakatAmount := balance.Percent(percentage)
 
-	if zakatAmount < 0.01 {
-		log.Printf("Zakat amount too small for user %s, skipping", user.ID)
-		return nil
+	if zakatAmount < models.BC/100 {
+		log.Printf("Zakat amount too small for wallet %s of user %s, skipping", walletID, user.ID)
+		return 0, nil
 	}
 
 	// Create zakat transaction
-	tx, err := CreateZakatTransaction(user.WalletID, zakatAmount, month)
+	tx, err := CreateZakatTransaction(walletID, zakatAmount, month)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// Process transaction
+	// Process transaction (validates, spends inputs and creates outputs atomically)
 	if err := ProcessTransaction(*tx); err != nil {
-		return err
-	}
-
-	// Process UTXOs
-	if err := ProcessTransactionUTXOs(*tx); err != nil {
-		return err
+		return 0, err
 	}
 
 	// Record zakat deduction
 	zakatDeduction := models.ZakatDeduction{
 		ID:              uuid.New().String(),
 		UserID:          user.ID,
-		WalletID:        user.WalletID,
+		WalletID:        walletID,
 		Amount:          zakatAmount,
 		BalanceBefore:   balance,
 		BalanceAfter:    balance - zakatAmount,
@@ -144,30 +179,18 @@ func deductZakat(user models.User, percentage float64, month string) error {
 	}
 
 	if err := SaveZakatDeduction(&zakatDeduction); err != nil {
-		return err
-	}
-
-	// Update user's zakat tracking
-	user.ZakatTracking.LastDeduction = time.Now()
-	user.ZakatTracking.TotalDeducted += zakatAmount
-	user.ZakatTracking.MonthlyDeducted = zakatAmount
-	user.UpdatedAt = time.Now()
-
-	if err := UpdateUser(&user); err != nil {
-		return err
+		return zakatAmount, err
 	}
 
 	// Recalculate wallet balance
-	if err := RecalculateWalletBalance(user.WalletID); err != nil {
+	if err := RecalculateWalletBalance(walletID); err != nil {
 		log.Printf("Warning: failed to recalculate balance: %v", err)
 	}
 
-	log.Printf("Zakat deducted for user %s: %.2f (%.2f%%)", user.ID, zakatAmount, percentage)
-
 	// Log transaction
-	LogTransactionEvent(tx.Hash, "zakat_deducted", user.ID, user.WalletID, "", zakatAmount, "completed")
+	LogTransactionEvent(tx.Hash, "zakat_deducted", user.ID, walletID, "", zakatAmount, "completed")
 
-	return nil
+	return zakatAmount, nil
 }
 
 // getZakatPercentage returns the zakat percentage from environment
@@ -197,12 +220,12 @@ func GetZakatSummary(userID string) (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	totalDeducted := 0.0
-	monthlyDeductions := make(map[string]float64)
+	totalDeducted := models.Amount(0)
+	monthlyDeductions := make(map[string]models.Amount)
 
 	for _, deduction := range history {
 		totalDeducted += deduction.Amount
-		monthlyDeductions[deduction.Month] = deduction.Amount
+		monthlyDeductions[deduction.Month] += deduction.Amount
 	}
 
 	summary := map[string]interface{}{
@@ -74,12 +74,10 @@ export const AuthProvider = ({ children }) => {
     try {
       const response = await api.post('/login', { email, password });
       
-      const { token, user, privateKey } = response.data;
+      // The private key is not returned on login; it stays on the device it was saved to
+      const { token, user } = response.data;
       localStorage.setItem('token', token);
       localStorage.setItem('user', JSON.stringify(user));
-      if (privateKey) {
-        localStorage.setItem('privateKey', privateKey);
-      }
       
       setCurrentUser(user);
       setUserProfile(user);
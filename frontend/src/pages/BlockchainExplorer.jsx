@@ -2,7 +2,7 @@ import { useState, useEffect } from 'react';
 import { useNavigate, useParams } from 'react-router-dom';
 import api from '../utils/api';
 import Navbar from '../components/Navbar';
-import { Box, Hash, Calendar, ArrowRight, Search, ChevronLeft, ChevronRight, Copy, Check } from 'lucide-react';
+import { Box, Hash, Calendar, ArrowRight, Search, ChevronLeft, ChevronRight, Copy, Check, ShieldCheck, ShieldAlert } from 'lucide-react';
 import toast from 'react-hot-toast';
 
 const BlockchainExplorer = () => {
@@ -14,6 +14,8 @@ const BlockchainExplorer = () => {
   const [searchTerm, setSearchTerm] = useState('');
   const [currentPage, setCurrentPage] = useState(1);
   const [copiedField, setCopiedField] = useState(null);
+  const [proof, setProof] = useState(null);
+  const [proofLoading, setProofLoading] = useState(null);
   const blocksPerPage = 10;
 
   useEffect(() => {
@@ -59,8 +61,22 @@ const BlockchainExplorer = () => {
     }
   };
 
+  const fetchProof = async (txHash) => {
+    try {
+      setProofLoading(txHash);
+      const res = await api.get(`/transaction/${txHash}/proof`);
+      setProof({ ...res.data.proof, verified: res.data.verified });
+    } catch (error) {
+      console.error('Error fetching proof:', error);
+      toast.error(error.response?.data?.error || 'Failed to load proof');
+    } finally {
+      setProofLoading(null);
+    }
+  };
+
   const handleBlockClick = (block) => {
     setSelectedBlock(block);
+    setProof(null);
     navigate(`/blockchain/${block.hash}`);
   };
 
@@ -363,14 +379,63 @@ const BlockchainExplorer = () => {
                       {selectedBlock.transactions.map((tx, index) => (
                         <div
                           key={index}
-                          onClick={() => navigate(`/transaction/${tx.hash}`)}
-                          className="p-2 bg-gray-50 rounded border border-gray-200 hover:border-blue-300 cursor-pointer transition"
+                          className="flex items-center gap-2 p-2 bg-gray-50 rounded border border-gray-200 hover:border-blue-300 transition"
                         >
-                          <p className="text-xs font-mono text-gray-600 truncate">{tx.hash}</p>
+                          <p
+                            onClick={() => navigate(`/transaction/${tx.hash}`)}
+                            className="flex-1 text-xs font-mono text-gray-600 truncate cursor-pointer"
+                          >
+                            {tx.hash}
+                          </p>
+                          <button
+                            onClick={() => fetchProof(tx.hash)}
+                            disabled={proofLoading === tx.hash}
+                            className="text-xs px-2 py-1 rounded border border-gray-300 hover:bg-gray-100 disabled:opacity-50"
+                          >
+                            {proofLoading === tx.hash ? 'Loading...' : 'Proof'}
+                          </button>
                         </div>
                       ))}
                     </div>
                   )}
+
+                  {/* Merkle Proof */}
+                  {proof && proof.blockHash === selectedBlock.hash && (
+                    <div className="mt-4 p-4 rounded-lg border border-gray-200 bg-gray-50">
+                      <div className="flex items-center gap-2 mb-2">
+                        {proof.verified ? (
+                          <ShieldCheck className="w-5 h-5 text-green-600" />
+                        ) : (
+                          <ShieldAlert className="w-5 h-5 text-red-600" />
+                        )}
+                        <p className="text-sm font-semibold text-gray-800">
+                          Merkle Proof {proof.verified ? 'Verified' : 'Invalid'}
+                        </p>
+                      </div>
+                      <p className="text-xs text-gray-600 mb-1">
+                        Transaction #{proof.transactionIndex}
+                      </p>
+                      <p className="text-xs font-mono text-gray-600 break-all mb-3">
+                        {proof.transactionHash}
+                      </p>
+                      {proof.path.length === 0 ? (
+                        <p className="text-xs text-gray-600">
+                          Only transaction in the block; its hash is the merkle root.
+                        </p>
+                      ) : (
+                        <div className="space-y-1">
+                          <p className="text-xs font-semibold text-gray-700 uppercase">Sibling Path</p>
+                          {proof.path.map((step, index) => (
+                            <p key={index} className="text-xs font-mono text-gray-600 break-all">
+                              {index + 1}. [{step.position}] {step.hash}
+                            </p>
+                          ))}
+                        </div>
+                      )}
+                      <p className="text-xs font-semibold text-gray-700 uppercase mt-3">Merkle Root</p>
+                      <p className="text-xs font-mono text-gray-600 break-all">{proof.header.merkleRoot}</p>
+                    </div>
+                  )}
                 </div>
               </div>
             ) : (
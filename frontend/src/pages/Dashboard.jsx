@@ -11,7 +11,9 @@ import {
   Clock, 
   Eye,
   Copy,
-  RefreshCw
+  RefreshCw,
+  Plus,
+  KeyRound
 } from 'lucide-react';
 import toast from 'react-hot-toast';
 
@@ -19,6 +21,11 @@ const Dashboard = () => {
   const { userProfile, refreshProfile } = useAuth();
   const navigate = useNavigate();
   const [balance, setBalance] = useState(0);
+  const [address, setAddress] = useState('');
+  const [addresses, setAddresses] = useState([]);
+  const [hdAccounts, setHdAccounts] = useState([]);
+  const [accountKeys, setAccountKeys] = useState('');
+  const [showHdSetup, setShowHdSetup] = useState(false);
   const [transactions, setTransactions] = useState([]);
   const [loading, setLoading] = useState(true);
   const [refreshing, setRefreshing] = useState(false);
@@ -34,6 +41,11 @@ const Dashboard = () => {
       // Fetch balance
       const balanceRes = await api.get('/balance');
       setBalance(balanceRes.data.balance || 0);
+      setAddress(balanceRes.data.address || '');
+      setAddresses(balanceRes.data.addresses || []);
+
+      const addressRes = await api.get('/wallet/addresses');
+      setHdAccounts(addressRes.data.accounts || []);
 
       // Fetch recent transactions
       const txRes = await api.get('/transactions');
@@ -56,12 +68,42 @@ const Dashboard = () => {
   };
 
   const copyWalletId = () => {
-    if (userProfile?.walletId) {
-      navigator.clipboard.writeText(userProfile.walletId);
+    if (address || userProfile?.walletId) {
+      // Share the checksummed address; it resolves to the same wallet as a legacy ID
+      navigator.clipboard.writeText(address || userProfile.walletId);
       toast.success('Wallet ID copied!');
     }
   };
 
+  // Only account public keys are sent; the mnemonic stays with the user
+  const createHDWallet = async () => {
+    const keys = accountKeys.split(/[\s,]+/).filter(Boolean);
+    if (keys.length === 0) {
+      toast.error('Paste at least one account key');
+      return;
+    }
+    try {
+      await api.post('/wallet/hd', { accountKeys: keys });
+      setAccountKeys('');
+      setShowHdSetup(false);
+      toast.success('HD wallet created');
+      await fetchDashboardData();
+    } catch (error) {
+      toast.error(error.response?.data?.error || 'Failed to create HD wallet');
+    }
+  };
+
+  const createAddress = async (account) => {
+    try {
+      const res = await api.post('/wallet/addresses', { account });
+      navigator.clipboard.writeText(res.data.address);
+      toast.success('New address copied!');
+      await fetchDashboardData();
+    } catch (error) {
+      toast.error(error.response?.data?.error || 'Failed to create address');
+    }
+  };
+
   const formatDate = (dateString) => {
     return new Date(dateString).toLocaleString();
   };
@@ -108,7 +150,7 @@ const Dashboard = () => {
             <div className="mt-6 bg-white/10 backdrop-blur-md rounded-2xl p-5 border border-white/20 shadow-xl hover:bg-white/15 transition-all duration-300">
               <p className="text-blue-100 text-sm mb-2 font-semibold">Your Wallet ID</p>
               <div className="flex items-center gap-3">
-                <p className="font-mono text-lg font-medium">{userProfile?.walletId}</p>
+                <p className="font-mono text-lg font-medium">{address || userProfile?.walletId}</p>
                 <button
                   onClick={copyWalletId}
                   className="hover:bg-white/20 p-2.5 rounded-lg transition-all duration-200 transform hover:scale-110"
@@ -138,6 +180,75 @@ const Dashboard = () => {
           </div>
         </div>
 
+        {/* Addresses: the balance above is the sum of these */}
+        <div className="bg-white/80 backdrop-blur-sm rounded-3xl shadow-xl p-8 mb-8 border border-gray-100">
+          <div className="flex items-center justify-between mb-4">
+            <h3 className="text-xl font-bold text-gray-800 flex items-center gap-2">
+              <KeyRound className="w-5 h-5 text-blue-600" />
+              Addresses
+            </h3>
+            {hdAccounts.length === 0 ? (
+              <button
+                onClick={() => setShowHdSetup(!showHdSetup)}
+                className="bg-blue-600 hover:bg-blue-700 text-white px-4 py-2 rounded-xl flex items-center gap-2 text-sm font-semibold"
+              >
+                <Plus className="w-4 h-4" />
+                Create HD wallet
+              </button>
+            ) : (
+              <div className="flex gap-2">
+                {hdAccounts.map((account) => (
+                  <button
+                    key={account.index}
+                    onClick={() => createAddress(account.index)}
+                    className="bg-blue-600 hover:bg-blue-700 text-white px-4 py-2 rounded-xl flex items-center gap-2 text-sm font-semibold"
+                  >
+                    <Plus className="w-4 h-4" />
+                    {account.name}
+                  </button>
+                ))}
+              </div>
+            )}
+          </div>
+
+          {showHdSetup && hdAccounts.length === 0 && (
+            <div className="mb-4 p-4 rounded-xl bg-yellow-50 border-2 border-yellow-300">
+              <p className="text-sm font-semibold text-yellow-800 mb-2">
+                Run <code>go run main.go hd-account-keys -new</code> offline, write down the
+                recovery phrase it prints and paste its account keys below, one per line.
+                The recovery phrase is never sent to the server.
+              </p>
+              <textarea
+                value={accountKeys}
+                onChange={(e) => setAccountKeys(e.target.value)}
+                rows={3}
+                className="w-full font-mono text-sm p-2 rounded-lg border border-yellow-300"
+                placeholder="Account keys"
+              />
+              <button
+                onClick={createHDWallet}
+                className="mt-3 bg-blue-600 hover:bg-blue-700 text-white px-4 py-2 rounded-xl text-sm font-semibold"
+              >
+                Create HD wallet
+              </button>
+            </div>
+          )}
+
+          <div className="divide-y divide-gray-100">
+            {addresses.map((item) => (
+              <div key={item.walletId} className="flex items-center justify-between py-3">
+                <div className="min-w-0">
+                  <p className="font-mono text-sm text-gray-800 truncate">{item.address}</p>
+                  <p className="text-xs text-gray-500">
+                    {item.primary ? 'Main wallet' : item.label || item.derivationPath}
+                  </p>
+                </div>
+                <p className="font-semibold text-gray-800 ml-4">{item.balance.toFixed(2)} BC</p>
+              </div>
+            ))}
+          </div>
+        </div>
+
         {/* Quick Actions with Enhanced Styling */}
         <div className="grid grid-cols-1 md:grid-cols-3 gap-6 mb-8">
           <button
@@ -33,7 +33,9 @@ const Transactions = () => {
     try {
       setLoading(true);
       const res = await api.get('/transactions');
-      const txData = res.data?.transactions || res.data || [];
+      // Payment lines break batches into one row per recipient
+      const lines = res.data?.lines?.map((line) => ({ ...line, hash: line.transactionHash }));
+      const txData = lines || res.data?.transactions || res.data || [];
       setTransactions(Array.isArray(txData) ? txData : []);
     } catch (error) {
       console.error('Error fetching transactions:', error);
@@ -242,7 +244,7 @@ const Transactions = () => {
                       const type = getTransactionType(tx);
                       return (
                         <tr
-                          key={tx.hash}
+                          key={`${tx.hash}-${tx.receiverWalletId}`}
                           onClick={() => navigate(`/transaction/${tx.hash}`)}
                           className="hover:bg-gray-50 cursor-pointer transition"
                         >
@@ -0,0 +1,81 @@
+// Client-side transaction signing. The private key stays in the browser; the server
+// only receives the signature over the canonical payload from /transaction/build.
+
+const base64ToBytes = (b64) => Uint8Array.from(atob(b64), (c) => c.charCodeAt(0));
+
+const bytesToBase64 = (bytes) => {
+  let binary = '';
+  bytes.forEach((b) => {
+    binary += String.fromCharCode(b);
+  });
+  return btoa(binary);
+};
+
+// DER encoding helpers used to wrap a PKCS#1 key in the PKCS#8 structure WebCrypto expects
+const derLength = (n) => {
+  if (n < 0x80) return [n];
+  if (n < 0x100) return [0x81, n];
+  if (n < 0x10000) return [0x82, n >> 8, n & 0xff];
+  return [0x83, n >> 16, (n >> 8) & 0xff, n & 0xff];
+};
+
+const derNode = (tag, content) => new Uint8Array([tag, ...derLength(content.length), ...content]);
+
+// AlgorithmIdentifier for rsaEncryption with NULL parameters
+const RSA_ALGORITHM_ID = [0x30, 0x0d, 0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x01, 0x01, 0x05, 0x00];
+
+const pemToPkcs8 = (pem) => {
+  const body = pem
+    .replace(/-----(BEGIN|END) [A-Z ]+-----/g, '')
+    .replace(/\s+/g, '');
+  const der = base64ToBytes(body);
+
+  if (pem.includes('BEGIN PRIVATE KEY')) {
+    return der;
+  }
+  if (!pem.includes('BEGIN RSA PRIVATE KEY')) {
+    throw new Error('Unsupported private key format');
+  }
+
+  const version = [0x02, 0x01, 0x00];
+  return derNode(0x30, [...version, ...RSA_ALGORITHM_ID, ...derNode(0x04, der)]);
+};
+
+const hexToBytes = (hex) => Uint8Array.from(hex.match(/../g), (h) => parseInt(h, 16));
+
+// signTransaction signs a transaction returned by /transaction/build and returns the
+// base64 signature. RSA keys sign the canonical payload with RSASSA-PKCS1-v1_5 / SHA-256;
+// Ed25519 keys sign the hash bytes. secp256k1 keys need an external signer.
+export const signTransaction = async (privateKeyPem, { signingPayload, hash, keyAlgorithm }) => {
+  if (keyAlgorithm === 'ed25519') {
+    const key = await window.crypto.subtle.importKey(
+      'pkcs8',
+      pemToPkcs8(privateKeyPem),
+      { name: 'Ed25519' },
+      false,
+      ['sign']
+    );
+    const signature = await window.crypto.subtle.sign('Ed25519', key, hexToBytes(hash));
+    return bytesToBase64(new Uint8Array(signature));
+  }
+
+  if (keyAlgorithm && keyAlgorithm !== 'rsa') {
+    throw new Error(`Browser signing is not available for ${keyAlgorithm} keys`);
+  }
+
+  const key = await window.crypto.subtle.importKey(
+    'pkcs8',
+    pemToPkcs8(privateKeyPem),
+    { name: 'RSASSA-PKCS1-v1_5', hash: 'SHA-256' },
+    false,
+    ['sign']
+  );
+
+  const signature = await window.crypto.subtle.sign(
+    'RSASSA-PKCS1-v1_5',
+    key,
+    base64ToBytes(signingPayload)
+  );
+
+  return bytesToBase64(new Uint8Array(signature));
+};